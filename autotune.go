@@ -88,6 +88,63 @@
 //   - [go.uber.org/automaxprocs]
 //   - [github.com/KimMachineGun/automemlimit]
 //
+// If GOMAXPROCS or GOMEMLIMIT was already modified by another package, and
+// corresponding environment variable is not set, a warning is logged and values
+// are overridden. Because package initialization order is not always obvious,
+// main packages can call [Configure] explicitly to order initialization, and
+// use [WithPreserveExternal] to leave values modified by other packages unchanged.
+//
+// # Overriding cgroup Path
+//
+// On Linux, cgroup interface path is resolved from /proc/self/mountinfo and
+// /proc/self/cgroup. In some sandboxes (gVisor, masked /proc, chroots), these
+// may be unreadable or misleading. To override,
+//
+//   - Set "GOAUTOTUNE_CGROUP_PATH" environment variable to path of cgroup interface
+//     files, for example /sys/fs/cgroup.
+//   - Set "GOAUTOTUNE_PROCFS" environment variable to procfs directory of the process
+//     containing mountinfo and cgroup files. Default is /proc/self.
+//
+// If cgroup interface path cannot be resolved, but /sys/fs/cgroup is a cgroup2
+// mount rooted at process' cgroup (as with cgroup namespaces), it is used.
+//
+// # Tuning Reserve
+//
+// To tune memory set aside as reserved without recompiling, set
+// "GOAUTOTUNE_MEMLIMIT_RESERVE" environment variable to a reserve spec, like
+// "15%,min=32MiB,max=512MiB". See [ParseReserve] for its syntax. Note that
+// suffixes K, M, G and T are decimal, thus use KiB, MiB, GiB and TiB for
+// powers of 1024. This is ignored if [WithReserveFunc] is specified.
+//
+// # Shared Limits
+//
+// When CPU and memory limits are shared by multiple processes, for example with
+// sidecar binaries in a single container or pre-fork servers, each process would
+// otherwise configure GOMAXPROCS and GOMEMLIMIT from the entire limits. To divide
+// limits, use [WithShare] or [WithProcessShare], or set "GOAUTOTUNE_SHARE"
+// environment variable to,
+//
+//   - a number in range (0, 1), like "0.5", to use that share of limits.
+//   - "procs" to divide limits equally among processes in the cgroup.
+//   - "others" to subtract memory used by other processes from memory limits
+//     (see [WithExcludeOthers]) and divide CPU limits equally among processes.
+//
+// This is ignored if any of these options are specified.
+//
+// # systemd Integration
+//
+// To report GOMAXPROCS, GOMEMLIMIT and their sources to systemd, which are
+// shown by "systemctl status", set "GOAUTOTUNE_NOTIFY" environment variable
+// to "true" or use [WithSystemdNotify].
+//
+// # Init Deadline
+//
+// Automatic configuration on import is bounded by a deadline of 1 second, so
+// that a hung read (for example, FUSE-backed /proc) does not block the program.
+// To change it, set "GOAUTOTUNE_TIMEOUT" environment variable to a duration,
+// like "500ms" or "0" to disable it. Panics in detectors and functions specified
+// via options are recovered and returned as errors by [Configure].
+//
 // # Disable at Runtime
 //
 // To disable automatic configuration at runtime (for compiled binaries),
@@ -99,6 +156,7 @@
 // [MemoryMax]: https://www.freedesktop.org/software/systemd/man/latest/systemd.resource-control.html#MemoryMax=bytes
 // [MemoryHigh]: https://www.freedesktop.org/software/systemd/man/latest/systemd.resource-control.html#MemoryHigh=bytes
 // [DefaultReserveFunc]: https://pkg.go.dev/github.com/tprasadtp/go-autotune/memlimit#DefaultReserveFunc
+// [ParseReserve]: https://pkg.go.dev/github.com/tprasadtp/go-autotune/memlimit#ParseReserve
 // [QueryInformationJobObject]: https://learn.microsoft.com/en-us/windows/win32/api/jobapi2/nf-jobapi2-queryinformationjobobject
 // [JOBOBJECT_EXTENDED_LIMIT_INFORMATION]: https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_extended_limit_information
 // [Vertical Pod autoscaling]: https://github.com/kubernetes/autoscaler/tree/master/vertical-pod-autoscaler
@@ -106,10 +164,45 @@
 package autotune
 
 import (
+	"context"
+
 	"github.com/tprasadtp/go-autotune/internal/autotune"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
 )
 
 //nolint:gochecknoinits // ignore
 func init() {
-	autotune.Configure()
+	ctx, cancel := autotune.InitContext()
+	defer cancel()
+	_, _ = Configure(ctx)
+}
+
+// Report describes GOMAXPROCS and GOMEMLIMIT values applied by [Configure].
+type Report struct {
+	// GOMAXPROCS result.
+	MaxProcs maxprocs.Result `json:"maxprocs"`
+
+	// GOMEMLIMIT result.
+	MemLimit memlimit.Result `json:"memlimit"`
+}
+
+// Configure configures GOMAXPROCS and GOMEMLIMIT and returns a [Report].
+// This is already done when this package is imported, but main packages may
+// call it again with custom options, or after all packages are initialized
+// to explicitly order initialization. Like automatic configuration, this does
+// nothing if "GOAUTOTUNE" environment variable is set to "false" or "0".
+//
+// On Linux, cgroup interface path is resolved only once and shared by
+// both CPU and memory quota detectors, unless custom detectors are specified.
+// Errors configuring GOMAXPROCS and GOMEMLIMIT are joined together with
+// [errors.Join]. Even when an error is returned, [Report] contains
+// current GOMAXPROCS and GOMEMLIMIT values.
+func Configure(ctx context.Context, opts ...Option) (Report, error) {
+	cfg := newConfig(opts...)
+	report, err := autotune.Run(ctx, cfg.runConfig())
+	return Report{
+		MaxProcs: report.MaxProcs,
+		MemLimit: report.MemLimit,
+	}, err
 }
@@ -4,10 +4,19 @@
 package autotune_test
 
 import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"runtime/debug"
 	"testing"
 
+	"github.com/tprasadtp/go-autotune"
+	"github.com/tprasadtp/go-autotune/internal/shared"
 	"github.com/tprasadtp/go-autotune/internal/trampoline"
 	"github.com/tprasadtp/go-autotune/internal/trampoline/scenarios"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
 )
 
 func TestIntegration(t *testing.T) {
@@ -19,3 +28,114 @@ func TestIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigure(t *testing.T) {
+	reset := func() {
+		runtime.GOMAXPROCS(runtime.NumCPU())
+		debug.SetMemoryLimit(math.MaxInt64)
+	}
+	cpu := maxprocs.CPUQuotaDetectorFunc(
+		func(context.Context) (float64, error) {
+			return 0.5, nil
+		},
+	)
+	mem := memlimit.MemoryQuotaDetectorFunc(
+		func(_ context.Context) (int64, int64, error) {
+			return 250 * shared.MiByte, 0, nil
+		},
+	)
+	tt := []struct {
+		name     string
+		opts     []autotune.Option
+		maxprocs int
+		memlimit int64
+		sources  [2]string
+	}{
+		{
+			name: "Both",
+			opts: []autotune.Option{
+				autotune.WithCPUQuotaDetector(cpu),
+				autotune.WithMemoryQuotaDetector(mem),
+			},
+			maxprocs: 1,
+			memlimit: 225 * shared.MiByte,
+			sources:  [2]string{"quota", "quota"},
+		},
+		{
+			name: "WithMaxProcs(false)",
+			opts: []autotune.Option{
+				autotune.WithCPUQuotaDetector(cpu),
+				autotune.WithMemoryQuotaDetector(mem),
+				autotune.WithMaxProcs(false),
+			},
+			maxprocs: runtime.NumCPU(),
+			memlimit: 225 * shared.MiByte,
+			sources:  [2]string{"default", "quota"},
+		},
+		{
+			name: "WithMemLimit(false)",
+			opts: []autotune.Option{
+				autotune.WithCPUQuotaDetector(cpu),
+				autotune.WithMemoryQuotaDetector(mem),
+				autotune.WithMemLimit(false),
+			},
+			maxprocs: 1,
+			memlimit: math.MaxInt64,
+			sources:  [2]string{"quota", "default"},
+		},
+		{
+			name: "WithRoundFuncAndReserveFunc",
+			opts: []autotune.Option{
+				autotune.WithCPUQuotaDetector(
+					maxprocs.CPUQuotaDetectorFunc(
+						func(context.Context) (float64, error) {
+							return 2.5, nil
+						},
+					),
+				),
+				autotune.WithMemoryQuotaDetector(mem),
+				autotune.WithRoundFunc(func(f float64) int {
+					return int(math.Floor(f))
+				}),
+				autotune.WithReserveFunc(func(int64) int64 {
+					return 0
+				}),
+			},
+			maxprocs: 2,
+			memlimit: 250 * shared.MiByte,
+			sources:  [2]string{"quota", "quota"},
+		},
+	}
+	t.Cleanup(reset)
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+			t.Setenv("GOMAXPROCS", "")
+			t.Setenv("GOMEMLIMIT", "")
+			t.Setenv("GOAUTOTUNE", "")
+
+			tc.opts = append(tc.opts, autotune.WithLogger(slog.New(trampoline.NewTestingHandler(t))))
+			report, err := autotune.Configure(context.Background(), tc.opts...)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+
+			if v := runtime.GOMAXPROCS(-1); v != tc.maxprocs || report.MaxProcs.Value != tc.maxprocs {
+				t.Errorf("GOMAXPROCS expected=%d, got=%d (report=%d)", tc.maxprocs, v, report.MaxProcs.Value)
+			}
+
+			if v := debug.SetMemoryLimit(-1); v != tc.memlimit || report.MemLimit.Value != tc.memlimit {
+				t.Errorf("GOMEMLIMIT expected=%d, got=%d (report=%d)", tc.memlimit, v, report.MemLimit.Value)
+			}
+
+			if string(report.MaxProcs.Source) != tc.sources[0] {
+				t.Errorf("GOMAXPROCS source expected=%s, got=%s", tc.sources[0], report.MaxProcs.Source)
+			}
+
+			if string(report.MemLimit.Source) != tc.sources[1] {
+				t.Errorf("GOMEMLIMIT source expected=%s, got=%s", tc.sources[1], report.MemLimit.Source)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+// Package autotunetest provides utilities for testing code which uses
+// [github.com/tprasadtp/go-autotune/maxprocs] and [github.com/tprasadtp/go-autotune/memlimit]
+// against realistic cgroup layouts.
+//
+// [New] builds a temporary cgroup filesystem from a declarative [Spec] along with
+// matching mountinfo and cgroup proc files, and returns detectors pointed at it.
+// Canned specs for common platforms like [Kubernetes] and [Systemd] are provided.
+//
+//	fs := autotunetest.New(t, autotunetest.Kubernetes())
+//	result, err := maxprocs.Compute(ctx, maxprocs.WithCPUQuotaDetector(fs.Detector(t)))
+//
+// Detectors are only supported on Linux. See [github.com/tprasadtp/go-autotune/cgroup].
+//
+// [RunWithLimits] runs a test function in a new process with real resource limits
+// applied, to test behavior of services under limits.
+package autotunetest
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/cgroup"
+)
+
+// PID used for the proc files.
+const PID = 1234
+
+// Spec describes a cgroup filesystem. Interface files with empty contents
+// are not created, which is same as the controller not being enabled.
+type Spec struct {
+	// cgroup of the process, for example /system.slice/example.service.
+	// If empty, / is used, which is typical for containers with cgroup namespace.
+	Cgroup string
+
+	// Mount point of cgroup2 filesystem. If empty, /sys/fs/cgroup is used.
+	MountPoint string
+
+	// Contents of cpu.max, for example "150000 100000".
+	CPUMax string
+
+	// Contents of cpu.weight, for example "100".
+	CPUWeight string
+
+	// Contents of cpuset.cpus.effective, for example "0-3".
+	CPUSetEffective string
+
+	// Contents of memory.max, for example "268435456".
+	MemoryMax string
+
+	// Contents of memory.high, for example "max".
+	MemoryHigh string
+
+	// Contents of pids.max, for example "max".
+	PIDsMax string
+
+	// Additional interface files to create, keyed by their names.
+	// These take precedence over other fields.
+	Files map[string]string
+
+	// Contents of mountinfo file. If empty, it is generated to contain
+	// cgroup2 mount at MountPoint.
+	MountInfo string
+
+	// Contents of cgroup proc file. If empty, it is generated from Cgroup.
+	CgroupFile string
+}
+
+// FS is a cgroup filesystem built by [New].
+type FS struct {
+	// Root directory of the filesystem.
+	Root string
+
+	// procfs directory, which contains proc files for [PID].
+	ProcFS string
+
+	// Root of the cgroup2 hierarchy.
+	CgroupFS string
+
+	// Directory containing interface files.
+	InterfacePath string
+}
+
+// New builds a cgroup filesystem from spec in a temporary directory,
+// which is removed when the test completes.
+func New(tb testing.TB, spec Spec) *FS {
+	tb.Helper()
+
+	if spec.Cgroup == "" {
+		spec.Cgroup = "/"
+	}
+
+	if spec.MountPoint == "" {
+		spec.MountPoint = "/sys/fs/cgroup"
+	}
+
+	if spec.MountInfo == "" {
+		spec.MountInfo = MountInfo(spec.MountPoint)
+	}
+
+	if spec.CgroupFile == "" {
+		spec.CgroupFile = fmt.Sprintf("0::%s\n", spec.Cgroup)
+	}
+
+	root := tb.TempDir()
+	fs := &FS{
+		Root:     root,
+		ProcFS:   filepath.Join(root, "proc"),
+		CgroupFS: filepath.Join(root, filepath.FromSlash(spec.MountPoint)),
+	}
+	fs.InterfacePath = filepath.Join(fs.CgroupFS, filepath.FromSlash(path.Clean(spec.Cgroup)))
+
+	files := map[string]string{
+		"cpu.max":               spec.CPUMax,
+		"cpu.weight":            spec.CPUWeight,
+		"cpuset.cpus.effective": spec.CPUSetEffective,
+		"memory.max":            spec.MemoryMax,
+		"memory.high":           spec.MemoryHigh,
+		"pids.max":              spec.PIDsMax,
+	}
+	for name, contents := range spec.Files {
+		files[name] = contents
+	}
+
+	proc := filepath.Join(fs.ProcFS, strconv.Itoa(PID))
+	writeFile(tb, filepath.Join(proc, "mountinfo"), spec.MountInfo)
+	writeFile(tb, filepath.Join(proc, "cgroup"), spec.CgroupFile)
+	if err := os.MkdirAll(fs.InterfacePath, 0o755); err != nil {
+		tb.Fatalf("autotunetest: failed to create directory: %s", err)
+	}
+
+	for name, contents := range files {
+		if contents != "" {
+			writeFile(tb, filepath.Join(fs.InterfacePath, name), contents)
+		}
+	}
+	return fs
+}
+
+// Detector returns a detector pointed at the filesystem. It implements both
+// [github.com/tprasadtp/go-autotune/maxprocs.CPUQuotaDetector] and
+// [github.com/tprasadtp/go-autotune/memlimit.MemoryQuotaDetector].
+// Test fails if the detector cannot be created. Use [FS.NewDetector] to check
+// for errors instead.
+func (fs *FS) Detector(tb testing.TB) *cgroup.Detector {
+	tb.Helper()
+	detector, err := fs.NewDetector()
+	if err != nil {
+		tb.Fatalf("autotunetest: failed to create detector: %s", err)
+	}
+	return detector
+}
+
+// NewDetector returns a detector pointed at the filesystem.
+func (fs *FS) NewDetector() (*cgroup.Detector, error) {
+	return cgroup.NewDetector(PID,
+		cgroup.WithProcFS(fs.ProcFS),
+		cgroup.WithCgroupFS(fs.CgroupFS),
+	)
+}
+
+// MountInfo returns contents of a mountinfo file with cgroup2
+// filesystem mounted at mountpoint.
+func MountInfo(mountpoint string) string {
+	return "21 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n" +
+		"22 21 0:20 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw\n" +
+		"23 21 0:21 / /sys rw,nosuid,nodev,noexec,relatime shared:2 - sysfs sysfs rw\n" +
+		fmt.Sprintf("24 23 0:22 / %s rw,nosuid,nodev,noexec,relatime shared:4 - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot\n",
+			escape(mountpoint))
+}
+
+// writeFile writes contents to path creating parent directories.
+func writeFile(tb testing.TB, path, contents string) {
+	tb.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		tb.Fatalf("autotunetest: failed to create directory: %s", err)
+	}
+
+	if !strings.HasSuffix(contents, "\n") {
+		contents += "\n"
+	}
+
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		tb.Fatalf("autotunetest: failed to write file: %s", err)
+	}
+}
+
+// escape escapes mount point like mountinfo file.
+func escape(s string) string {
+	return strings.NewReplacer(
+		" ", `\040`,
+		"\t", `\011`,
+		"\n", `\012`,
+		`\`, `\134`,
+	).Replace(s)
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package autotunetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/autotunetest"
+	"github.com/tprasadtp/go-autotune/cgroup"
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+)
+
+func TestProfiles(t *testing.T) {
+	tt := []struct {
+		name string
+		spec autotunetest.Spec
+		cpu  float64
+		max  int64
+		high int64
+		err  error
+	}{
+		{
+			name: "Kubernetes",
+			spec: autotunetest.Kubernetes(),
+			cpu:  1.5,
+			max:  256 * shared.MiByte,
+		},
+		{
+			name: "KubernetesBestEffort",
+			spec: autotunetest.KubernetesBestEffort(),
+		},
+		{
+			name: "KubernetesRequestsOnly",
+			spec: autotunetest.KubernetesRequestsOnly(),
+		},
+		{
+			name: "Docker",
+			spec: autotunetest.Docker(),
+			cpu:  1.5,
+			max:  250 * shared.MiByte,
+		},
+		{
+			name: "Systemd",
+			spec: autotunetest.Systemd(),
+			cpu:  1.5,
+			max:  300 * shared.MiByte,
+			high: 250 * shared.MiByte,
+		},
+		{
+			name: "SystemdUser",
+			spec: autotunetest.SystemdUser(),
+			max:  300 * shared.MiByte,
+		},
+		{
+			name: "CgroupV1",
+			spec: autotunetest.CgroupV1(),
+			err:  cgroup.ErrCgroupV1,
+		},
+		{
+			name: "MountPointWithSpaces",
+			spec: autotunetest.Spec{
+				MountPoint: "/sys/fs/cgroup v2",
+				CPUMax:     "50000 100000",
+			},
+			cpu: 0.5,
+		},
+		{
+			name: "MalformedFile",
+			spec: autotunetest.Spec{
+				Files: map[string]string{
+					"memory.max": "foo",
+				},
+			},
+			err: cgroup.ErrMalformed,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := autotunetest.New(t, tc.spec)
+			detector, err := fs.NewDetector()
+			if err == nil {
+				var cpu float64
+				var max, high int64
+				cpu, err = detector.DetectCPUQuota(context.Background())
+				if err == nil {
+					if cpu != tc.cpu {
+						t.Errorf("cpu expected=%f, got=%f", tc.cpu, cpu)
+					}
+					max, high, err = detector.DetectMemoryQuota(context.Background())
+					if err == nil && (max != tc.max || high != tc.high) {
+						t.Errorf("memory expected=(%d,%d), got=(%d,%d)", tc.max, tc.high, max, high)
+					}
+				}
+			}
+
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected error matching %q, got %v", tc.err, err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+		})
+	}
+
+	t.Run("CPURequestPolicy", func(t *testing.T) {
+		t.Setenv("GOMAXPROCS", "")
+		fs := autotunetest.New(t, autotunetest.KubernetesRequestsOnly())
+		result, err := maxprocs.Compute(context.Background(),
+			maxprocs.WithCPUQuotaDetector(fs.Detector(t)),
+			maxprocs.WithCPUPolicy(maxprocs.CPURequestPolicy(1, 0)),
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if result.Value != 1 || result.Source != maxprocs.SourceQuota {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("All", func(t *testing.T) {
+		if len(autotunetest.Profiles()) == 0 {
+			t.Errorf("expected profiles")
+		}
+	})
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package autotunetest
+
+// Kubernetes returns a [Spec] for a container in a burstable pod with
+// cgroup namespace, limits of 1500m CPU and 256Mi memory and request
+// of 1500m CPU.
+func Kubernetes() Spec {
+	return Spec{
+		CPUMax:          "150000 100000",
+		CPUWeight:       "59",
+		CPUSetEffective: "0-3",
+		MemoryMax:       "268435456",
+		MemoryHigh:      "max",
+		PIDsMax:         "max",
+	}
+}
+
+// KubernetesBestEffort returns a [Spec] for a container in a best effort pod
+// with cgroup namespace. No limits are defined.
+func KubernetesBestEffort() Spec {
+	return Spec{
+		CPUMax:          "max 100000",
+		CPUWeight:       "1",
+		CPUSetEffective: "0-3",
+		MemoryMax:       "max",
+		MemoryHigh:      "max",
+		PIDsMax:         "max",
+	}
+}
+
+// KubernetesRequestsOnly returns a [Spec] for a container in a burstable pod
+// with cgroup namespace, request of 500m CPU and 256Mi memory but no limits.
+// CPU request is only reflected in cpu.weight. See
+// [github.com/tprasadtp/go-autotune/maxprocs.CPURequestPolicy].
+func KubernetesRequestsOnly() Spec {
+	return Spec{
+		CPUMax:          "max 100000",
+		CPUWeight:       "20",
+		CPUSetEffective: "0-3",
+		MemoryMax:       "max",
+		MemoryHigh:      "max",
+		PIDsMax:         "max",
+	}
+}
+
+// Docker returns a [Spec] for a container started with
+// docker run --cpus=1.5 --memory=250M.
+func Docker() Spec {
+	return Spec{
+		CPUMax:          "150000 100000",
+		CPUWeight:       "100",
+		CPUSetEffective: "0-3",
+		MemoryMax:       "262144000",
+		MemoryHigh:      "max",
+		PIDsMax:         "max",
+	}
+}
+
+// Systemd returns a [Spec] for a transient system service started with
+// systemd-run -p CPUQuota=150% -p MemoryHigh=250M -p MemoryMax=300M.
+func Systemd() Spec {
+	return Spec{
+		Cgroup:          "/system.slice/run-u1801.service",
+		CPUMax:          "150000 100000",
+		CPUWeight:       "100",
+		CPUSetEffective: "0-3",
+		MemoryMax:       "314572800",
+		MemoryHigh:      "262144000",
+		PIDsMax:         "4915",
+	}
+}
+
+// SystemdUser returns a [Spec] for a transient user service started with
+// systemd-run --user -p MemoryMax=300M, when CPU controller is not delegated
+// to user services. Thus, cpu.max does not exist.
+func SystemdUser() Spec {
+	return Spec{
+		Cgroup:     "/user.slice/user-1000.slice/user@1000.service/app.slice/run-u119.service",
+		MemoryMax:  "314572800",
+		MemoryHigh: "max",
+		PIDsMax:    "max",
+	}
+}
+
+// CgroupV1 returns a [Spec] for a host which only has cgroup v1 hierarchies.
+// Detectors cannot be created for it and return an error matching
+// [github.com/tprasadtp/go-autotune/cgroup.ErrCgroupV1].
+func CgroupV1() Spec {
+	return Spec{
+		MountInfo: "21 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n" +
+			"22 21 0:20 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw\n" +
+			"23 21 0:21 / /sys rw,nosuid,nodev,noexec,relatime shared:2 - sysfs sysfs rw\n" +
+			"24 23 0:22 / /sys/fs/cgroup ro,nosuid,nodev,noexec shared:3 - tmpfs tmpfs ro,mode=755\n" +
+			"25 24 0:23 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:4 - cgroup cgroup rw,cpu,cpuacct\n" +
+			"26 24 0:24 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:5 - cgroup cgroup rw,memory\n",
+		CgroupFile: "3:memory:/docker/0f1e2d3c\n2:cpu,cpuacct:/docker/0f1e2d3c\n",
+	}
+}
+
+// Profiles returns all canned specs keyed by their names.
+func Profiles() map[string]Spec {
+	return map[string]Spec{
+		"Kubernetes":             Kubernetes(),
+		"KubernetesBestEffort":   KubernetesBestEffort(),
+		"KubernetesRequestsOnly": KubernetesRequestsOnly(),
+		"Docker":                 Docker(),
+		"Systemd":                Systemd(),
+		"SystemdUser":            SystemdUser(),
+		"CgroupV1":               CgroupV1(),
+	}
+}
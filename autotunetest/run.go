@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package autotunetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+)
+
+// Limits are resource limits applied by [RunWithLimits]. Zero values
+// indicate no limits.
+type Limits struct {
+	// Number of CPUs (cpu.max on Linux and CPU rate on Windows).
+	CPU float64
+
+	// memory.max on Linux and process memory limit on Windows.
+	MemoryMax int64
+
+	// memory.high on Linux and job memory limit on Windows.
+	MemoryHigh int64
+
+	// CPUs allowed (cpuset.cpus), for example "0-1". Only supported on Linux.
+	CPUSet string
+
+	// Maximum number of tasks (pids.max). Only supported on Linux.
+	PIDs int
+
+	// Additional environment variables in KEY=VALUE format.
+	Env []string
+
+	// Timeout for the test. Defaults to 30s.
+	Timeout time.Duration
+}
+
+// RunWithLimits re-executes the current test with given resource limits and runs fn
+// in it. On Linux, test is re-executed via [systemd-run] in a transient unit, or in a
+// child cgroup of a delegated cgroup2 hierarchy specified by GO_TEST_TRAMPOLINE_CGROUP
+// environment variable if systemd is not available, and on Windows, via a job object.
+// Output of the re-executed test is logged and its failures fail the current test.
+// Coverage data is propagated if enabled.
+//
+// fn runs in a new process, thus GOMAXPROCS and GOMEMLIMIT are already configured,
+// if [github.com/tprasadtp/go-autotune] is imported for side effects by the test
+// package. Otherwise, fn must configure them.
+//
+// Test is skipped if limits cannot be applied, for example, if neither systemd nor
+// a writable cgroup2 hierarchy is available or required controllers are not delegated.
+// RunWithLimits must be called from a top level test or a subtest without
+// parallel siblings, as the test is re-executed by its name.
+//
+// [systemd-run]: https://www.freedesktop.org/software/systemd/man/latest/systemd-run.html
+func RunWithLimits(tb testing.TB, limits Limits, fn func(tb testing.TB)) {
+	tb.Helper()
+	if fn == nil {
+		tb.Fatalf("autotunetest: fn is nil")
+	}
+
+	opts := trampoline.Options{
+		Timeout: limits.Timeout,
+		CPU:     limits.CPU,
+		M1:      int(limits.MemoryMax),
+		M2:      int(limits.MemoryHigh),
+		CPUSet:  limits.CPUSet,
+		PIDs:    limits.PIDs,
+		Env:     limits.Env,
+	}
+
+	if !trampoline.IsTrampoline() {
+		if err := trampoline.Check(opts); err != nil {
+			tb.Skipf("autotunetest: cannot run with limits: %s", err)
+		}
+	}
+
+	trampoline.Trampoline(tb, opts, fn, nil)
+}
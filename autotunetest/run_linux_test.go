@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package autotunetest_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/autotunetest"
+	"github.com/tprasadtp/go-autotune/cgroup"
+	"github.com/tprasadtp/go-autotune/internal/shared"
+)
+
+func TestRunWithLimits(t *testing.T) {
+	autotunetest.RunWithLimits(t,
+		autotunetest.Limits{
+			CPU:        1,
+			MemoryMax:  256 * shared.MiByte,
+			MemoryHigh: 200 * shared.MiByte,
+			PIDs:       512,
+			Env:        []string{"AUTOTUNETEST=true"},
+		},
+		func(tb testing.TB) {
+			if v := os.Getenv("AUTOTUNETEST"); v != "true" {
+				tb.Errorf("AUTOTUNETEST expected=true, got=%q", v)
+			}
+
+			detector, err := cgroup.NewDetector(0)
+			if err != nil {
+				tb.Fatalf("failed to create detector: %s", err)
+			}
+
+			cpu, err := detector.DetectCPUQuota(context.Background())
+			if err != nil || cpu != 1 {
+				tb.Errorf("cpu expected=1, got=%f (err=%v)", cpu, err)
+			}
+
+			max, high, err := detector.DetectMemoryQuota(context.Background())
+			if err != nil || max != 256*shared.MiByte || high != 200*shared.MiByte {
+				tb.Errorf("memory expected=(%d,%d), got=(%d,%d) (err=%v)",
+					256*shared.MiByte, 200*shared.MiByte, max, high, err)
+			}
+		},
+	)
+}
@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package cgroup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"testing/fstest"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/quota"
+)
+
+// BundleVersion is the version of the support bundle format.
+const BundleVersion = 1
+
+// Name of the metadata file in tar bundles.
+const bundleMetadataFile = "bundle.json"
+
+// Bundle is a support bundle containing files read by the detector along
+// with environment variables and runtime information of the process.
+// Use [Capture] to create it and [Bundle.Detector] to replay it.
+type Bundle struct {
+	// Version of the bundle format. See [BundleVersion].
+	Version int `json:"version"`
+
+	// Time when bundle was captured.
+	Time time.Time `json:"time"`
+
+	// Operating system.
+	GOOS string `json:"goos"`
+
+	// Architecture.
+	GOARCH string `json:"goarch"`
+
+	// Go version used to build the binary which captured the bundle.
+	GoVersion string `json:"go_version"`
+
+	// Number of logical CPUs usable by the process which captured the bundle.
+	NumCPU int `json:"num_cpu"`
+
+	// GOMAXPROCS of the process which captured the bundle.
+	GOMAXPROCS int `json:"gomaxprocs"`
+
+	// GOMEMLIMIT of the process which captured the bundle.
+	GOMEMLIMIT int64 `json:"gomemlimit"`
+
+	// PID of the process. Zero indicates the process which captured the bundle.
+	PID int `json:"pid"`
+
+	// Root of the cgroup2 hierarchy within the bundle, if [WithCgroupFS]
+	// was used when capturing.
+	CgroupFS string `json:"cgroupfs,omitempty"`
+
+	// Path to cgroup interface files on the host which captured the bundle.
+	InterfacePath string `json:"interface_path,omitempty"`
+
+	// Path to cgroup interface files within the bundle, if it was not resolved
+	// from proc files when capturing, for example when overridden via
+	// GOAUTOTUNE_CGROUP_PATH environment variable. If set, it is used as is
+	// when replaying the bundle.
+	CgroupPath string `json:"cgroup_path,omitempty"`
+
+	// Environment variables of the process, which are set.
+	Env map[string]string `json:"env,omitempty"`
+
+	// Contents of files, keyed by their paths within the bundle. Paths
+	// are relative to root filesystem of the process, for example
+	// proc/self/mountinfo or sys/fs/cgroup/system.slice/example.service/cpu.max.
+	Files map[string]string `json:"files,omitempty"`
+
+	// Errors encountered when capturing, keyed by paths of files within
+	// the bundle or step which failed.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// FS returns files in the bundle as a [fs.FS].
+func (b *Bundle) FS() fs.FS {
+	fsys := fstest.MapFS{}
+	for name, contents := range b.Files {
+		fsys[name] = &fstest.MapFile{Data: []byte(contents), Mode: 0o444}
+	}
+	return fsys
+}
+
+// Detector returns a [Detector] which replays the bundle. It resolves cgroup
+// interface path and reads interface files from the bundle like the detector
+// did when capturing, thus reproducing its decision. If [Bundle.CgroupPath]
+// is set, it is used instead of resolving cgroup interface path. CPU info
+// reports [Bundle.NumCPU] instead of number of CPUs of the current host.
+// Environment variables recorded in [Bundle.Env] are not applied.
+func (b *Bundle) Detector() (*Detector, error) {
+	if b.CgroupPath != "" {
+		return &Detector{
+			detector: quota.NewFSDetectorWithCgroupPath(b.FS(), b.CgroupPath),
+			numCPU:   b.NumCPU,
+		}, nil
+	}
+
+	d, err := NewDetectorFS(b.FS(), b.PID, WithCgroupFS(b.CgroupFS))
+	if err != nil {
+		return nil, err
+	}
+	d.numCPU = b.NumCPU
+	return d, nil
+}
+
+// WriteJSON writes the bundle as JSON to w.
+func (b *Bundle) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return fmt.Errorf("cgroup: failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// WriteTar writes the bundle as a tar archive to w. Files are written with their
+// paths within the bundle and all other fields are written to bundle.json.
+func (b *Bundle) WriteTar(w io.Writer) error {
+	metadata := *b
+	metadata.Files = nil
+	buf, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cgroup: failed to write bundle: %w", err)
+	}
+
+	names := make([]string, 0, len(b.Files))
+	for name := range b.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tar.NewWriter(w)
+	write := func(name string, data []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o444,
+			Size:     int64(len(data)),
+			ModTime:  b.Time,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+
+	if err = write(bundleMetadataFile, buf); err != nil {
+		return fmt.Errorf("cgroup: failed to write bundle: %w", err)
+	}
+
+	for _, name := range names {
+		if err = write(name, []byte(b.Files[name])); err != nil {
+			return fmt.Errorf("cgroup: failed to write bundle: %w", err)
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return fmt.Errorf("cgroup: failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle reads a bundle written by [Bundle.WriteJSON] or [Bundle.WriteTar].
+// Format is automatically detected.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.Peek(1)
+		if err != nil {
+			return nil, fmt.Errorf("cgroup: failed to read bundle: %w", err)
+		}
+
+		switch c[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = br.ReadByte()
+			continue
+		case '{':
+			return readBundleJSON(br)
+		}
+		return readBundleTar(br)
+	}
+}
+
+// readBundleJSON reads a JSON bundle.
+func readBundleJSON(r io.Reader) (*Bundle, error) {
+	b := &Bundle{}
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, fmt.Errorf("cgroup: failed to read bundle: %w", err)
+	}
+	return b, b.validate()
+}
+
+// readBundleTar reads a tar bundle.
+func readBundleTar(r io.Reader) (*Bundle, error) {
+	var metadata bool
+	b := &Bundle{}
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("cgroup: failed to read bundle: %w", err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Interface files are small, avoid reading large files.
+		var buf bytes.Buffer
+		if _, err = io.Copy(&buf, io.LimitReader(tr, 1<<20)); err != nil {
+			return nil, fmt.Errorf("cgroup: failed to read bundle: %w", err)
+		}
+
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "/")
+		if name == bundleMetadataFile {
+			if err = json.Unmarshal(buf.Bytes(), b); err != nil {
+				return nil, fmt.Errorf("cgroup: failed to read bundle: %s: %w", bundleMetadataFile, err)
+			}
+			metadata = true
+			continue
+		}
+		files[name] = buf.String()
+	}
+
+	if !metadata {
+		return nil, fmt.Errorf("cgroup: failed to read bundle: missing %s", bundleMetadataFile)
+	}
+
+	b.Files = files
+	return b, b.validate()
+}
+
+// validate checks if bundle is supported.
+func (b *Bundle) validate() error {
+	if b.Version != BundleVersion {
+		return fmt.Errorf("cgroup: unsupported bundle version: %d", b.Version)
+	}
+
+	for name := range b.Files {
+		if !fs.ValidPath(name) {
+			return fmt.Errorf("cgroup: invalid path in bundle: %q", name)
+		}
+	}
+
+	if b.CgroupPath != "" && !fs.ValidPath(b.CgroupPath) {
+		return fmt.Errorf("cgroup: invalid cgroup path in bundle: %q", b.CgroupPath)
+	}
+	return nil
+}
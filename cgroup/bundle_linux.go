@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package cgroup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/env"
+	"github.com/tprasadtp/go-autotune/internal/quota"
+)
+
+// Capture captures a support bundle for process with given pid, containing files
+// read by the detector returned by [NewDetector], with same options.
+// If pid is zero or negative, current process is used.
+//
+// For the current process, if neither [WithProcFS] nor [WithCgroupFS] is specified,
+// cgroup interface path is resolved like default detectors, honoring GOAUTOTUNE_PROCFS
+// and GOAUTOTUNE_CGROUP_PATH environment variables. Path to cgroup interface files
+// which were captured is recorded in [Bundle.InterfacePath].
+//
+// Errors encountered reading individual files are recorded in the bundle.
+// An error is only returned if none of the proc files of the process can be read.
+func Capture(pid int, opts ...Option) (*Bundle, error) {
+	cfg := newConfig(opts...)
+	procDir := cfg.procDir(pid)
+
+	self := pid <= 0 && cfg.procfs == "" && cfg.cgroupfs == ""
+	if v := os.Getenv(quota.EnvProcFS); self && v != "" {
+		procDir = v
+	}
+
+	name := "self"
+	if pid > 0 {
+		name = strconv.Itoa(pid)
+	} else {
+		pid = 0
+	}
+
+	b := &Bundle{
+		Version:    BundleVersion,
+		Time:       time.Now().UTC(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		GoVersion:  runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(-1),
+		GOMEMLIMIT: debug.SetMemoryLimit(-1),
+		PID:        pid,
+		Env:        make(map[string]string),
+		Files:      make(map[string]string),
+		Errors:     make(map[string]string),
+	}
+
+	// Proc files are always captured, even if cgroup interface
+	// path cannot be resolved.
+	var ok bool
+	for _, item := range []string{"mountinfo", "cgroup"} {
+		if b.capture(path.Join(procDir, item), path.Join("proc", name, item), false) {
+			ok = true
+		}
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("cgroup: pid %d: failed to read proc files: %s",
+			pid, b.Errors[path.Join("proc", name, "cgroup")])
+	}
+
+	// Environment variables.
+	if pid == 0 {
+		for _, key := range env.Names {
+			if value, set := os.LookupEnv(key); set {
+				b.Env[key] = value
+			}
+		}
+	} else {
+		environ, err := readBundleFile(path.Join(procDir, "environ"))
+		if err != nil {
+			b.Errors["environ"] = err.Error()
+		}
+		for _, item := range strings.Split(environ, "\x00") {
+			key, value, found := strings.Cut(item, "=")
+			for _, candidate := range env.Names {
+				if found && key == candidate {
+					b.Env[key] = value
+				}
+			}
+		}
+	}
+
+	if self {
+		b.captureDefault(procDir)
+		return b, nil
+	}
+
+	detector, err := quota.NewFSDetector(nil, procDir, cfg.cgroupfs)
+	if err != nil {
+		b.Errors["resolve"] = err.Error()
+		return b, nil
+	}
+
+	// Map interface path to a path within the bundle. If cgroupfs is specified, it
+	// is mapped to sys/fs/cgroup. Otherwise, it is relative to root directory of the process.
+	var dir string
+	if cfg.cgroupfs != "" {
+		b.CgroupFS = "sys/fs/cgroup"
+		dir = path.Join(b.CgroupFS, strings.TrimPrefix(detector.InterfacePath(), path.Clean(cfg.cgroupfs)))
+	} else {
+		dir = strings.TrimPrefix(detector.InterfacePath(), path.Join(procDir, "root"))
+	}
+	b.captureInterfaceFiles(detector.InterfacePath(), dir)
+	return b, nil
+}
+
+// captureDefault captures cgroup interface files of the current process, whose
+// path is resolved with [quota.DefaultCgroupInterfacePath], like default detectors.
+func (b *Bundle) captureDefault(procDir string) {
+	interfacePath, err := quota.DefaultCgroupInterfacePath()
+	if err != nil {
+		b.Errors["resolve"] = err.Error()
+		return
+	}
+
+	dir := interfacePath
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	// If interface path is not resolved from proc files, for example when overridden
+	// via GOAUTOTUNE_CGROUP_PATH, record it, as replay cannot resolve it.
+	if resolved, err := quota.GetCgroupInterfacePath(procDir); err != nil || resolved != interfacePath {
+		b.CgroupPath = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	}
+	b.captureInterfaceFiles(interfacePath, dir)
+}
+
+// captureInterfaceFiles captures cgroup interface files in interfacePath
+// to dir within the bundle, and records interfacePath.
+func (b *Bundle) captureInterfaceFiles(interfacePath, dir string) {
+	b.InterfacePath = interfacePath
+	dir = strings.TrimPrefix(path.Clean("/"+dir), "/")
+	for _, item := range quota.InterfaceFiles {
+		b.capture(path.Join(interfacePath, item), path.Join(dir, item), true)
+	}
+}
+
+// capture reads file at src and records it in the bundle at dst. If optional is true,
+// missing files are not recorded as errors. Returns true if file is captured.
+func (b *Bundle) capture(src, dst string, optional bool) bool {
+	contents, err := readBundleFile(src)
+	if err != nil {
+		if !optional || !errors.Is(err, fs.ErrNotExist) {
+			b.Errors[dst] = err.Error()
+		}
+		return false
+	}
+	b.Files[dst] = contents
+	return true
+}
+
+// readBundleFile reads raw contents of a file.
+func readBundleFile(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// mountinfo may be large on hosts with many mounts.
+	var buf bytes.Buffer
+	if _, err = io.Copy(&buf, io.LimitReader(file, 1<<20)); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
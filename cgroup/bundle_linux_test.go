@@ -0,0 +1,243 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package cgroup_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/cgroup"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestCapture(t *testing.T) {
+	const mountinfo = "33 24 0:28 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9" +
+		" - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot\n"
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"proc/1234/mountinfo": mountinfo,
+		"proc/1234/cgroup":    "0::/system.slice/example.service\n",
+		"proc/1234/environ":   "HOME=/root\x00GOMEMLIMIT=100MiB\x00GOMAXPROCS=2\x00GOAUTOTUNE_SHARE=procs\x00",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/cpu.max":               "150000 100000\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/cpu.max.burst":         "20000\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/cpu.weight":            "59\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/cpuset.cpus.effective": "0-3\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.max":            "314572800\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.high":           "262144000\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.low":            "52428800\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.min":            "1048576\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.swap.max":       "0\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.current":        "104857600\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.peak":           "157286400\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/cgroup.procs":          "1234\n1235\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.stat": "anon 52428800\n" +
+			"file 41943040\nkernel 8388608\nsock 1048576\n",
+		"host/sys/fs/cgroup/system.slice/example.service/cpu.max":    "50000 100000\n",
+		"host/sys/fs/cgroup/system.slice/example.service/memory.max": "max\n",
+		"proc/4321/mountinfo": "6 5 0:5 / /sys/fs/cgroup/cpuset rw,nosuid,nodev,noexec,relatime shared:6" +
+			" - cgroup cgroup rw,cpuset\n",
+		"proc/4321/cgroup": "1:cpuset:/\n",
+	})
+
+	tt := []struct {
+		name     string
+		pid      int
+		cgroupfs string
+		file     string
+		err      error
+	}{
+		{
+			name: "ProcRoot",
+			pid:  1234,
+			file: "sys/fs/cgroup/system.slice/example.service/cpu.max",
+		},
+		{
+			name:     "CgroupFS",
+			pid:      1234,
+			cgroupfs: filepath.Join(dir, "host", "sys", "fs", "cgroup"),
+			file:     "sys/fs/cgroup/system.slice/example.service/cpu.max",
+		},
+		{
+			name: "CgroupV1",
+			pid:  4321,
+			err:  cgroup.ErrCgroupV1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []cgroup.Option{
+				cgroup.WithProcFS(filepath.Join(dir, "proc")),
+				cgroup.WithCgroupFS(tc.cgroupfs),
+			}
+
+			bundle, err := cgroup.Capture(tc.pid, opts...)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if bundle.Env["GOMEMLIMIT"] != "100MiB" && tc.pid == 1234 {
+				t.Errorf("expected GOMEMLIMIT to be captured, got %v", bundle.Env)
+			}
+
+			if bundle.Env["GOAUTOTUNE_SHARE"] != "procs" && tc.pid == 1234 {
+				t.Errorf("expected GOAUTOTUNE_SHARE to be captured, got %v", bundle.Env)
+			}
+
+			if _, ok := bundle.Env["HOME"]; ok {
+				t.Errorf("unexpected environment variable HOME in bundle")
+			}
+
+			if tc.file != "" {
+				if _, ok := bundle.Files[tc.file]; !ok {
+					t.Errorf("expected %s in bundle, got %v", tc.file, bundle.Files)
+				}
+			}
+
+			// Decision of the original detector.
+			var expectCPU float64
+			var expectMax, expectHigh int64
+			var expectCPUInfo maxprocs.CPUInfo
+			var expectMemoryInfo memlimit.MemoryInfo
+			var expectProcs int
+			original, expectErr := cgroup.NewDetector(tc.pid, opts...)
+			if expectErr == nil {
+				expectCPU, _ = original.DetectCPUQuota(context.Background())
+				expectMax, expectHigh, _ = original.DetectMemoryQuota(context.Background())
+				expectCPUInfo, _ = original.DetectCPUInfo(context.Background())
+				expectMemoryInfo, _ = original.DetectMemoryInfo(context.Background())
+				expectProcs, _ = original.DetectProcs(context.Background())
+			}
+
+			for _, format := range []string{"json", "tar"} {
+				t.Run(format, func(t *testing.T) {
+					var buf bytes.Buffer
+					if format == "json" {
+						err = bundle.WriteJSON(&buf)
+					} else {
+						err = bundle.WriteTar(&buf)
+					}
+					if err != nil {
+						t.Fatalf("failed to write bundle: %s", err)
+					}
+
+					replay, err := cgroup.ReadBundle(&buf)
+					if err != nil {
+						t.Fatalf("failed to read bundle: %s", err)
+					}
+
+					detector, err := replay.Detector()
+					if tc.err != nil {
+						if !errors.Is(err, tc.err) || !errors.Is(expectErr, tc.err) {
+							t.Errorf("expected error matching %q, got %v and %v", tc.err, err, expectErr)
+						}
+						return
+					}
+
+					if err != nil {
+						t.Fatalf("failed to create replay detector: %s", err)
+					}
+
+					cpu, err := detector.DetectCPUQuota(context.Background())
+					if err != nil || cpu != expectCPU {
+						t.Errorf("cpu expected=%f, got=%f (err=%v)", expectCPU, cpu, err)
+					}
+
+					max, high, err := detector.DetectMemoryQuota(context.Background())
+					if err != nil || max != expectMax || high != expectHigh {
+						t.Errorf("memory expected=(%d,%d), got=(%d,%d) (err=%v)",
+							expectMax, expectHigh, max, high, err)
+					}
+
+					cpuInfo, err := detector.DetectCPUInfo(context.Background())
+					if err != nil || cpuInfo != expectCPUInfo {
+						t.Errorf("cpu info expected=%+v, got=%+v (err=%v)", expectCPUInfo, cpuInfo, err)
+					}
+
+					memoryInfo, err := detector.DetectMemoryInfo(context.Background())
+					if err != nil || memoryInfo != expectMemoryInfo {
+						t.Errorf("memory info expected=%+v, got=%+v (err=%v)", expectMemoryInfo, memoryInfo, err)
+					}
+
+					procs, err := detector.DetectProcs(context.Background())
+					if err != nil || procs != expectProcs {
+						t.Errorf("procs expected=%d, got=%d (err=%v)", expectProcs, procs, err)
+					}
+				})
+			}
+		})
+	}
+
+	t.Run("NumCPU", func(t *testing.T) {
+		bundle, err := cgroup.Capture(1234, cgroup.WithProcFS(filepath.Join(dir, "proc")))
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		// Replay uses number of CPUs of the host which captured the bundle.
+		bundle.NumCPU = runtime.NumCPU() + 7
+		detector, err := bundle.Detector()
+		if err != nil {
+			t.Fatalf("failed to create replay detector: %s", err)
+		}
+
+		info, err := detector.DetectCPUInfo(context.Background())
+		if err != nil || info.NumCPU != bundle.NumCPU {
+			t.Errorf("expected NumCPU=%d, got=%+v (err=%v)", bundle.NumCPU, info, err)
+		}
+	})
+
+	t.Run("MissingProcess", func(t *testing.T) {
+		_, err := cgroup.Capture(9999, cgroup.WithProcFS(filepath.Join(dir, "proc")))
+		if err == nil {
+			t.Errorf("expected an error, got nil")
+		}
+	})
+
+	t.Run("SelfCgroupPathEnv", func(t *testing.T) {
+		interfacePath := filepath.Join(dir, "host", "sys", "fs", "cgroup", "system.slice", "example.service")
+		t.Setenv("GOAUTOTUNE_CGROUP_PATH", interfacePath)
+		bundle, err := cgroup.Capture(0)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if bundle.InterfacePath != interfacePath {
+			t.Errorf("expected interface path %q, got %q", interfacePath, bundle.InterfacePath)
+		}
+
+		if bundle.CgroupPath == "" {
+			t.Fatalf("expected cgroup path to be recorded")
+		}
+
+		detector, err := bundle.Detector()
+		if err != nil {
+			t.Fatalf("failed to create replay detector: %s", err)
+		}
+
+		cpu, err := detector.DetectCPUQuota(context.Background())
+		if err != nil || cpu != 0.5 {
+			t.Errorf("cpu expected=0.5, got=%f (err=%v)", cpu, err)
+		}
+	})
+
+	t.Run("Self", func(t *testing.T) {
+		bundle, err := cgroup.Capture(0)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if _, ok := bundle.Files["proc/self/mountinfo"]; !ok {
+			t.Errorf("expected proc/self/mountinfo in bundle")
+		}
+	})
+}
@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Capture captures a support bundle for process with given pid.
+// This always returns an error wrapping [errors.ErrUnsupported]
+// on this platform. Bundles captured on Linux can be replayed with
+// [Bundle.Detector] on all platforms.
+func Capture(pid int, _ ...Option) (*Bundle, error) {
+	return nil, fmt.Errorf("cgroup: pid %d: %w", pid, errors.ErrUnsupported)
+}
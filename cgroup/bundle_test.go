@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package cgroup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/cgroup"
+)
+
+func TestReadBundle(t *testing.T) {
+	tt := []struct {
+		name   string
+		bundle string
+		ok     bool
+	}{
+		{
+			name: "Valid",
+			bundle: `
+			{
+				"version": 1,
+				"files": {
+					"proc/self/mountinfo": "33 24 0:28 / /sys/fs/cgroup rw - cgroup2 cgroup2 rw\n",
+					"proc/self/cgroup": "0::/\n",
+					"sys/fs/cgroup/cpu.max": "200000 100000\n"
+				}
+			}`,
+			ok: true,
+		},
+		{
+			name:   "UnsupportedVersion",
+			bundle: `{"version": 99}`,
+		},
+		{
+			name:   "InvalidPath",
+			bundle: `{"version": 1, "files": {"../etc/passwd": ""}}`,
+		},
+		{
+			name:   "InvalidJSON",
+			bundle: `{"version":`,
+		},
+		{
+			name: "Empty",
+		},
+		{
+			name: "TarMissingMetadata",
+			bundle: func() string {
+				var buf bytes.Buffer
+				tw := tar.NewWriter(&buf)
+				_ = tw.WriteHeader(&tar.Header{Name: "proc/self/cgroup", Mode: 0o444, Size: 4})
+				_, _ = tw.Write([]byte("0::/"))
+				_ = tw.Close()
+				return buf.String()
+			}(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			bundle, err := cgroup.ReadBundle(strings.NewReader(tc.bundle))
+			if !tc.ok {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			detector, err := bundle.Detector()
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			cpu, err := detector.DetectCPUQuota(context.Background())
+			if err != nil || cpu != 2 {
+				t.Errorf("expected cpu=2, got=%f (err=%v)", cpu, err)
+			}
+		})
+	}
+}
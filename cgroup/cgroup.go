@@ -0,0 +1,231 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+// Package cgroup provides CPU and memory quota detectors for arbitrary processes,
+// based on cgroup v2 interface files.
+//
+// Detectors returned by [NewDetector] implement both
+// [github.com/tprasadtp/go-autotune/maxprocs.CPUQuotaDetector] and
+// [github.com/tprasadtp/go-autotune/memlimit.MemoryQuotaDetector], thus can be used
+// with [github.com/tprasadtp/go-autotune/maxprocs.Compute] and
+// [github.com/tprasadtp/go-autotune/memlimit.Compute] to determine GOMAXPROCS and
+// GOMEMLIMIT a process should have. This is typically useful for node agents and
+// sidecars.
+//
+// For Kubernetes DaemonSets, host's procfs and cgroupfs are typically mounted within
+// the container. Use [WithProcFS] and [WithCgroupFS] to specify their locations.
+//
+//	detector, err := cgroup.NewDetector(pid,
+//		cgroup.WithProcFS("/host/proc"),
+//		cgroup.WithCgroupFS("/host/sys/fs/cgroup"),
+//	)
+//
+// [NewDetectorFS] reads files from a [fs.FS] instead, like [testing/fstest.MapFS] or
+// files of /proc and /sys/fs/cgroup captured from another host. This allows
+// replaying support cases offline and is supported on all platforms.
+//
+// [NewDetector] is only supported on Linux. On other platforms, it returns
+// an error wrapping [errors.ErrUnsupported].
+package cgroup
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"runtime"
+	"strconv"
+
+	"github.com/tprasadtp/go-autotune/internal/quota"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+var (
+	_ maxprocs.CPUQuotaDetector    = (*Detector)(nil)
+	_ maxprocs.CPUInfoDetector     = (*Detector)(nil)
+	_ memlimit.MemoryQuotaDetector = (*Detector)(nil)
+	_ memlimit.MemoryInfoDetector  = (*Detector)(nil)
+)
+
+type config struct {
+	procfs   string
+	cgroupfs string
+}
+
+// Option to apply while creating a [Detector].
+type Option interface {
+	apply(c *config)
+}
+
+type optionFunc struct {
+	fn func(*config)
+}
+
+func (opt *optionFunc) apply(f *config) {
+	opt.fn(f)
+}
+
+// WithProcFS configures mount point of procfs. If not specified, /proc is used.
+// This is useful when host's procfs is mounted within a container,
+// for example at /host/proc.
+func WithProcFS(root string) Option {
+	if root != "" {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.procfs = root
+			},
+		}
+	}
+	return nil
+}
+
+// WithCgroupFS configures mount point of cgroup2 hierarchy root, for example
+// host's /sys/fs/cgroup mounted at /host/sys/fs/cgroup. If specified, cgroup of the
+// process is resolved relative to it. Otherwise, cgroup2 mount point is obtained
+// from mountinfo of the process and is accessed via its root directory
+// (/proc/<pid>/root).
+//
+// cgroup of the process must be within cgroup namespace of the current process.
+// Containers typically have their own cgroup namespace, thus when inspecting
+// processes outside of it, run the agent in host's cgroup namespace.
+func WithCgroupFS(root string) Option {
+	if root != "" {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.cgroupfs = root
+			},
+		}
+	}
+	return nil
+}
+
+// newConfig builds config from options.
+func newConfig(opts ...Option) *config {
+	cfg := &config{}
+	for i := range opts {
+		if opts[i] != nil {
+			opts[i].apply(cfg)
+		}
+	}
+	return cfg
+}
+
+// procDir returns procfs directory for the pid.
+func (c *config) procDir(pid int) string {
+	procfs := c.procfs
+	if procfs == "" {
+		procfs = "/proc"
+	}
+
+	if pid <= 0 {
+		return path.Join(procfs, "self")
+	}
+	return path.Join(procfs, strconv.Itoa(pid))
+}
+
+// Detector detects CPU and memory quota of a process.
+type Detector struct {
+	detector *quota.FSDetector
+
+	// Number of CPUs reported by DetectCPUInfo. If zero,
+	// runtime.NumCPU is used.
+	numCPU int
+}
+
+// NewDetectorFS returns a [Detector] for process with given pid, which reads
+// files from fsys. fsys must contain files with same layout as root filesystem of
+// a Linux host, for example proc/1234/mountinfo, proc/1234/cgroup and
+// sys/fs/cgroup/system.slice/example.service/cpu.max. If pid is zero or negative,
+// proc/self is used.
+//
+// [WithProcFS] and [WithCgroupFS] are interpreted relative to root of fsys.
+// If [WithCgroupFS] is not specified, cgroup2 mount point of the process
+// is resolved relative to root of fsys.
+func NewDetectorFS(fsys fs.FS, pid int, opts ...Option) (*Detector, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("cgroup: pid %d: fsys is nil", pid)
+	}
+
+	cfg := newConfig(opts...)
+	detector, err := quota.NewFSDetector(fsys, cfg.procDir(pid), cfg.cgroupfs)
+	if err != nil {
+		return nil, fmt.Errorf("cgroup: pid %d: %w", pid, err)
+	}
+	return &Detector{detector: detector}, nil
+}
+
+// InterfacePath returns path to cgroup interface files of the process.
+func (d *Detector) InterfacePath() string {
+	return d.detector.InterfacePath()
+}
+
+// DetectCPUQuota returns CPU quota of the process. Zero is returned
+// if CPU quota is not defined.
+func (d *Detector) DetectCPUQuota(ctx context.Context) (float64, error) {
+	return d.detector.DetectCPUQuota(ctx)
+}
+
+// DetectMemoryQuota returns memory.max and memory.high values of the process.
+// Zero is returned if respective limits are not defined.
+//
+//nolint:nonamedreturns // for docs.
+func (d *Detector) DetectMemoryQuota(ctx context.Context) (max, high int64, err error) {
+	return d.detector.DetectMemoryQuota(ctx)
+}
+
+// DetectCPUInfo returns CPU limits from cpu.max, cpu.max.burst, cpu.weight and
+// cpuset.cpus.effective interface files. Missing interface files are ignored.
+// [maxprocs.CPUInfo.NumCPU] is of the current process, unless replaying a [Bundle].
+func (d *Detector) DetectCPUInfo(ctx context.Context) (maxprocs.CPUInfo, error) {
+	info, err := d.detector.DetectCPUInfo(ctx)
+	if err != nil {
+		return maxprocs.CPUInfo{}, err
+	}
+
+	numCPU := d.numCPU
+	if numCPU <= 0 {
+		numCPU = runtime.NumCPU()
+	}
+	return maxprocs.CPUInfo{
+		Quota:  info.Quota,
+		Period: info.Period,
+		Burst:  info.Burst,
+		CPUSet: info.CPUSet,
+		Weight: info.Weight,
+		NumCPU: numCPU,
+		Source: info.Source,
+	}, nil
+}
+
+// DetectMemoryInfo returns memory limits and usage from memory.max, memory.high,
+// memory.low, memory.min, memory.swap.max, memory.current, memory.peak and
+// memory.stat interface files. Missing interface files are ignored.
+func (d *Detector) DetectMemoryInfo(ctx context.Context) (memlimit.MemoryInfo, error) {
+	info, err := d.detector.DetectMemoryInfo(ctx)
+	if err != nil {
+		return memlimit.MemoryInfo{}, err
+	}
+	return memlimit.MemoryInfo{
+		Max:     info.Max,
+		High:    info.High,
+		Low:     info.Low,
+		Min:     info.Min,
+		SwapMax: info.SwapMax,
+		Current: info.Current,
+		Peak:    info.Peak,
+		Anon:    info.Anon,
+		File:    info.File,
+		Kernel:  info.Kernel,
+		Sock:    info.Sock,
+		Source:  info.Source,
+	}, nil
+}
+
+// DetectProcs returns number of processes in the cgroup, from cgroup.procs
+// interface file. Zero is returned if it does not exist. This is only used
+// by [maxprocs.WithProcessShare], [memlimit.WithProcessShare] and
+// [memlimit.WithExcludeOthers].
+func (d *Detector) DetectProcs(ctx context.Context) (int, error) {
+	return d.detector.DetectProcs(ctx)
+}
@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package cgroup
+
+import (
+	"fmt"
+
+	"github.com/tprasadtp/go-autotune/internal/quota"
+)
+
+// NewDetector returns a [Detector] for process with given pid. If pid is zero
+// or negative, current process is used. cgroup interface path is resolved
+// when creating the detector. If the process is moved to a different cgroup,
+// a new detector must be created.
+func NewDetector(pid int, opts ...Option) (*Detector, error) {
+	cfg := newConfig(opts...)
+	detector, err := quota.NewFSDetector(nil, cfg.procDir(pid), cfg.cgroupfs)
+	if err != nil {
+		return nil, fmt.Errorf("cgroup: pid %d: %w", pid, err)
+	}
+	return &Detector{detector: detector}, nil
+}
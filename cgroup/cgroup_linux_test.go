@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package cgroup_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/cgroup"
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+// writeFiles writes files relative to root.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+}
+
+func TestNewDetector(t *testing.T) {
+	const mountinfo = "33 24 0:28 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9" +
+		" - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot\n"
+
+	tt := []struct {
+		name     string
+		pid      int
+		files    map[string]string
+		cgroupfs string
+		path     string
+		cpu      float64
+		max      int64
+		high     int64
+		err      bool
+	}{
+		{
+			name: "ProcRoot",
+			pid:  1234,
+			files: map[string]string{
+				"proc/1234/mountinfo": mountinfo,
+				"proc/1234/cgroup":    "0::/system.slice/example.service\n",
+				"proc/1234/root/sys/fs/cgroup/system.slice/example.service/cpu.max":     "150000 100000\n",
+				"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.max":  "262144000\n",
+				"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.high": "max\n",
+			},
+			path: "proc/1234/root/sys/fs/cgroup/system.slice/example.service",
+			cpu:  1.5,
+			max:  250 * shared.MiByte,
+		},
+		{
+			name:     "HostCgroupFS",
+			pid:      1234,
+			cgroupfs: "host/sys/fs/cgroup",
+			files: map[string]string{
+				"proc/1234/mountinfo": mountinfo,
+				"proc/1234/cgroup":    "0::/kubepods.slice/kubepods-pod1.slice/cri-containerd-1.scope\n",
+				"host/sys/fs/cgroup/kubepods.slice/kubepods-pod1.slice/cri-containerd-1.scope/cpu.max":     "50000 100000\n",
+				"host/sys/fs/cgroup/kubepods.slice/kubepods-pod1.slice/cri-containerd-1.scope/memory.max":  "max\n",
+				"host/sys/fs/cgroup/kubepods.slice/kubepods-pod1.slice/cri-containerd-1.scope/memory.high": "209715200\n",
+			},
+			path: "host/sys/fs/cgroup/kubepods.slice/kubepods-pod1.slice/cri-containerd-1.scope",
+			cpu:  0.5,
+			high: 200 * shared.MiByte,
+		},
+		{
+			name:     "OutsideCgroupNamespace",
+			pid:      1234,
+			cgroupfs: "host/sys/fs/cgroup",
+			files: map[string]string{
+				"proc/1234/mountinfo": mountinfo,
+				"proc/1234/cgroup":    "0::/../../kubepods.slice\n",
+			},
+			err: true,
+		},
+		{
+			name: "MissingProcess",
+			pid:  1234,
+			err:  true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+
+			var cgroupfs string
+			if tc.cgroupfs != "" {
+				cgroupfs = filepath.Join(dir, tc.cgroupfs)
+			}
+
+			detector, err := cgroup.NewDetector(tc.pid,
+				cgroup.WithProcFS(filepath.Join(dir, "proc")),
+				cgroup.WithCgroupFS(cgroupfs),
+			)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if v := detector.InterfacePath(); v != filepath.Join(dir, tc.path) {
+				t.Errorf("InterfacePath expected=%s, got=%s", filepath.Join(dir, tc.path), v)
+			}
+
+			cpu, err := detector.DetectCPUQuota(context.Background())
+			if err != nil {
+				t.Errorf("DetectCPUQuota expected no error, got %s", err)
+			}
+			if cpu != tc.cpu {
+				t.Errorf("DetectCPUQuota expected=%f, got=%f", tc.cpu, cpu)
+			}
+
+			max, high, err := detector.DetectMemoryQuota(context.Background())
+			if err != nil {
+				t.Errorf("DetectMemoryQuota expected no error, got %s", err)
+			}
+			if max != tc.max || high != tc.high {
+				t.Errorf("DetectMemoryQuota expected=(%d,%d), got=(%d,%d)", tc.max, tc.high, max, high)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	const mountinfo = "33 24 0:28 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9" +
+		" - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot\n"
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"proc/1234/mountinfo": mountinfo,
+		"proc/1234/cgroup":    "0::/system.slice/example.service\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/cpu.max":    "foo 100000\n",
+		"proc/1234/root/sys/fs/cgroup/system.slice/example.service/memory.max": "-1\n",
+		"proc/4321/mountinfo": "6 5 0:5 / /sys/fs/cgroup/cpuset rw,nosuid,nodev,noexec,relatime shared:6" +
+			" - cgroup cgroup rw,cpuset\n",
+		"proc/4321/cgroup": "1:cpuset:/\n",
+	})
+
+	t.Run("CgroupV1", func(t *testing.T) {
+		_, err := cgroup.NewDetector(4321, cgroup.WithProcFS(filepath.Join(dir, "proc")))
+		if !errors.Is(err, cgroup.ErrCgroupV1) {
+			t.Errorf("expected ErrCgroupV1, got %v", err)
+		}
+	})
+
+	detector, err := cgroup.NewDetector(1234, cgroup.WithProcFS(filepath.Join(dir, "proc")))
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	t.Run("MaxProcs", func(t *testing.T) {
+		t.Setenv("GOMAXPROCS", "")
+		err := maxprocs.Configure(context.Background(), maxprocs.WithCPUQuotaDetector(detector))
+		if !errors.Is(err, cgroup.ErrMalformed) {
+			t.Errorf("expected ErrMalformed, got %v", err)
+		}
+
+		var perr *cgroup.ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("expected ParseError, got %v", err)
+		}
+
+		if filepath.Base(perr.Path) != "cpu.max" || perr.Content != "foo 100000" {
+			t.Errorf("unexpected ParseError path=%q content=%q", perr.Path, perr.Content)
+		}
+	})
+
+	t.Run("MemLimit", func(t *testing.T) {
+		t.Setenv("GOMEMLIMIT", "")
+		err := memlimit.Configure(context.Background(), memlimit.WithMemoryQuotaDetector(detector))
+		var perr *cgroup.ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("expected ParseError, got %v", err)
+		}
+
+		if filepath.Base(perr.Path) != "memory.max" || perr.Content != "-1" {
+			t.Errorf("unexpected ParseError path=%q content=%q", perr.Path, perr.Content)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NewDetector returns a [Detector] for process with given pid.
+// This always returns an error wrapping [errors.ErrUnsupported]
+// on this platform. Use [NewDetectorFS] to read files captured
+// from a Linux host.
+func NewDetector(pid int, _ ...Option) (*Detector, error) {
+	return nil, fmt.Errorf("cgroup: pid %d: %w", pid, errors.ErrUnsupported)
+}
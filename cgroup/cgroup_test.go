@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package cgroup_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/cgroup"
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestNewDetectorFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"proc/1234/mountinfo": {
+			Data: []byte("33 24 0:28 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9" +
+				" - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot\n"),
+		},
+		"proc/1234/cgroup": {Data: []byte("0::/system.slice/example.service\n")},
+		"sys/fs/cgroup/system.slice/example.service/cpu.max":        {Data: []byte("50000 100000\n")},
+		"sys/fs/cgroup/system.slice/example.service/memory.max":     {Data: []byte("262144000\n")},
+		"sys/fs/cgroup/system.slice/example.service/memory.high":    {Data: []byte("max\n")},
+		"sys/fs/cgroup/system.slice/example.service/memory.current": {Data: []byte("104857600\n")},
+	}
+
+	t.Run("Nil", func(t *testing.T) {
+		_, err := cgroup.NewDetectorFS(nil, 1234)
+		if err == nil {
+			t.Errorf("expected an error, got nil")
+		}
+	})
+
+	t.Run("MissingProcess", func(t *testing.T) {
+		_, err := cgroup.NewDetectorFS(fsys, 4321)
+		if err == nil {
+			t.Errorf("expected an error, got nil")
+		}
+	})
+
+	t.Run("Compute", func(t *testing.T) {
+		t.Setenv("GOMAXPROCS", "")
+		t.Setenv("GOMEMLIMIT", "")
+
+		detector, err := cgroup.NewDetectorFS(fsys, 1234)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		procs, err := maxprocs.Compute(context.Background(), maxprocs.WithCPUQuotaDetector(detector))
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		if procs.Quota != 0.5 || procs.Value != 1 {
+			t.Errorf("unexpected GOMAXPROCS result: %+v", procs)
+		}
+
+		limit, err := memlimit.Compute(context.Background(), memlimit.WithMemoryQuotaDetector(detector))
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		if limit.Max != 250*shared.MiByte {
+			t.Errorf("unexpected GOMEMLIMIT result: %+v", limit)
+		}
+
+		cpu, err := detector.DetectCPUInfo(context.Background())
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		if cpu.Quota != 0.5 || cpu.Period != 100*time.Millisecond || cpu.Source != "cgroup" {
+			t.Errorf("unexpected cpu info: %+v", cpu)
+		}
+
+		info, err := detector.DetectMemoryInfo(context.Background())
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+		if info.Max != 250*shared.MiByte || info.Current != 100*shared.MiByte || info.Source != "cgroup" {
+			t.Errorf("unexpected memory info: %+v", info)
+		}
+	})
+}
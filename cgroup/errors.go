@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package cgroup
+
+import (
+	"github.com/tprasadtp/go-autotune/internal/quota"
+)
+
+// Errors returned by detectors, including the default detectors used by
+// [github.com/tprasadtp/go-autotune/maxprocs.Configure] and
+// [github.com/tprasadtp/go-autotune/memlimit.Configure]. Use [errors.Is] to check
+// for them. Permission errors can be checked with [io/fs.ErrPermission].
+//
+//nolint:gochecknoglobals // sentinel errors.
+var (
+	// ErrNoCgroup2Mount is returned when cgroup2 filesystem is not mounted.
+	ErrNoCgroup2Mount = quota.ErrNoCgroup2Mount
+
+	// ErrCgroupV1 is returned when only cgroup v1 hierarchies are available.
+	// cgroup v1 is not supported.
+	ErrCgroupV1 = quota.ErrCgroupV1
+
+	// ErrMalformed is returned when mountinfo, cgroup or interface files
+	// have invalid format. Errors matching it are always of type [*ParseError].
+	ErrMalformed = quota.ErrMalformed
+)
+
+// ParseError is returned when mountinfo, cgroup or interface files cannot be parsed.
+// It contains path of the file and offending content. Use [errors.As] to check for it.
+type ParseError = quota.ParseError
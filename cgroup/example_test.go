@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package cgroup_test
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/tprasadtp/go-autotune/cgroup"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func ExampleNewDetector() {
+	// Host's procfs and cgroupfs are mounted at /host/proc and
+	// /host/sys/fs/cgroup respectively.
+	detector, err := cgroup.NewDetector(1234,
+		cgroup.WithProcFS("/host/proc"),
+		cgroup.WithCgroupFS("/host/sys/fs/cgroup"),
+	)
+	if err != nil {
+		slog.Error("Failed to create detector", slog.Any("err", err))
+		return
+	}
+
+	procs, err := maxprocs.Compute(context.Background(), maxprocs.WithCPUQuotaDetector(detector))
+	if err != nil {
+		slog.Error("Failed to compute GOMAXPROCS", slog.Any("err", err))
+		return
+	}
+
+	limit, err := memlimit.Compute(context.Background(), memlimit.WithMemoryQuotaDetector(detector))
+	if err != nil {
+		slog.Error("Failed to compute GOMEMLIMIT", slog.Any("err", err))
+		return
+	}
+
+	slog.Info("Computed",
+		slog.Int("pid", 1234),
+		slog.Int("GOMAXPROCS", procs.Value),
+		slog.Int64("GOMEMLIMIT", limit.Value),
+	)
+}
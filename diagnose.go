@@ -0,0 +1,264 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package autotune
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tprasadtp/go-autotune/internal/autotune"
+	"github.com/tprasadtp/go-autotune/internal/env"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+// Diagnosis describes each step taken to determine GOMAXPROCS and GOMEMLIMIT.
+// It can be encoded as JSON, or as text via [Diagnosis.String].
+type Diagnosis struct {
+	// Operating system.
+	GOOS string `json:"goos"`
+
+	// Architecture.
+	GOARCH string `json:"goarch"`
+
+	// Go version used to build the binary.
+	GoVersion string `json:"go_version"`
+
+	// Number of logical CPUs usable by the process.
+	NumCPU int `json:"num_cpu"`
+
+	// True if automatic configuration is disabled via environment variables.
+	Disabled bool `json:"disabled"`
+
+	// Environment variables considered.
+	Env []EnvVar `json:"env"`
+
+	// Error parsing environment variables, if any.
+	EnvError string `json:"env_error,omitempty"`
+
+	// cgroup interface path resolution and interface files. This is only
+	// populated on Linux.
+	Cgroup *CgroupDiagnosis `json:"cgroup,omitempty"`
+
+	// GOMAXPROCS diagnosis.
+	MaxProcs MaxProcsDiagnosis `json:"maxprocs"`
+
+	// GOMEMLIMIT diagnosis.
+	MemLimit MemLimitDiagnosis `json:"memlimit"`
+}
+
+// EnvVar is an environment variable considered by [Diagnose].
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Set   bool   `json:"set"`
+}
+
+// CgroupDiagnosis describes how cgroup interface path is resolved
+// and raw contents of interface files.
+type CgroupDiagnosis struct {
+	// Path to mountinfo file.
+	MountInfoPath string `json:"mountinfo_path"`
+
+	// Line in mountinfo file which matched cgroup2 mount.
+	MountInfoLine string `json:"mountinfo_line,omitempty"`
+
+	// cgroup2 mount point.
+	MountPoint string `json:"mount_point,omitempty"`
+
+	// Error parsing mountinfo file.
+	MountInfoError string `json:"mountinfo_error,omitempty"`
+
+	// Path to cgroup file.
+	CgroupPath string `json:"cgroup_path"`
+
+	// Name of the cgroup.
+	CgroupName string `json:"cgroup_name,omitempty"`
+
+	// Error parsing cgroup file.
+	CgroupError string `json:"cgroup_error,omitempty"`
+
+	// Resolved path to cgroup interface files.
+	InterfacePath string `json:"interface_path,omitempty"`
+
+	// How cgroup interface path was resolved. This is GOAUTOTUNE_CGROUP_PATH if it
+	// was overridden by the environment variable, "procfs" if it was resolved from
+	// mountinfo and cgroup files, or "cgroupns" if /sys/fs/cgroup was used.
+	InterfaceSource string `json:"interface_source,omitempty"`
+
+	// Error resolving cgroup interface path.
+	InterfaceError string `json:"interface_error,omitempty"`
+
+	// Interface files.
+	Files []FileDiagnosis `json:"files,omitempty"`
+}
+
+// FileDiagnosis describes a cgroup interface file.
+type FileDiagnosis struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Contents string `json:"contents,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MaxProcsDiagnosis describes how GOMAXPROCS is determined.
+type MaxProcsDiagnosis struct {
+	// Result computed by [github.com/tprasadtp/go-autotune/maxprocs.Compute].
+	// Result.Previous is current GOMAXPROCS, Result.Quota is CPU quota before
+	// rounding, and Result.Value is GOMAXPROCS after rounding.
+	Result maxprocs.Result `json:"result"`
+
+	// Error encountered, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// MemLimitDiagnosis describes how GOMEMLIMIT is determined.
+type MemLimitDiagnosis struct {
+	// Result computed by [github.com/tprasadtp/go-autotune/memlimit.Compute].
+	// Result.Previous is current GOMEMLIMIT, Result.Reserve is the memory
+	// reserved from hard limit, and Result.Value is the GOMEMLIMIT.
+	Result memlimit.Result `json:"result"`
+
+	// Error encountered, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Diagnose explains how GOMAXPROCS and GOMEMLIMIT are determined with the given
+// options, without modifying them. Options and environment variables, like
+// GOAUTOTUNE_MEMLIMIT_RESERVE and GOAUTOTUNE_SHARE, are applied like [Configure]. Errors encountered
+// at each step are recorded in the returned [Diagnosis] and are also returned
+// joined together with [errors.Join].
+func Diagnose(ctx context.Context, opts ...Option) (Diagnosis, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cfg := newConfig(opts...)
+	d := Diagnosis{
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		NumCPU:    runtime.NumCPU(),
+		Disabled:  env.IsFalse(env.AutotuneLegacy) || env.IsFalse(env.Autotune),
+	}
+
+	for _, name := range env.Names {
+		value, ok := os.LookupEnv(name)
+		d.Env = append(d.Env, EnvVar{Name: name, Value: value, Set: ok})
+	}
+
+	// Settings specified by environment variables are applied like [Configure].
+	run := cfg.runConfig()
+	errEnv := autotune.ApplyEnv(ctx, &run)
+	if errEnv != nil {
+		d.EnvError = errEnv.Error()
+	}
+
+	// Platform specific diagnosis. This returns detectors,
+	// which re-use resolved cgroup interface path on Linux.
+	cpu, mem := diagnose(&d)
+	if run.CPUQuotaDetector == nil {
+		run.CPUQuotaDetector = cpu
+	}
+	if run.MemoryQuotaDetector == nil {
+		run.MemoryQuotaDetector = mem
+	}
+
+	var errMaxProcs, errMemLimit error
+	d.MaxProcs.Result, errMaxProcs = maxprocs.Compute(ctx, autotune.MaxProcsOptions(run)...)
+	if errMaxProcs != nil {
+		d.MaxProcs.Error = errMaxProcs.Error()
+	}
+
+	d.MemLimit.Result, errMemLimit = memlimit.Compute(ctx, autotune.MemLimitOptions(run)...)
+	if errMemLimit != nil {
+		d.MemLimit.Error = errMemLimit.Error()
+	}
+
+	return d, errors.Join(errEnv, errMaxProcs, errMemLimit)
+}
+
+// String returns human readable representation of [Diagnosis].
+// Its format is not covered by compatibility guarantees.
+func (d Diagnosis) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Platform       : %s/%s\n", d.GOOS, d.GOARCH)
+	fmt.Fprintf(&b, "Go Version     : %s\n", d.GoVersion)
+	fmt.Fprintf(&b, "NumCPU         : %d\n", d.NumCPU)
+	fmt.Fprintf(&b, "Disabled       : %t\n", d.Disabled)
+
+	b.WriteString("Environment    :\n")
+	for _, item := range d.Env {
+		if item.Set {
+			fmt.Fprintf(&b, "  %-27s : %q\n", item.Name, item.Value)
+		} else {
+			fmt.Fprintf(&b, "  %-27s : (unset)\n", item.Name)
+		}
+	}
+	if d.EnvError != "" {
+		fmt.Fprintf(&b, "  %-27s : %s\n", "error", d.EnvError)
+	}
+
+	if d.Cgroup != nil {
+		b.WriteString("cgroup         :\n")
+		fmt.Fprintf(&b, "  %-12s : %s\n", "mountinfo", d.Cgroup.MountInfoPath)
+		if d.Cgroup.MountInfoError != "" {
+			fmt.Fprintf(&b, "  %-12s : %s\n", "error", d.Cgroup.MountInfoError)
+		} else {
+			fmt.Fprintf(&b, "  %-12s : %s\n", "matched", d.Cgroup.MountInfoLine)
+			fmt.Fprintf(&b, "  %-12s : %s\n", "mount point", d.Cgroup.MountPoint)
+		}
+		fmt.Fprintf(&b, "  %-12s : %s\n", "cgroup", d.Cgroup.CgroupPath)
+		if d.Cgroup.CgroupError != "" {
+			fmt.Fprintf(&b, "  %-12s : %s\n", "error", d.Cgroup.CgroupError)
+		} else {
+			fmt.Fprintf(&b, "  %-12s : %s\n", "name", d.Cgroup.CgroupName)
+		}
+		if d.Cgroup.InterfaceError != "" {
+			fmt.Fprintf(&b, "  %-12s : %s\n", "error", d.Cgroup.InterfaceError)
+		} else if d.Cgroup.InterfacePath != "" {
+			fmt.Fprintf(&b, "  %-12s : %s (%s)\n", "path", d.Cgroup.InterfacePath, d.Cgroup.InterfaceSource)
+		}
+		for _, f := range d.Cgroup.Files {
+			if f.Error != "" {
+				fmt.Fprintf(&b, "  %-12s : (error) %s\n", f.Name, f.Error)
+			} else {
+				fmt.Fprintf(&b, "  %-12s : %q\n", f.Name, f.Contents)
+			}
+		}
+	}
+
+	b.WriteString("GOMAXPROCS     :\n")
+	fmt.Fprintf(&b, "  %-12s : %d\n", "current", d.MaxProcs.Result.Previous)
+	fmt.Fprintf(&b, "  %-12s : %s\n", "quota", strconv.FormatFloat(d.MaxProcs.Result.Quota, 'f', -1, 64))
+	fmt.Fprintf(&b, "  %-12s : %d\n", "value", d.MaxProcs.Result.Value)
+	fmt.Fprintf(&b, "  %-12s : %s\n", "source", d.MaxProcs.Result.Source)
+	if d.MaxProcs.Result.Reason != "" {
+		fmt.Fprintf(&b, "  %-12s : %s\n", "reason", d.MaxProcs.Result.Reason)
+	}
+	if d.MaxProcs.Error != "" {
+		fmt.Fprintf(&b, "  %-12s : %s\n", "error", d.MaxProcs.Error)
+	}
+
+	b.WriteString("GOMEMLIMIT     :\n")
+	fmt.Fprintf(&b, "  %-12s : %s\n", "current", units.Format(d.MemLimit.Result.Previous))
+	fmt.Fprintf(&b, "  %-12s : %s\n", "max", units.Format(d.MemLimit.Result.Max))
+	fmt.Fprintf(&b, "  %-12s : %s\n", "high", units.Format(d.MemLimit.Result.High))
+	fmt.Fprintf(&b, "  %-12s : %s\n", "reserve", units.Format(d.MemLimit.Result.Reserve))
+	fmt.Fprintf(&b, "  %-12s : %s\n", "value", units.Format(d.MemLimit.Result.Value))
+	fmt.Fprintf(&b, "  %-12s : %s\n", "source", d.MemLimit.Result.Source)
+	if d.MemLimit.Result.Reason != "" {
+		fmt.Fprintf(&b, "  %-12s : %s\n", "reason", d.MemLimit.Result.Reason)
+	}
+	if d.MemLimit.Error != "" {
+		fmt.Fprintf(&b, "  %-12s : %s\n", "error", d.MemLimit.Error)
+	}
+	return b.String()
+}
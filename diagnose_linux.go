@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package autotune
+
+import (
+	"github.com/tprasadtp/go-autotune/internal/quota"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+// diagnose populates cgroup diagnosis and returns detectors which
+// re-use the resolved cgroup interface path.
+func diagnose(d *Diagnosis) (maxprocs.CPUQuotaDetector, memlimit.MemoryQuotaDetector) {
+	trace := quota.Inspect("")
+	d.Cgroup = &CgroupDiagnosis{
+		MountInfoPath:   trace.MountInfoPath,
+		MountInfoLine:   trace.MountInfoLine,
+		MountPoint:      trace.MountPoint,
+		CgroupPath:      trace.CgroupPath,
+		CgroupName:      trace.CgroupName,
+		InterfacePath:   trace.InterfacePath,
+		InterfaceSource: trace.InterfaceSource,
+	}
+
+	if trace.MountInfoErr != nil {
+		d.Cgroup.MountInfoError = trace.MountInfoErr.Error()
+	}
+
+	if trace.CgroupErr != nil {
+		d.Cgroup.CgroupError = trace.CgroupErr.Error()
+	}
+
+	if trace.InterfaceErr != nil {
+		d.Cgroup.InterfaceError = trace.InterfaceErr.Error()
+	}
+
+	for _, item := range trace.Files {
+		file := FileDiagnosis{
+			Name:     item.Name,
+			Path:     item.Path,
+			Contents: item.Contents,
+		}
+		if item.Err != nil {
+			file.Error = item.Err.Error()
+		}
+		d.Cgroup.Files = append(d.Cgroup.Files, file)
+	}
+
+	// Interface path is resolved like default detectors. If it cannot be resolved,
+	// default detector is used, which returns the error, and is recorded by the caller.
+	detector := &quota.Detector{}
+	if trace.InterfacePath != "" {
+		detector = quota.NewDetectorWithCgroupPath(trace.InterfacePath)
+	}
+	return detector, detector
+}
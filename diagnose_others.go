@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package autotune
+
+import (
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+// diagnose returns default detectors as there is no cgroup
+// interface path to resolve on this platform.
+func diagnose(_ *Diagnosis) (maxprocs.CPUQuotaDetector, memlimit.MemoryQuotaDetector) {
+	return maxprocs.DefaultCPUQuotaDetector(), memlimit.DefaultMemoryQuotaDetector()
+}
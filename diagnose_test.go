@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package autotune_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune"
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestDiagnose(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "")
+	t.Setenv("GOMEMLIMIT", "")
+	t.Setenv("GOAUTOTUNE", "")
+	t.Setenv("GOAUTOTUNE_MEMLIMIT_RESERVE", "")
+	t.Setenv("GOAUTOTUNE_SHARE", "")
+
+	procs := runtime.GOMAXPROCS(-1)
+	limit := debug.SetMemoryLimit(-1)
+
+	t.Run("Quota", func(t *testing.T) {
+		diagnosis, err := autotune.Diagnose(context.Background(),
+			autotune.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			autotune.WithCPUQuotaDetector(maxprocs.CPUQuotaDetectorFunc(
+				func(context.Context) (float64, error) {
+					return 0.5, nil
+				},
+			)),
+			autotune.WithMemoryQuotaDetector(memlimit.MemoryQuotaDetectorFunc(
+				func(context.Context) (int64, int64, error) {
+					return 250 * shared.MiByte, 0, nil
+				},
+			)),
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if v := runtime.GOMAXPROCS(-1); v != procs {
+			t.Errorf("Diagnose modified GOMAXPROCS, expected=%d, got=%d", procs, v)
+		}
+
+		if v := debug.SetMemoryLimit(-1); v != limit {
+			t.Errorf("Diagnose modified GOMEMLIMIT, expected=%d, got=%d", limit, v)
+		}
+
+		if diagnosis.MaxProcs.Result.Value != 1 || diagnosis.MaxProcs.Result.Quota != 0.5 {
+			t.Errorf("unexpected GOMAXPROCS result: %+v", diagnosis.MaxProcs.Result)
+		}
+
+		if diagnosis.MemLimit.Result.Max != 250*shared.MiByte ||
+			diagnosis.MemLimit.Result.Source != memlimit.SourceQuota {
+			t.Errorf("unexpected GOMEMLIMIT result: %+v", diagnosis.MemLimit.Result)
+		}
+
+		if len(diagnosis.Env) == 0 {
+			t.Errorf("expected environment variables to be recorded")
+		}
+
+		if runtime.GOOS == "linux" && diagnosis.Cgroup == nil {
+			t.Errorf("expected cgroup diagnosis on linux")
+		}
+
+		if _, err := json.Marshal(diagnosis); err != nil {
+			t.Errorf("failed to encode diagnosis as JSON: %s", err)
+		}
+
+		text := diagnosis.String()
+		for _, item := range []string{"GOMAXPROCS", "GOMEMLIMIT", "quota"} {
+			if !strings.Contains(text, item) {
+				t.Errorf("expected text output to contain %q", item)
+			}
+		}
+	})
+
+	t.Run("CgroupPathEnv", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skipf("cgroup is only supported on linux")
+		}
+
+		dir := t.TempDir()
+		for name, contents := range map[string]string{
+			"cpu.max":    "50000 100000\n",
+			"memory.max": "262144000\n",
+		} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600); err != nil {
+				t.Fatalf("failed to write %s: %s", name, err)
+			}
+		}
+		t.Setenv("GOAUTOTUNE_CGROUP_PATH", dir)
+
+		diagnosis, err := autotune.Diagnose(context.Background(),
+			autotune.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if diagnosis.Cgroup == nil || diagnosis.Cgroup.InterfacePath != dir ||
+			diagnosis.Cgroup.InterfaceSource != "GOAUTOTUNE_CGROUP_PATH" {
+			t.Fatalf("expected interface path %q from GOAUTOTUNE_CGROUP_PATH, got %+v", dir, diagnosis.Cgroup)
+		}
+
+		for _, f := range diagnosis.Cgroup.Files {
+			if f.Name == "cpu.max" && f.Contents != "50000 100000" {
+				t.Errorf("unexpected cpu.max contents=%q", f.Contents)
+			}
+		}
+
+		if diagnosis.MaxProcs.Result.Quota != 0.5 {
+			t.Errorf("unexpected GOMAXPROCS result: %+v", diagnosis.MaxProcs.Result)
+		}
+
+		if diagnosis.MemLimit.Result.Max != 250*shared.MiByte {
+			t.Errorf("unexpected GOMEMLIMIT result: %+v", diagnosis.MemLimit.Result)
+		}
+	})
+
+	t.Run("ReserveEnv", func(t *testing.T) {
+		t.Setenv("GOAUTOTUNE_MEMLIMIT_RESERVE", "20%")
+		diagnosis, err := autotune.Diagnose(context.Background(),
+			autotune.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			autotune.WithMemoryQuotaDetector(memlimit.MemoryQuotaDetectorFunc(
+				func(context.Context) (int64, int64, error) {
+					return 250 * shared.MiByte, 0, nil
+				},
+			)),
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if diagnosis.MemLimit.Result.Value != 200*shared.MiByte {
+			t.Errorf("unexpected GOMEMLIMIT result: %+v", diagnosis.MemLimit.Result)
+		}
+
+		var found bool
+		for _, item := range diagnosis.Env {
+			if item.Name == "GOAUTOTUNE_MEMLIMIT_RESERVE" && item.Set && item.Value == "20%" {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("expected GOAUTOTUNE_MEMLIMIT_RESERVE to be recorded, got %+v", diagnosis.Env)
+		}
+	})
+
+	t.Run("Share", func(t *testing.T) {
+		tt := []struct {
+			name string
+			env  string
+			opts []autotune.Option
+		}{
+			{
+				name: "Option",
+				opts: []autotune.Option{autotune.WithShare(0.5)},
+			},
+			{
+				name: "Env",
+				env:  "0.5",
+			},
+		}
+		for _, tc := range tt {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Setenv("GOAUTOTUNE_SHARE", tc.env)
+				opts := append([]autotune.Option{
+					autotune.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+					autotune.WithCPUQuotaDetector(maxprocs.CPUQuotaDetectorFunc(
+						func(context.Context) (float64, error) {
+							return 4, nil
+						},
+					)),
+					autotune.WithMemoryQuotaDetector(memlimit.MemoryQuotaDetectorFunc(
+						func(context.Context) (int64, int64, error) {
+							return 500 * shared.MiByte, 0, nil
+						},
+					)),
+				}, tc.opts...)
+
+				diagnosis, err := autotune.Diagnose(context.Background(), opts...)
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+
+				if diagnosis.MaxProcs.Result.Value != 2 {
+					t.Errorf("unexpected GOMAXPROCS result: %+v", diagnosis.MaxProcs.Result)
+				}
+
+				if diagnosis.MemLimit.Result.Value != 225*shared.MiByte {
+					t.Errorf("unexpected GOMEMLIMIT result: %+v", diagnosis.MemLimit.Result)
+				}
+			})
+		}
+	})
+
+	t.Run("InvalidReserveEnv", func(t *testing.T) {
+		t.Setenv("GOAUTOTUNE_MEMLIMIT_RESERVE", "foo")
+		diagnosis, err := autotune.Diagnose(context.Background(),
+			autotune.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			autotune.WithMemoryQuotaDetector(memlimit.MemoryQuotaDetectorFunc(
+				func(context.Context) (int64, int64, error) {
+					return 250 * shared.MiByte, 0, nil
+				},
+			)),
+		)
+		if err == nil {
+			t.Errorf("expected an error, got nil")
+		}
+
+		if diagnosis.EnvError == "" {
+			t.Errorf("expected environment variable error to be recorded")
+		}
+
+		if diagnosis.MemLimit.Result.Value != 225*shared.MiByte {
+			t.Errorf("unexpected GOMEMLIMIT result: %+v", diagnosis.MemLimit.Result)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		diagnosis, err := autotune.Diagnose(context.Background(),
+			autotune.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			autotune.WithCPUQuotaDetector(maxprocs.CPUQuotaDetectorFunc(
+				func(context.Context) (float64, error) {
+					return 0, errors.New("test: cpu error")
+				},
+			)),
+			autotune.WithMemoryQuotaDetector(memlimit.MemoryQuotaDetectorFunc(
+				func(context.Context) (int64, int64, error) {
+					return 0, 0, errors.New("test: memory error")
+				},
+			)),
+		)
+		if err == nil {
+			t.Fatalf("expected an error, got nil")
+		}
+
+		if diagnosis.MaxProcs.Error == "" || diagnosis.MemLimit.Error == "" {
+			t.Errorf("expected errors to be recorded, got %+v, %+v",
+				diagnosis.MaxProcs, diagnosis.MemLimit)
+		}
+
+		if v := debug.SetMemoryLimit(-1); v != limit {
+			t.Errorf("Diagnose modified GOMEMLIMIT: %d", v)
+		}
+	})
+}
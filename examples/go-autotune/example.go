@@ -6,6 +6,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -23,7 +24,12 @@ import (
 
 	_ "embed"
 
-	_ "github.com/tprasadtp/go-autotune"
+	"github.com/tprasadtp/go-autotune"
+	"github.com/tprasadtp/go-autotune/cgroup"
+	tune "github.com/tprasadtp/go-autotune/internal/autotune"
+	"github.com/tprasadtp/go-autotune/internal/env"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
 )
 
 //go:embed favicon.ico
@@ -37,8 +43,164 @@ func info(w io.Writer) {
 	fmt.Fprintf(w, "GOMEMLIMIT : %d\n", debug.SetMemoryLimit(-1))
 }
 
+// inspect writes diagnosis of GOMAXPROCS and GOMEMLIMIT to stdout.
+func inspect(args []string) error {
+	var format string
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	fs.StringVar(&format, "format", "text", "output format (text or json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Errors are already recorded in diagnosis.
+	diagnosis, _ := autotune.Diagnose(context.Background())
+	switch format {
+	case "text":
+		fmt.Fprint(os.Stdout, diagnosis.String())
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diagnosis)
+	default:
+		return fmt.Errorf("invalid format: %q", format)
+	}
+	return nil
+}
+
+// capture writes a support bundle to stdout or to a file.
+func capture(args []string) error {
+	var format, output, procfs, cgroupfs string
+	var pid int
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	fs.StringVar(&format, "format", "tar", "output format (tar or json)")
+	fs.StringVar(&output, "output", "", "output file (default stdout)")
+	fs.IntVar(&pid, "pid", 0, "pid of the process (default current process)")
+	fs.StringVar(&procfs, "procfs", "", "procfs mount point (default /proc)")
+	fs.StringVar(&cgroupfs, "cgroupfs", "", "cgroup2 hierarchy root (default from mountinfo)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	bundle, err := cgroup.Capture(pid, cgroup.WithProcFS(procfs), cgroup.WithCgroupFS(cgroupfs))
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if output != "" {
+		w, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+
+	switch format {
+	case "tar":
+		return bundle.WriteTar(w)
+	case "json":
+		return bundle.WriteJSON(w)
+	default:
+		return fmt.Errorf("invalid format: %q", format)
+	}
+}
+
+// replay replays a support bundle and writes GOMAXPROCS and GOMEMLIMIT
+// which would have been set.
+func replay(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: go-autotune replay <bundle>")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	bundle, err := cgroup.ReadBundle(file)
+	if err != nil {
+		return err
+	}
+
+	// Environment variables are considered when computing, thus
+	// use the ones from the bundle.
+	for _, key := range env.Names {
+		os.Unsetenv(key)
+		if v, ok := bundle.Env[key]; ok {
+			os.Setenv(key, v)
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "Captured   : %s\n", bundle.Time)
+	fmt.Fprintf(os.Stdout, "Platform   : %s/%s\n", bundle.GOOS, bundle.GOARCH)
+	fmt.Fprintf(os.Stdout, "Go Version : %s\n", bundle.GoVersion)
+	fmt.Fprintf(os.Stdout, "NumCPU     : %d\n", bundle.NumCPU)
+	for key, value := range bundle.Errors {
+		fmt.Fprintf(os.Stdout, "Error      : %s: %s\n", key, value)
+	}
+
+	// If detector cannot be created, use detectors which return the error,
+	// to show decision made by Configure.
+	var cpu maxprocs.CPUQuotaDetector
+	var mem memlimit.MemoryQuotaDetector
+	detector, errDetector := bundle.Detector()
+	if errDetector != nil {
+		cpu = maxprocs.CPUQuotaDetectorFunc(func(context.Context) (float64, error) {
+			return 0, errDetector
+		})
+		mem = memlimit.MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+			return 0, 0, errDetector
+		})
+	} else {
+		cpu, mem = detector, detector
+	}
+
+	// Settings specified by environment variables are applied like Configure.
+	cfg := tune.Config{CPUQuotaDetector: cpu, MemoryQuotaDetector: mem}
+	if err = tune.ApplyEnv(context.Background(), &cfg); err != nil {
+		fmt.Fprintf(os.Stdout, "Error      : %s\n", err)
+	}
+
+	procs, err := maxprocs.Compute(context.Background(), tune.MaxProcsOptions(cfg)...)
+	fmt.Fprintf(os.Stdout, "GOMAXPROCS : %d (source=%s, quota=%g, captured=%d)\n",
+		procs.Value, procs.Source, procs.Quota, bundle.GOMAXPROCS)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "Error      : %s\n", err)
+	}
+
+	limit, err := memlimit.Compute(context.Background(), tune.MemLimitOptions(cfg)...)
+	fmt.Fprintf(os.Stdout, "GOMEMLIMIT : %d (source=%s, max=%d, high=%d, captured=%d)\n",
+		limit.Value, limit.Source, limit.Max, limit.High, bundle.GOMEMLIMIT)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "Error      : %s\n", err)
+	}
+	return nil
+}
+
 func main() {
 	var addr string
+
+	// Handle sub-commands.
+	if len(os.Args) > 1 {
+		var cmd func([]string) error
+		switch os.Args[1] {
+		case "inspect":
+			cmd = inspect
+		case "capture":
+			cmd = capture
+		case "replay":
+			cmd = replay
+		}
+
+		if cmd != nil {
+			if err := cmd(os.Args[2:]); err != nil {
+				slog.Error("Command failed", slog.String("command", os.Args[1]), slog.Any("err", err))
+				os.Exit(1)
+			}
+			return
+		}
+	}
 	var wg sync.WaitGroup
 
 	// Parse flags.
@@ -3,12 +3,287 @@
 
 // Package autotune which implements autotune functions.
 //
-// This is an internal package with only single method exported
-// to allow easy benchmarking and tests without side effects of init.
+// This is an internal package to allow easy benchmarking and tests
+// without side effects of init.
 package autotune
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/env"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+// Config used by [Run].
+type Config struct {
+	// Logger to use. If nil, logs are discarded unless GOAUTOTUNE
+	// environment variable is set to "debug".
+	Logger *slog.Logger
+
+	// Leave GOMAXPROCS and GOMEMLIMIT unchanged if they were modified
+	// by other packages.
+	PreserveExternal bool
+
+	// CPU quota detector. If nil, default detector is used.
+	CPUQuotaDetector maxprocs.CPUQuotaDetector
+
+	// Memory quota detector. If nil, default detector is used.
+	MemoryQuotaDetector memlimit.MemoryQuotaDetector
+
+	// Rounding function for fractional CPU quota. If nil, default is used.
+	RoundFunc func(float64) int
+
+	// CPU policy for GOMAXPROCS. If nil, default is used.
+	CPUPolicy maxprocs.CPUPolicy
+
+	// Reserve function for hard memory limits. If nil, reserve spec specified by
+	// GOAUTOTUNE_MEMLIMIT_RESERVE environment variable or default is used.
+	ReserveFunc func(int64) int64
+
+	// Limit policy for GOMEMLIMIT. If nil, default is used.
+	LimitPolicy memlimit.LimitPolicy
+
+	// Do not configure GOMAXPROCS.
+	DisableMaxProcs bool
+
+	// Do not configure GOMEMLIMIT.
+	DisableMemLimit bool
+
+	// Report GOMAXPROCS and GOMEMLIMIT to the service manager via sd_notify.
+	// This is also enabled if GOAUTOTUNE_NOTIFY environment variable is true.
+	Notify bool
+
+	// Share of CPU and memory limits for this process. If Share, ProcessShare
+	// and ExcludeOthers are not specified, GOAUTOTUNE_SHARE environment variable
+	// is used.
+	Share float64
+
+	// Divide CPU and memory limits equally among processes sharing them.
+	ProcessShare bool
+
+	// Subtract memory used by other processes sharing memory limits.
+	ExcludeOthers bool
+}
+
+// Report returned by [Run].
+type Report struct {
+	MaxProcs maxprocs.Result
+	MemLimit memlimit.Result
+}
+
+// DefaultInitTimeout is the default deadline for configuring GOMAXPROCS
+// and GOMEMLIMIT in init function of the public package.
+const DefaultInitTimeout = time.Second
+
+// InitContext returns context to use for configuring GOMAXPROCS and GOMEMLIMIT
+// in init function of the public package. Its deadline is specified by
+// GOAUTOTUNE_TIMEOUT environment variable as a duration (for example, 500ms),
+// which defaults to [DefaultInitTimeout]. If it is "0", there is no deadline.
+// Invalid or negative values are ignored.
+func InitContext() (context.Context, context.CancelFunc) {
+	timeout := DefaultInitTimeout
+	if v, err := time.ParseDuration(os.Getenv(env.Timeout)); err == nil && v >= 0 {
+		timeout = v
+	}
+
+	if timeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
 // Configure configures GOMAXPROCS and GOMEMLIMIT. This is only intended
 // to be used for testing and use in init function of the public package.
 func Configure() {
-	configure()
+	ctx, cancel := InitContext()
+	defer cancel()
+	_, _ = Run(ctx, Config{})
+}
+
+// current returns report with current GOMAXPROCS and GOMEMLIMIT values.
+func current() Report {
+	return Report{
+		MaxProcs: maxprocs.Result{
+			Previous: maxprocs.Current(),
+			Value:    maxprocs.Current(),
+			Source:   maxprocs.SourceDefault,
+		},
+		MemLimit: memlimit.Result{
+			Previous: memlimit.Current(),
+			Value:    memlimit.Current(),
+			Source:   memlimit.SourceDefault,
+		},
+	}
+}
+
+// Run configures GOMAXPROCS and GOMEMLIMIT with the given config.
+// If GOAUTOTUNE environment variable is set to false, this does nothing.
+//
+//nolint:nonamedreturns // required to recover from panics.
+func Run(ctx context.Context, cfg Config) (report Report, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if env.IsFalse(env.AutotuneLegacy) || env.IsFalse(env.Autotune) {
+		return current(), nil
+	}
+
+	// Detectors, policies and other functions are protected against panics
+	// by maxprocs and memlimit packages, but logger may also panic.
+	defer func() {
+		if r := recover(); r != nil {
+			report = current()
+			err = fmt.Errorf("autotune: recovered from panic: %v", r)
+		}
+	}()
+
+	errEnv := ApplyEnv(ctx, &cfg)
+	report, err = run(ctx, cfg)
+	return report, errors.Join(errEnv, err)
+}
+
+// ApplyEnv updates cfg with settings specified by environment variables, like
+// GOAUTOTUNE_MEMLIMIT_RESERVE and GOAUTOTUNE_SHARE, which are not overridden
+// by cfg. Invalid environment variables are logged and ignored, and errors
+// are returned joined together with [errors.Join].
+func ApplyEnv(ctx context.Context, cfg *Config) error {
+	if cfg.Logger == nil {
+		if env.IsDebug(env.AutotuneLegacy) || env.IsDebug(env.Autotune) {
+			cfg.Logger = slog.Default()
+		}
+	}
+
+	if env.IsTrue(env.Notify) {
+		cfg.Notify = true
+	}
+
+	// Reserve func specified via config takes precedence over environment variable.
+	// If environment variable is invalid, default reserve func is used.
+	var errReserve error
+	if cfg.ReserveFunc == nil {
+		cfg.ReserveFunc, errReserve = reserveFromEnv()
+		if errReserve != nil && cfg.Logger != nil {
+			cfg.Logger.LogAttrs(ctx, slog.LevelWarn, "Ignoring invalid GOAUTOTUNE_MEMLIMIT_RESERVE",
+				slog.Any("err", errReserve),
+			)
+		}
+	}
+
+	// Share specified via config takes precedence over environment variable.
+	var errShare error
+	if cfg.Share == 0 && !cfg.ProcessShare && !cfg.ExcludeOthers {
+		errShare = shareFromEnv(cfg)
+		if errShare != nil && cfg.Logger != nil {
+			cfg.Logger.LogAttrs(ctx, slog.LevelWarn, "Ignoring invalid GOAUTOTUNE_SHARE",
+				slog.Any("err", errShare),
+			)
+		}
+	}
+	return errors.Join(errReserve, errShare)
+}
+
+// reserveFromEnv returns reserve func specified by GOAUTOTUNE_MEMLIMIT_RESERVE
+// environment variable. See [memlimit.ParseReserve] for its syntax. If it is not
+// set, nil is returned.
+func reserveFromEnv() (func(int64) int64, error) {
+	spec := os.Getenv(env.MemLimitReserve)
+	if spec == "" {
+		return nil, nil
+	}
+
+	fn, err := memlimit.ParseReserve(spec)
+	if err != nil {
+		return nil, fmt.Errorf("autotune: invalid GOAUTOTUNE_MEMLIMIT_RESERVE: %w", err)
+	}
+	return fn, nil
+}
+
+// shareFromEnv updates cfg with share specified by GOAUTOTUNE_SHARE
+// environment variable. It can be a number in range (0, 1), "procs" or "others".
+func shareFromEnv(cfg *Config) error {
+	spec := strings.TrimSpace(os.Getenv(env.Share))
+	switch spec {
+	case "":
+	case "procs":
+		cfg.ProcessShare = true
+	case "others":
+		cfg.ProcessShare = true
+		cfg.ExcludeOthers = true
+	default:
+		v, err := strconv.ParseFloat(spec, 64)
+		if err != nil || !(v > 0 && v < 1) {
+			return fmt.Errorf("autotune: invalid GOAUTOTUNE_SHARE(%q): must be procs, others "+
+				"or a number in range (0, 1)", spec)
+		}
+		cfg.Share = v
+	}
+	return nil
+}
+
+// apply configures GOMAXPROCS and GOMEMLIMIT using given detectors.
+func apply(ctx context.Context, cfg Config) (Report, error) {
+	var report Report
+	var errMaxProcs, errMemLimit error
+
+	if cfg.DisableMaxProcs {
+		report.MaxProcs = maxprocs.Result{
+			Previous: maxprocs.Current(),
+			Value:    maxprocs.Current(),
+			Source:   maxprocs.SourceDefault,
+		}
+	} else {
+		report.MaxProcs, errMaxProcs = maxprocs.Apply(ctx, MaxProcsOptions(cfg)...)
+	}
+
+	if cfg.DisableMemLimit {
+		report.MemLimit = memlimit.Result{
+			Previous: memlimit.Current(),
+			Value:    memlimit.Current(),
+			Source:   memlimit.SourceDefault,
+		}
+	} else {
+		report.MemLimit, errMemLimit = memlimit.Apply(ctx, MemLimitOptions(cfg)...)
+	}
+
+	return report, errors.Join(errMaxProcs, errMemLimit)
+}
+
+// MaxProcsOptions returns options for [maxprocs.Apply] and [maxprocs.Compute]
+// specified by cfg.
+func MaxProcsOptions(cfg Config) []maxprocs.Option {
+	return []maxprocs.Option{
+		maxprocs.WithLogger(cfg.Logger),
+		maxprocs.WithCPUQuotaDetector(cfg.CPUQuotaDetector),
+		maxprocs.WithRoundFunc(cfg.RoundFunc),
+		maxprocs.WithCPUPolicy(cfg.CPUPolicy),
+		maxprocs.WithPreserveExternal(cfg.PreserveExternal),
+		maxprocs.WithSystemdNotify(cfg.Notify),
+		maxprocs.WithShare(cfg.Share),
+		maxprocs.WithProcessShare(cfg.ProcessShare),
+	}
+}
+
+// MemLimitOptions returns options for [memlimit.Apply] and [memlimit.Compute]
+// specified by cfg.
+func MemLimitOptions(cfg Config) []memlimit.Option {
+	return []memlimit.Option{
+		memlimit.WithLogger(cfg.Logger),
+		memlimit.WithMemoryQuotaDetector(cfg.MemoryQuotaDetector),
+		memlimit.WithReserveFunc(cfg.ReserveFunc),
+		memlimit.WithLimitPolicy(cfg.LimitPolicy),
+		memlimit.WithPreserveExternal(cfg.PreserveExternal),
+		memlimit.WithSystemdNotify(cfg.Notify),
+		memlimit.WithShare(cfg.Share),
+		memlimit.WithProcessShare(cfg.ProcessShare),
+		memlimit.WithExcludeOthers(cfg.ExcludeOthers),
+	}
 }
@@ -9,38 +9,41 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/tprasadtp/go-autotune/internal/env"
 	"github.com/tprasadtp/go-autotune/internal/quota"
-	"github.com/tprasadtp/go-autotune/maxprocs"
-	"github.com/tprasadtp/go-autotune/memlimit"
+	"github.com/tprasadtp/go-autotune/internal/shared"
 )
 
-func configure() {
-	if env.IsFalse("GO_AUTOTUNE") || env.IsFalse("GOAUTOTUNE") {
-		return
-	}
-
-	var logger *slog.Logger
-	if env.IsDebug("GO_AUTOTUNE") || env.IsDebug("GOAUTOTUNE") {
-		logger = slog.Default()
-	}
+func run(ctx context.Context, cfg Config) (Report, error) {
+	cpu := !cfg.DisableMaxProcs && cfg.CPUQuotaDetector == nil
+	mem := !cfg.DisableMemLimit && cfg.MemoryQuotaDetector == nil
 
 	// To avoid parsing mountinfo and cgroup file twice,
 	// get cgroup interface path for current process' cgroup
-	// and re-use it.
-	cgroupfs, err := quota.GetCgroupInterfacePath("")
-	if err != nil {
-		return
+	// and re-use it for both detectors. GOAUTOTUNE_CGROUP_PATH and
+	// GOAUTOTUNE_PROCFS environment variables are honored. If it cannot
+	// be resolved, default detector returns the error, but environment
+	// variables are still considered.
+	if cpu || mem {
+		detector := &quota.Detector{}
+		cgroupfs, err := shared.Call(ctx, func(context.Context) (string, error) {
+			return quota.DefaultCgroupInterfacePath()
+		})
+		if err == nil {
+			detector = quota.NewDetectorWithCgroupPath(cgroupfs)
+		} else if cfg.Logger != nil {
+			cfg.Logger.LogAttrs(ctx, slog.LevelDebug, "Failed to resolve cgroup interface path",
+				slog.Any("err", err),
+			)
+		}
+
+		if cpu {
+			cfg.CPUQuotaDetector = detector
+		}
+
+		if mem {
+			cfg.MemoryQuotaDetector = detector
+		}
 	}
-	detector := quota.NewDetectorWithCgroupPath(cgroupfs)
-	ctx := context.Background()
-
-	_ = maxprocs.Configure(ctx,
-		maxprocs.WithLogger(logger),
-		maxprocs.WithCPUQuotaDetector(detector),
-	)
-	_ = memlimit.Configure(ctx,
-		memlimit.WithLogger(logger),
-		memlimit.WithMemoryQuotaDetector(detector),
-	)
+
+	return apply(ctx, cfg)
 }
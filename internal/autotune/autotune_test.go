@@ -4,11 +4,18 @@
 package autotune_test
 
 import (
+	"context"
+	"log/slog"
+	"runtime/debug"
 	"testing"
+	"time"
 
 	"github.com/tprasadtp/go-autotune/internal/autotune"
 	"github.com/tprasadtp/go-autotune/internal/trampoline"
 	"github.com/tprasadtp/go-autotune/internal/trampoline/scenarios"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+	"github.com/tprasadtp/go-autotune/units"
 )
 
 func TestIntegration(t *testing.T) {
@@ -30,3 +37,151 @@ func BenchmarkConfigure(b *testing.B) {
 		autotune.Configure()
 	}
 }
+
+func TestInitContext(t *testing.T) {
+	tt := []struct {
+		env      string
+		deadline bool
+		expect   time.Duration
+	}{
+		{env: "", deadline: true, expect: autotune.DefaultInitTimeout},
+		{env: "500ms", deadline: true, expect: 500 * time.Millisecond},
+		{env: "invalid", deadline: true, expect: autotune.DefaultInitTimeout},
+		{env: "-1s", deadline: true, expect: autotune.DefaultInitTimeout},
+		{env: "0"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv("GOAUTOTUNE_TIMEOUT", tc.env)
+			start := time.Now()
+			ctx, cancel := autotune.InitContext()
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if ok != tc.deadline {
+				t.Fatalf("expected deadline=%t, got=%t", tc.deadline, ok)
+			}
+
+			if ok && (deadline.Before(start.Add(tc.expect)) || deadline.After(time.Now().Add(tc.expect))) {
+				t.Errorf("expected deadline in %s, got %s", tc.expect, deadline.Sub(start))
+			}
+		})
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	t.Setenv("GOAUTOTUNE", "")
+	report, err := autotune.Run(context.Background(), autotune.Config{
+		Logger:           slog.New(panicHandler{}),
+		CPUQuotaDetector: maxprocs.CPUQuotaDetectorFunc(func(context.Context) (float64, error) { return 1, nil }),
+		DisableMemLimit:  true,
+	})
+	if err == nil {
+		t.Errorf("expected error when logger panics")
+	}
+
+	if report.MaxProcs.Value != maxprocs.Current() {
+		t.Errorf("expected report with current GOMAXPROCS")
+	}
+}
+
+func TestRunReserveEnv(t *testing.T) {
+	orig := debug.SetMemoryLimit(-1)
+	t.Cleanup(func() {
+		debug.SetMemoryLimit(orig)
+	})
+
+	tt := []struct {
+		env     string
+		reserve int64
+		err     bool
+	}{
+		{env: "", reserve: 100 * units.MiB},
+		{env: "25%", reserve: 256 * units.MiB},
+		{env: "50%,max=128MiB", reserve: 128 * units.MiB},
+		{env: "invalid", reserve: 100 * units.MiB, err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv("GOAUTOTUNE", "")
+			t.Setenv("GOMEMLIMIT", "")
+			t.Setenv("GOAUTOTUNE_MEMLIMIT_RESERVE", tc.env)
+			report, err := autotune.Run(context.Background(), autotune.Config{
+				Logger: slog.New(trampoline.NewTestingHandler(t)),
+				MemoryQuotaDetector: memlimit.MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+					return units.GiB, 0, nil
+				}),
+				DisableMaxProcs: true,
+			})
+			if tc.err != (err != nil) {
+				t.Errorf("expected error=%t, got=%v", tc.err, err)
+			}
+
+			if report.MemLimit.Reserve != tc.reserve {
+				t.Errorf("expected reserve=%d, got=%d", tc.reserve, report.MemLimit.Reserve)
+			}
+		})
+	}
+}
+
+// sharedDetector is a [memlimit.MemoryInfoDetector] for memory
+// limits shared by 4 processes.
+type sharedDetector struct{}
+
+func (sharedDetector) DetectMemoryQuota(context.Context) (int64, int64, error) {
+	return 4 * units.GiB, 0, nil
+}
+
+func (sharedDetector) DetectMemoryInfo(context.Context) (memlimit.MemoryInfo, error) {
+	return memlimit.MemoryInfo{Max: 4 * units.GiB, Procs: 4}, nil
+}
+
+func TestRunShareEnv(t *testing.T) {
+	orig := debug.SetMemoryLimit(-1)
+	t.Cleanup(func() {
+		debug.SetMemoryLimit(orig)
+	})
+
+	tt := []struct {
+		env   string
+		cfg   autotune.Config
+		value int64
+		share float64
+		err   bool
+	}{
+		{env: "", value: 4*units.GiB - 100*units.MiB},
+		{env: "0.5", value: 2*units.GiB - 100*units.MiB, share: 0.5},
+		{env: "procs", value: units.GiB - 100*units.MiB, share: 0.25},
+		{env: "procs", cfg: autotune.Config{Share: 0.5}, value: 2*units.GiB - 100*units.MiB, share: 0.5},
+		{env: "1.5", value: 4*units.GiB - 100*units.MiB, err: true},
+		{env: "invalid", value: 4*units.GiB - 100*units.MiB, err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv("GOAUTOTUNE", "")
+			t.Setenv("GOMEMLIMIT", "")
+			t.Setenv("GOAUTOTUNE_MEMLIMIT_RESERVE", "")
+			t.Setenv("GOAUTOTUNE_SHARE", tc.env)
+			cfg := tc.cfg
+			cfg.Logger = slog.New(trampoline.NewTestingHandler(t))
+			cfg.MemoryQuotaDetector = sharedDetector{}
+			cfg.DisableMaxProcs = true
+			report, err := autotune.Run(context.Background(), cfg)
+			if tc.err != (err != nil) {
+				t.Errorf("expected error=%t, got=%v", tc.err, err)
+			}
+
+			if report.MemLimit.Value != tc.value || report.MemLimit.Share != tc.share {
+				t.Errorf("expected value=%d, share=%f, got=%+v", tc.value, tc.share, report.MemLimit)
+			}
+		})
+	}
+}
+
+// panicHandler is a [slog.Handler] which panics.
+type panicHandler struct{}
+
+func (panicHandler) Enabled(context.Context, slog.Level) bool  { return true }
+func (panicHandler) Handle(context.Context, slog.Record) error { panic("test: handler panic") }
+func (h panicHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h panicHandler) WithGroup(string) slog.Handler           { return h }
@@ -9,6 +9,56 @@ import (
 	"strings"
 )
 
+// Environment variables used by autotune and its packages.
+const (
+	// Autotune disables autotune when false, or enables debug logs when "debug".
+	Autotune = "GOAUTOTUNE"
+
+	// AutotuneLegacy is same as Autotune, and is supported for compatibility.
+	AutotuneLegacy = "GO_AUTOTUNE"
+
+	// MaxProcs is GOMAXPROCS used by the Go runtime.
+	MaxProcs = "GOMAXPROCS"
+
+	// MemLimit is GOMEMLIMIT used by the Go runtime.
+	MemLimit = "GOMEMLIMIT"
+
+	// MemLimitReserve is the reserve spec for hard memory limits.
+	MemLimitReserve = "GOAUTOTUNE_MEMLIMIT_RESERVE"
+
+	// Share is share of CPU and memory limits of the process.
+	Share = "GOAUTOTUNE_SHARE"
+
+	// Notify enables reporting to the service manager via sd_notify.
+	Notify = "GOAUTOTUNE_NOTIFY"
+
+	// Timeout is the deadline for configuring GOMAXPROCS and GOMEMLIMIT on init.
+	Timeout = "GOAUTOTUNE_TIMEOUT"
+
+	// CgroupPath overrides path to cgroup interface files.
+	CgroupPath = "GOAUTOTUNE_CGROUP_PATH"
+
+	// ProcFS overrides procfs directory of the current process.
+	ProcFS = "GOAUTOTUNE_PROCFS"
+)
+
+// Names of all environment variables which affect GOMAXPROCS and GOMEMLIMIT,
+// recorded by diagnostics and support bundles.
+//
+//nolint:gochecknoglobals // read only list.
+var Names = []string{
+	Autotune,
+	AutotuneLegacy,
+	MaxProcs,
+	MemLimit,
+	MemLimitReserve,
+	Share,
+	Notify,
+	Timeout,
+	CgroupPath,
+	ProcFS,
+}
+
 // IsTrue checks if environment variable env is set to truthy value.
 func IsTrue(env string) bool {
 	value := os.Getenv(env)
@@ -6,203 +6,100 @@
 package quota
 
 import (
-	"bufio"
-	"bytes"
-	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
-)
-
-// GetCgroupInterfacePath returns base path of cgroup interface files.
-// If procfs is empty, /self/proc is assumed.
-func GetCgroupInterfacePath(procfs string) (string, error) {
-	if procfs == "" {
-		procfs = "/proc/self"
-	}
-
-	mount, err := cgroupMountPointFromFile(filepath.Join(procfs, "mountinfo"))
-	if err != nil {
-		return "", fmt.Errorf("quota(cgroup): failed to get cgroup2 mountpoint: %w", err)
-	}
-
-	name, err := cgroupNameFromFile(filepath.Join(procfs, "cgroup"))
-	if err != nil {
-		return "", fmt.Errorf("quota(cgroup): failed to get cgroup name: %w", err)
-	}
-
-	return filepath.Join(mount, name), nil
-}
-
-// cgroupNameFromFile returns cgroup name for given cgroup file.
-func cgroupNameFromFile(path string) (string, error) {
-	// Try to open file.
-	file, err := os.Open(path)
-	if err != nil {
-		return "", fmt.Errorf("failed to open %s: %w", path, err)
-	}
-	defer file.Close()
 
-	// If file is too large do not read it.
-	stat, err := file.Stat()
-	if err != nil {
-		return "", fmt.Errorf("failed to check file size: %w", err)
-	}
-
-	if stat.Size() > 1e3 {
-		return "", fmt.Errorf("file too large: %d", stat.Size())
-	}
+	"github.com/tprasadtp/go-autotune/internal/env"
+	"golang.org/x/sys/unix"
+)
 
-	contents, err := io.ReadAll(file)
-	if err != nil {
-		return "", fmt.Errorf("failed to read contents for cgroup file %s: %w", path, err)
-	}
+// Environment variables which override cgroup interface path and
+// procfs directory used by [DefaultCgroupInterfacePath].
+const (
+	// EnvCgroupPath overrides path to cgroup interface files.
+	EnvCgroupPath = env.CgroupPath
 
-	// /proc/self/cgroup (since Linux 2.6.24)
-	// This file describes control groups to which the process with the corresponding PID
-	// belongs. The displayed information differs for cgroups version 1 and version 2 hierarchies.
-	// For each cgroup hierarchy of which the process is a member, there is one entry containing
-	// three colon-separated fields:
-	//
-	// hierarchy-ID:controller-list:cgroup-path
-	//
-	// The colon-separated fields are, from left to right:
-	//
-	// 1. For the cgroups version 2 hierarchy, this field contains the value 0.
-	// 2. For the cgroups version 2 hierarchy, this field is empty.
-	// 3. This field contains the pathname of the control group in the hierarchy to which the
-	//    process belongs. This pathname is relative to the mount point of the hierarchy.
-	//
-	// https://manpages.debian.org/buster/manpages/cgroups.7.en.html
-	if !bytes.HasPrefix(contents, []byte("0::")) {
-		return "", fmt.Errorf("missing prefix '0::' from cgroup file(%s)", path)
-	}
-
-	rv := string(bytes.TrimSpace(bytes.TrimPrefix(contents, []byte("0::"))))
-	if strings.Contains(rv, "\n") {
-		return "", fmt.Errorf("cgroup file(%s) contains newlines", path)
-	}
+	// EnvProcFS overrides procfs directory of the current process,
+	// which contains mountinfo and cgroup files. Default is /proc/self.
+	EnvProcFS = env.ProcFS
+)
 
-	return rv, nil
+// cgroupfsRoot is the default mount point of cgroup2 hierarchy.
+const cgroupfsRoot = "/sys/fs/cgroup"
+
+// DefaultCgroupInterfacePath returns base path of cgroup interface files
+// of the current process, used by default detectors.
+//
+//   - If GOAUTOTUNE_CGROUP_PATH environment variable is set, it is used as is.
+//   - Otherwise, it is resolved with [GetCgroupInterfacePath], using procfs directory
+//     specified by GOAUTOTUNE_PROCFS environment variable (default /proc/self).
+//   - If it cannot be resolved (for example, procfs is masked or unavailable)
+//     or resolved path does not exist, but /sys/fs/cgroup is a cgroup2 mount
+//     rooted at a non-root cgroup (as with cgroup namespaces), /sys/fs/cgroup
+//     is used.
+func DefaultCgroupInterfacePath() (string, error) {
+	path, _, err := defaultCgroupInterfacePath()
+	return path, err
 }
 
-// cgroupMountPointFromFile parses given mountinfo file and extracts cgroup
-// v2 mountpoint from it.
-func cgroupMountPointFromFile(mountInfo string) (string, error) {
-	file, err := os.Open(mountInfo)
-	if err != nil {
-		return "", fmt.Errorf("failed to open: %w", err)
-	}
-	defer file.Close()
-
-	// Read mount info file
-	// See https://manpages.debian.org/buster/manpages/proc.5.en.html
-	//
-	// The file contains lines of the form:
-	//
-	// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
-	// (1)(2)(3)   (4)   (5)      (6)      (7)   (8) (9)   (10)         (11)
-	//
-	// The numbers in parentheses are labels for the descriptions
-	// below:
-	//
-	// (1)  mount ID: a unique ID for the mount (may be reused
-	//      after umount(2)).
-	//
-	// (2)  parent ID: the ID of the parent mount (or of self for
-	//      the root of this mount namespace's mount tree).
-	//
-	// (3)  major:minor: the value of st_dev for files on this
-	//      filesystem.
-	//
-	// (4)  root: the pathname of the directory in the filesystem
-	//      which forms the root of this mount.
-	//
-	// (5)  mount point: the pathname of the mount point relative
-	//      to the process's root directory.
-	//
-	// (6)  mount options: per-mount options .
-	//
-	// (7)  optional fields: zero or more fields of the form
-	//      "tag[:value]"; see below.
-	//
-	// (8)  separator: the end of the optional fields is marked
-	//      by a single hyphen.
-	//
-	// (9)  filesystem type: the filesystem type in the form
-	//      "type[.subtype]".
-	//
-	// (10) mount source: filesystem-specific information or
-	//      "none".
-	//
-	// (11) super options: per-superblock options.
-	s := bufio.NewScanner(file)
-	for s.Scan() {
-		var err error
-		text := s.Text()
-		fields := strings.Split(text, " ")
-		numFields := len(fields)
-		if numFields < 10 {
-			// Should be at least 10 fields
-			return "", fmt.Errorf("parsing '%s' failed: not enough fields (%d)", text, numFields)
-		}
-
-		// Separator field
-		sepIdx := numFields - 4
-
-		// Check if type is valid
-		fsType, err := unescape(fields[sepIdx+1])
-		if err != nil {
-			return "", fmt.Errorf("parsing '%s' failed: fstype: %w", fields[sepIdx+1], err)
-		}
+// Sources of cgroup interface path returned by defaultCgroupInterfacePath.
+const (
+	sourceEnv      = EnvCgroupPath
+	sourceProcFS   = "procfs"
+	sourceCgroupNS = "cgroupns"
+)
 
-		mountpoint, err := unescape(fields[4])
+// defaultCgroupInterfacePath is same as [DefaultCgroupInterfacePath], but also
+// returns how the path was resolved.
+func defaultCgroupInterfacePath() (string, string, error) {
+	if path := os.Getenv(EnvCgroupPath); path != "" {
+		info, err := os.Stat(path)
 		if err != nil {
-			return "", fmt.Errorf("parsing '%s' failed: mount point: %w", fields[4], err)
+			return "", "", fmt.Errorf("quota(cgroup): invalid %s: %w", EnvCgroupPath, err)
 		}
 
-		if fsType == "cgroup2" {
-			return mountpoint, nil
+		if !info.IsDir() {
+			return "", "", fmt.Errorf("quota(cgroup): invalid %s: %q is not a directory", EnvCgroupPath, path)
 		}
+		return path, sourceEnv, nil
 	}
-	return "", errors.New("unable to find cgroup2 mountpoint")
-}
 
-func memLimitFromFile(path string) (int64, error) {
-	file, err := os.Open(path)
-	base := filepath.Base(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return 0, nil
+	path, err := GetCgroupInterfacePath(os.Getenv(EnvProcFS))
+	if err == nil {
+		_, err = os.Stat(path)
+		if err == nil {
+			return path, sourceProcFS, nil
 		}
+		err = fmt.Errorf("quota(cgroup): invalid cgroup interface path: %w", err)
 	}
 
-	scanner := bufio.NewScanner(file)
-	if scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
-		if len(fields) > 1 {
-			return 0, fmt.Errorf("invalid format %s", base)
-		}
-
-		// No memory limits.
-		if fields[0] == "max" {
-			return 0, nil
-		}
-
-		max, err := strconv.ParseInt(fields[0], 10, 64)
-		if err != nil || max < 0 {
-			return 0, fmt.Errorf("invalid format %s: %w", base, err)
-		}
-
-		return max, nil
+	if isNamespacedCgroupFS(cgroupfsRoot) {
+		return cgroupfsRoot, sourceCgroupNS, nil
 	}
+	return "", "", err
+}
 
-	if err := scanner.Err(); err != nil {
-		return 0, fmt.Errorf("failed to scan %s: %w", base, err)
+// isNamespacedCgroupFS returns true if dir is a cgroup2 mount rooted at
+// a non-root cgroup. Root cgroup does not have cgroup.type interface file.
+func isNamespacedCgroupFS(dir string) bool {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil || stat.Type != unix.CGROUP2_SUPER_MAGIC {
+		return false
 	}
 
-	return 0, io.ErrUnexpectedEOF
+	_, err := os.Stat(filepath.Join(dir, "cgroup.type"))
+	return err == nil
+}
+
+// GetCgroupInterfacePath returns base path of cgroup interface files of the
+// process whose procfs directory is procfs, as seen from the mount namespace
+// of the current process. If procfs is empty, /proc/self is assumed. Mount root
+// of the cgroup2 mount is stripped from the cgroup name, as only a part of the
+// hierarchy may be mounted, for example in containers without cgroup namespaces.
+func GetCgroupInterfacePath(procfs string) (string, error) {
+	if procfs == "" {
+		procfs = "/proc/self"
+	}
+	return resolveCgroupInterfacePath(nil, procfs, "", "/")
 }
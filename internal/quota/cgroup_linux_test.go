@@ -6,6 +6,8 @@
 package quota
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -22,6 +24,11 @@ func TestGetInterfacePath(t *testing.T) {
 			procfs: "cgroup-hybrid",
 			expect: "/sys/fs/cgroup/unified/user.slice/user-1000.slice/user@1000.service/app.slice/run-u18351.service",
 		},
+		{
+			name:   "cgroup-hybrid-duplicate",
+			procfs: "cgroup-hybrid-duplicate",
+			err:    true,
+		},
 		{
 			name:   "cgroup-invalid",
 			procfs: "cgroup-invalid",
@@ -72,6 +79,11 @@ func TestGetInterfacePath(t *testing.T) {
 			procfs: "systemd-debian",
 			expect: "/sys/fs/cgroup/user.slice/user-0.slice/user@0.service/app.slice/run-u4.service",
 		},
+		{
+			name:   "systemd-hybrid",
+			procfs: "systemd-hybrid",
+			expect: "/sys/fs/cgroup/unified/user.slice/user-1000.slice/user@1000.service/app.slice/run-u18351.service",
+		},
 		{
 			name:   "systemd-nspawn",
 			procfs: "systemd-nspawn",
@@ -92,6 +104,21 @@ func TestGetInterfacePath(t *testing.T) {
 			procfs: "systemd-user-fedora",
 			expect: "/sys/fs/cgroup/user.slice/user-1000.slice/user@1000.service/app.slice/run-u119.service",
 		},
+		{
+			name:   "docker-no-cgroupns",
+			procfs: "docker-no-cgroupns",
+			expect: "/sys/fs/cgroup",
+		},
+		{
+			name:   "mount-root-mismatch",
+			procfs: "mount-root-mismatch",
+			err:    true,
+		},
+		{
+			name:   "cgroup-outside-namespace",
+			procfs: "cgroup-outside-namespace",
+			err:    true,
+		},
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -116,3 +143,262 @@ func TestGetInterfacePath(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveCgroupInterfacePath(t *testing.T) {
+	tt := []struct {
+		name     string
+		procfs   string
+		cgroupfs string
+		expect   string
+		err      bool
+	}{
+		{
+			name:   "systemd-system",
+			procfs: "systemd-system",
+			expect: "testdata/procfs/systemd-system/root/sys/fs/cgroup/system.slice/run-u1801.service",
+		},
+		{
+			name:     "systemd-system-with-cgroupfs",
+			procfs:   "systemd-system",
+			cgroupfs: "/host/sys/fs/cgroup",
+			expect:   "/host/sys/fs/cgroup/system.slice/run-u1801.service",
+		},
+		{
+			name:   "docker-debian",
+			procfs: "docker-debian",
+			expect: "testdata/procfs/docker-debian/root/sys/fs/cgroup",
+		},
+		{
+			name:   "docker-no-cgroupns",
+			procfs: "docker-no-cgroupns",
+			expect: "testdata/procfs/docker-no-cgroupns/root/sys/fs/cgroup",
+		},
+		{
+			name:     "docker-no-cgroupns-with-cgroupfs",
+			procfs:   "docker-no-cgroupns",
+			cgroupfs: "/host/sys/fs/cgroup",
+			expect:   "/host/sys/fs/cgroup/docker/0f1e2d3c",
+		},
+		{
+			name:   "mount-root-mismatch",
+			procfs: "mount-root-mismatch",
+			err:    true,
+		},
+		{
+			name:     "cgroup-outside-namespace",
+			procfs:   "cgroup-outside-namespace",
+			cgroupfs: "/host/sys/fs/cgroup",
+			err:      true,
+		},
+		{
+			name:     "cgroup-v1",
+			procfs:   "cgroup-v1",
+			cgroupfs: "/host/sys/fs/cgroup",
+			err:      true,
+		},
+		{
+			name:   "missing-mountinfo-file",
+			procfs: "missing-mountinfo",
+			err:    true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			procfs := filepath.Join("testdata", "procfs", tc.procfs)
+			v, err := resolveCgroupInterfacePath(nil, procfs, tc.cgroupfs, filepath.Join(procfs, "root"))
+
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+
+				if v != "" {
+					t.Errorf("must return empty string when error is expected")
+				}
+			} else {
+				if err != nil {
+					t.Errorf("expected no error, got %s", err)
+				}
+				if tc.expect != filepath.ToSlash(v) {
+					t.Errorf("expected=%s, got=%s", tc.expect, v)
+				}
+			}
+		})
+	}
+
+	t.Run("EmptyProcFS", func(t *testing.T) {
+		_, err := resolveCgroupInterfacePath(nil, "", "", "")
+		if err == nil {
+			t.Errorf("expected an error, got nil")
+		}
+	})
+}
+
+func TestCgroupErrors(t *testing.T) {
+	tt := []struct {
+		name   string
+		procfs string
+		expect error
+		parse  bool
+	}{
+		{
+			name:   "cgroup-v1",
+			procfs: "cgroup-v1",
+			expect: ErrCgroupV1,
+		},
+		{
+			name:   "cgroup-mount-missing-from-mountinfo",
+			procfs: "cgroup-mount-missing",
+			expect: ErrNoCgroup2Mount,
+		},
+		{
+			name:   "cgroup-invalid",
+			procfs: "cgroup-invalid",
+			expect: ErrMalformed,
+			parse:  true,
+		},
+		{
+			name:   "cgroup-hybrid-duplicate",
+			procfs: "cgroup-hybrid-duplicate",
+			expect: ErrMalformed,
+			parse:  true,
+		},
+		{
+			name:   "invalid-cgroup",
+			procfs: "invalid-cgroup",
+			expect: ErrMalformed,
+			parse:  true,
+		},
+		{
+			name:   "mountinfo-invalid",
+			procfs: "mountinfo-invalid",
+			expect: ErrMalformed,
+			parse:  true,
+		},
+		{
+			name:   "missing-cgroup-file",
+			procfs: "missing-cgroup",
+			expect: os.ErrNotExist,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := GetCgroupInterfacePath(filepath.Join("testdata", "procfs", tc.procfs))
+			if !errors.Is(err, tc.expect) {
+				t.Errorf("expected error matching %q, got %v", tc.expect, err)
+			}
+
+			var perr *ParseError
+			if errors.As(err, &perr) != tc.parse {
+				t.Errorf("expected ParseError=%t, got %v", tc.parse, err)
+			}
+
+			if tc.parse && perr.Path == "" {
+				t.Errorf("expected ParseError to contain path")
+			}
+		})
+	}
+}
+
+func TestIsCgroupV1(t *testing.T) {
+	tt := []struct {
+		name     string
+		contents string
+		expect   bool
+	}{
+		{name: "v1", contents: "3:memory:/docker/large\n2:cpu,cpuacct:/docker\n1:cpuset:/", expect: true},
+		{name: "named-hierarchy", contents: "1:name=systemd:/user.slice", expect: true},
+		{name: "hybrid", contents: "1:name=systemd:/user.slice\n0::/user.slice"},
+		{name: "v2", contents: "0::/user.slice"},
+		{name: "empty"},
+		{name: "invalid", contents: "foo-bar"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if v := isCgroupV1([]byte(tc.contents)); v != tc.expect {
+				t.Errorf("expected=%t, got=%t", tc.expect, v)
+			}
+		})
+	}
+}
+
+func TestDefaultCgroupInterfacePath(t *testing.T) {
+	// Fallback depends on cgroup2 mount of the host running tests.
+	fallback := isNamespacedCgroupFS(cgroupfsRoot)
+	tt := []struct {
+		name   string
+		cgroup string
+		procfs string
+		expect string
+		err    bool
+	}{
+		{
+			name:   "cgroup-path",
+			cgroup: filepath.Join("testdata", "cgroup", "cpu-250"),
+			expect: filepath.Join("testdata", "cgroup", "cpu-250"),
+		},
+		{
+			name:   "cgroup-path-missing",
+			cgroup: filepath.Join("testdata", "cgroup", "no-such-dir"),
+			err:    true,
+		},
+		{
+			name:   "cgroup-path-not-directory",
+			cgroup: filepath.Join("testdata", "cgroup", "cpu-250", "cpu.max"),
+			err:    true,
+		},
+		{
+			name:   "cgroup-path-overrides-procfs",
+			cgroup: filepath.Join("testdata", "cgroup", "cpu-250"),
+			procfs: filepath.Join("testdata", "procfs", "missing-mountinfo"),
+			expect: filepath.Join("testdata", "cgroup", "cpu-250"),
+		},
+		{
+			name:   "procfs-missing-mountinfo",
+			procfs: filepath.Join("testdata", "procfs", "missing-mountinfo"),
+			err:    !fallback,
+		},
+		{
+			name:   "procfs-path-missing",
+			procfs: filepath.Join("testdata", "procfs", "systemd-system"),
+			err:    !fallback,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvCgroupPath, tc.cgroup)
+			t.Setenv(EnvProcFS, tc.procfs)
+			v, err := DefaultCgroupInterfacePath()
+
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+
+				if v != "" {
+					t.Errorf("must return empty string when error is expected")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+
+			expect := tc.expect
+			if expect == "" {
+				expect = cgroupfsRoot
+			}
+
+			if v != expect {
+				t.Errorf("expected=%s, got=%s", expect, v)
+			}
+		})
+	}
+
+	t.Run("not-cgroupfs", func(t *testing.T) {
+		if isNamespacedCgroupFS(t.TempDir()) {
+			t.Errorf("expected false for non cgroup2 directory")
+		}
+	})
+}
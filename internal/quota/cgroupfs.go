@@ -0,0 +1,784 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package quota
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FSDetector detects CPU and memory quota from cgroup interface files
+// in a [fs.FS].
+type FSDetector struct {
+	fsys fs.FS
+	path string
+}
+
+// NewFSDetector returns a [FSDetector] for process whose procfs directory within
+// fsys is procfs (for example, proc/1234). If cgroupfs is not empty, it is used as
+// root of the cgroup2 hierarchy. Otherwise, cgroup2 mount point of the process
+// is resolved relative to root of fsys.
+//
+// If fsys is nil, files are read with [os.Open] and cgroup2 mount point is resolved
+// relative to root directory of the process (procfs/root).
+func NewFSDetector(fsys fs.FS, procfs, cgroupfs string) (*FSDetector, error) {
+	rootfs := "/"
+	if fsys == nil {
+		rootfs = path.Join(procfs, "root")
+	}
+
+	interfacePath, err := resolveCgroupInterfacePath(fsys, procfs, cgroupfs, rootfs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FSDetector{fsys: fsys, path: interfacePath}, nil
+}
+
+// NewFSDetectorWithCgroupPath returns a [FSDetector] which reads cgroup interface
+// files from path within fsys, without resolving it. If fsys is nil, files are
+// read with [os.Open].
+func NewFSDetectorWithCgroupPath(fsys fs.FS, path string) *FSDetector {
+	return &FSDetector{fsys: fsys, path: path}
+}
+
+// InterfacePath returns path to cgroup interface files.
+func (d *FSDetector) InterfacePath() string {
+	return d.path
+}
+
+// DetectCPUQuota returns CPU quota from cpu.max interface file.
+func (d *FSDetector) DetectCPUQuota(ctx context.Context) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("quota(cgroup): %w", err)
+	}
+
+	quota, err := cpuQuotaFromFile(d.fsys, path.Join(d.path, "cpu.max"))
+	if err != nil {
+		return 0, fmt.Errorf("quota(cgroup): %w", err)
+	}
+	return quota, nil
+}
+
+// DetectMemoryQuota returns memory limits from memory.max and memory.high
+// interface files.
+//
+//nolint:nonamedreturns // for docs.
+func (d *FSDetector) DetectMemoryQuota(ctx context.Context) (max, high int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, 0, fmt.Errorf("quota(cgroup): %w", err)
+	}
+
+	max, err = memLimitFromFile(d.fsys, path.Join(d.path, "memory.max"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("quota(cgroup): failed to get memory max: %w", err)
+	}
+
+	high, err = memLimitFromFile(d.fsys, path.Join(d.path, "memory.high"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("quota(cgroup): failed to get memory high: %w", err)
+	}
+
+	return max, high, nil
+}
+
+// DetectCPUInfo returns CPU limits from cpu and cpuset interface files.
+func (d *FSDetector) DetectCPUInfo(ctx context.Context) (CPUInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return CPUInfo{}, fmt.Errorf("quota(cgroup): %w", err)
+	}
+	return cpuInfoFromDir(d.fsys, d.path)
+}
+
+// DetectProcs returns number of processes in the cgroup, from cgroup.procs
+// interface file. Zero is returned if it does not exist.
+func (d *FSDetector) DetectProcs(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("quota(cgroup): %w", err)
+	}
+	return procsFromDir(d.fsys, d.path)
+}
+
+// DetectMemoryInfo returns memory limits and usage from memory
+// interface files.
+func (d *FSDetector) DetectMemoryInfo(ctx context.Context) (MemoryInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return MemoryInfo{}, fmt.Errorf("quota(cgroup): %w", err)
+	}
+	return memoryInfoFromDir(d.fsys, d.path)
+}
+
+// openFile opens the named file from fsys. If fsys is nil, file is opened
+// with [os.Open]. Otherwise, name is converted to a path valid for [fs.FS].
+func openFile(fsys fs.FS, name string) (fs.File, error) {
+	if fsys == nil {
+		return os.Open(name)
+	}
+
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	if name == "" {
+		name = "."
+	}
+	return fsys.Open(name)
+}
+
+// relPath returns target relative to base. Both must be absolute slash
+// separated paths. false is returned if target is not within base.
+func relPath(base, target string) (string, bool) {
+	base, target = path.Clean(base), path.Clean(target)
+	switch {
+	case base == "/" || base == target:
+		return strings.TrimPrefix(strings.TrimPrefix(target, base), "/"), true
+	case strings.HasPrefix(target, base+"/"):
+		return strings.TrimPrefix(target, base+"/"), true
+	}
+	return "", false
+}
+
+// resolveCgroupInterfacePath returns base path of cgroup interface files for the
+// process whose procfs directory is procfs. If cgroupfs is empty, cgroup2 mount point
+// of the process is resolved relative to rootfs.
+func resolveCgroupInterfacePath(fsys fs.FS, procfs, cgroupfs, rootfs string) (string, error) {
+	if procfs == "" {
+		return "", errors.New("quota(cgroup): procfs directory is not specified")
+	}
+
+	name, err := cgroupNameFromFile(fsys, path.Join(procfs, "cgroup"))
+	if err != nil {
+		return "", fmt.Errorf("quota(cgroup): failed to get cgroup name: %w", err)
+	}
+
+	// cgroup name is relative to cgroup namespace of the current process. If the
+	// process belongs to a cgroup outside of it, path cannot be resolved.
+	if name == "/.." || strings.HasPrefix(name, "/../") {
+		return "", fmt.Errorf("quota(cgroup): cgroup %q is outside of current cgroup namespace", name)
+	}
+
+	if cgroupfs != "" {
+		return path.Join(cgroupfs, name), nil
+	}
+
+	mount, err := cgroupMountFromFile(fsys, path.Join(procfs, "mountinfo"))
+	if err != nil {
+		return "", fmt.Errorf("quota(cgroup): failed to get cgroup2 mountpoint: %w", err)
+	}
+
+	// Only a part of the hierarchy may be mounted, typically when process
+	// is running in a container without a cgroup namespace.
+	rel, ok := relPath(mount.Root, name)
+	if !ok {
+		return "", fmt.Errorf("quota(cgroup): cgroup %q is not within cgroup2 mount root %q", name, mount.Root)
+	}
+
+	return path.Join(rootfs, mount.MountPoint, rel), nil
+}
+
+// cgroupNameFromFile returns cgroup name for given cgroup file.
+func cgroupNameFromFile(fsys fs.FS, path string) (string, error) {
+	// Try to open file.
+	file, err := openFile(fsys, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	// If file is too large do not read it.
+	stat, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to check file size: %w", err)
+	}
+
+	// On hybrid hosts, file lists all cgroup v1 hierarchies, which may have
+	// long paths, for example with container IDs.
+	if stat.Size() > 64*1024 {
+		return "", &ParseError{
+			Path: path,
+			Err:  fmt.Errorf("file too large: %d", stat.Size()),
+		}
+	}
+
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read contents for cgroup file %s: %w", path, err)
+	}
+
+	// /proc/self/cgroup (since Linux 2.6.24)
+	// This file describes control groups to which the process with the corresponding PID
+	// belongs. The displayed information differs for cgroups version 1 and version 2 hierarchies.
+	// For each cgroup hierarchy of which the process is a member, there is one entry containing
+	// three colon-separated fields:
+	//
+	// hierarchy-ID:controller-list:cgroup-path
+	//
+	// The colon-separated fields are, from left to right:
+	//
+	// 1. For the cgroups version 2 hierarchy, this field contains the value 0.
+	// 2. For the cgroups version 2 hierarchy, this field is empty.
+	// 3. This field contains the pathname of the control group in the hierarchy to which the
+	//    process belongs. This pathname is relative to the mount point of the hierarchy.
+	//
+	// https://manpages.debian.org/buster/manpages/cgroups.7.en.html
+	//
+	// On hybrid hosts, cgroup v1 hierarchies are listed along with cgroup v2
+	// hierarchy, typically before it. Thus, all lines are scanned for the
+	// cgroup v2 entry, and others must be cgroup v1 entries.
+	var rv string
+	var found bool
+	var others []string
+	for _, line := range strings.Split(string(bytes.TrimSpace(contents)), "\n") {
+		name, ok := strings.CutPrefix(line, "0::")
+		if !ok {
+			others = append(others, line)
+			continue
+		}
+
+		if found {
+			return "", &ParseError{
+				Path:    path,
+				Content: string(contents),
+				Err:     errors.New("multiple '0::' entries"),
+			}
+		}
+		rv, found = strings.TrimSpace(name), true
+	}
+
+	if !found {
+		// Only cgroup v1 hierarchies are present, if all lines are
+		// of the form hierarchy-ID:controller-list:cgroup-path.
+		if isCgroupV1(contents) {
+			return "", fmt.Errorf("%s: %w", path, ErrCgroupV1)
+		}
+		return "", &ParseError{
+			Path:    path,
+			Content: string(contents),
+			Err:     errors.New("missing prefix '0::'"),
+		}
+	}
+
+	if len(others) > 0 && !isCgroupV1([]byte(strings.Join(others, "\n"))) {
+		return "", &ParseError{
+			Path:    path,
+			Content: string(contents),
+			Err:     errors.New("invalid cgroup v1 entries"),
+		}
+	}
+
+	if rv == "" {
+		return "", &ParseError{
+			Path:    path,
+			Content: string(contents),
+			Err:     errors.New("empty cgroup path"),
+		}
+	}
+	return rv, nil
+}
+
+// cgroupMount is a cgroup2 mount from mountinfo file.
+type cgroupMount struct {
+	// Root of the mount within cgroup2 hierarchy.
+	Root string
+
+	// Mount point relative to root directory of the process.
+	MountPoint string
+
+	// Matching line from mountinfo file.
+	Line string
+}
+
+// cgroupMountFromFile parses given mountinfo file and extracts cgroup
+// v2 mount from it.
+func cgroupMountFromFile(fsys fs.FS, mountInfo string) (cgroupMount, error) {
+	file, err := openFile(fsys, mountInfo)
+	if err != nil {
+		return cgroupMount{}, fmt.Errorf("failed to open: %w", err)
+	}
+	defer file.Close()
+
+	// Read mount info file
+	// See https://manpages.debian.org/buster/manpages/proc.5.en.html
+	//
+	// The file contains lines of the form:
+	//
+	// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+	// (1)(2)(3)   (4)   (5)      (6)      (7)   (8) (9)   (10)         (11)
+	//
+	// The numbers in parentheses are labels for the descriptions
+	// below:
+	//
+	// (1)  mount ID: a unique ID for the mount (may be reused
+	//      after umount(2)).
+	//
+	// (2)  parent ID: the ID of the parent mount (or of self for
+	//      the root of this mount namespace's mount tree).
+	//
+	// (3)  major:minor: the value of st_dev for files on this
+	//      filesystem.
+	//
+	// (4)  root: the pathname of the directory in the filesystem
+	//      which forms the root of this mount.
+	//
+	// (5)  mount point: the pathname of the mount point relative
+	//      to the process's root directory.
+	//
+	// (6)  mount options: per-mount options .
+	//
+	// (7)  optional fields: zero or more fields of the form
+	//      "tag[:value]"; see below.
+	//
+	// (8)  separator: the end of the optional fields is marked
+	//      by a single hyphen.
+	//
+	// (9)  filesystem type: the filesystem type in the form
+	//      "type[.subtype]".
+	//
+	// (10) mount source: filesystem-specific information or
+	//      "none".
+	//
+	// (11) super options: per-superblock options.
+	var v1 bool
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		var err error
+		text := s.Text()
+		fields := strings.Split(text, " ")
+		numFields := len(fields)
+		if numFields < 10 {
+			// Should be at least 10 fields
+			return cgroupMount{}, &ParseError{
+				Path:    mountInfo,
+				Content: text,
+				Err:     fmt.Errorf("not enough fields (%d)", numFields),
+			}
+		}
+
+		// Separator field
+		sepIdx := numFields - 4
+
+		// Check if type is valid
+		fsType, err := unescape(fields[sepIdx+1])
+		if err != nil {
+			return cgroupMount{}, &ParseError{
+				Path:    mountInfo,
+				Content: text,
+				Err:     fmt.Errorf("fstype: %w", err),
+			}
+		}
+
+		if fsType == "cgroup" {
+			v1 = true
+		}
+
+		if fsType != "cgroup2" {
+			continue
+		}
+
+		root, err := unescape(fields[3])
+		if err != nil {
+			return cgroupMount{}, &ParseError{
+				Path:    mountInfo,
+				Content: text,
+				Err:     fmt.Errorf("root: %w", err),
+			}
+		}
+
+		mountpoint, err := unescape(fields[4])
+		if err != nil {
+			return cgroupMount{}, &ParseError{
+				Path:    mountInfo,
+				Content: text,
+				Err:     fmt.Errorf("mount point: %w", err),
+			}
+		}
+
+		return cgroupMount{Root: root, MountPoint: mountpoint, Line: text}, nil
+	}
+
+	if err := s.Err(); err != nil {
+		return cgroupMount{}, fmt.Errorf("failed to scan %s: %w", mountInfo, err)
+	}
+
+	if v1 {
+		return cgroupMount{}, fmt.Errorf("%s: %w", mountInfo, ErrCgroupV1)
+	}
+	return cgroupMount{}, fmt.Errorf("%s: %w", mountInfo, ErrNoCgroup2Mount)
+}
+
+// isCgroupV1 reports whether contents of cgroup file only
+// contain cgroup v1 hierarchies.
+func isCgroupV1(contents []byte) bool {
+	lines := strings.Split(string(bytes.TrimSpace(contents)), "\n")
+	for _, line := range lines {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 || fields[1] == "" {
+			return false
+		}
+		if _, err := strconv.ParseUint(fields[0], 10, 32); err != nil || fields[0] == "0" {
+			return false
+		}
+	}
+	return len(lines) > 0 && lines[0] != ""
+}
+
+// cpuQuotaFromFile reads cpu.max file and returns CPU quota. Zero is returned
+// if the file does not exist or quota is not defined.
+func cpuQuotaFromFile(fsys fs.FS, path string) (float64, error) {
+	max, period, err := cpuMaxFromFile(fsys, path)
+	if err != nil || max == 0 {
+		return 0, err
+	}
+	return float64(max) / float64(period), nil
+}
+
+// cpuMaxFromFile reads cpu.max file and returns quota and period in microseconds.
+// Zero quota is returned if the file does not exist or quota is not defined.
+// Period is zero only if the file does not exist.
+//
+//nolint:nonamedreturns // for docs.
+func cpuMaxFromFile(fsys fs.FS, path string) (max, period uint64, err error) {
+	file, err := openFile(fsys, path)
+	if err != nil {
+		// If file is missing then cpu controller is not enabled
+		// or cpu limits are not defined.
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if scanner.Scan() {
+		text := scanner.Text()
+		fields := strings.Fields(text)
+		if len(fields) == 0 || len(fields) > 2 {
+			return 0, 0, &ParseError{Path: path, Content: text}
+		}
+
+		// Check if period is defined.
+		if len(fields) == 2 {
+			period, err = strconv.ParseUint(fields[1], 10, 64)
+			if err != nil || period == 0 {
+				return 0, 0, &ParseError{Path: path, Content: text, Err: err}
+			}
+		} else {
+			// Default CPU period value.
+			period = 100000
+		}
+
+		// No CPU limits.
+		if fields[0] == "max" {
+			return 0, period, nil
+		}
+
+		// Get Maximum CPU quota
+		max, err = strconv.ParseUint(fields[0], 10, 64)
+		if err != nil || max == 0 {
+			return 0, 0, &ParseError{Path: path, Content: text, Err: err}
+		}
+
+		return max, period, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to scan %s: %w", path, err)
+	}
+
+	return 0, 0, &ParseError{Path: path, Err: io.ErrUnexpectedEOF}
+}
+
+// cpuSetFromFile reads cpuset.cpus.effective file and returns number of CPUs
+// in it. Zero is returned if the file does not exist or is empty.
+func cpuSetFromFile(fsys fs.FS, path string) (int, error) {
+	text, err := readInterfaceFile(fsys, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if text == "" {
+		return 0, nil
+	}
+
+	// Format is comma separated list of CPUs or ranges, for example 0-3,5,7-8.
+	var count int
+	for _, item := range strings.Split(text, ",") {
+		lo, hi, found := strings.Cut(item, "-")
+		start, err := strconv.ParseUint(lo, 10, 32)
+		if err != nil {
+			return 0, &ParseError{Path: path, Content: text, Err: err}
+		}
+
+		end := start
+		if found {
+			end, err = strconv.ParseUint(hi, 10, 32)
+			if err != nil || end < start {
+				return 0, &ParseError{Path: path, Content: text, Err: err}
+			}
+		}
+		count += int(end-start) + 1
+	}
+	return count, nil
+}
+
+// intFromFile reads an interface file containing a single non-negative integer,
+// like cpu.weight or cpu.max.burst. Zero is returned if the file does not exist.
+func intFromFile(fsys fs.FS, path string) (int64, error) {
+	text, err := readInterfaceFile(fsys, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || v < 0 {
+		return 0, &ParseError{Path: path, Content: text, Err: err}
+	}
+	return v, nil
+}
+
+// cpuInfoFromDir reads cpu and cpuset interface files in cgroup directory dir.
+// Missing files are ignored, as they depend on enabled controllers and kernel
+// configuration, and are not defined for root cgroup. Only cpu.max determines
+// CPU quota, thus errors reading other files are ignored, and their values
+// are left as zero, i.e unknown.
+func cpuInfoFromDir(fsys fs.FS, dir string) (CPUInfo, error) {
+	info := CPUInfo{Source: "cgroup"}
+	max, period, err := cpuMaxFromFile(fsys, path.Join(dir, "cpu.max"))
+	if err != nil {
+		return CPUInfo{}, fmt.Errorf("quota(cgroup): failed to get cpu.max: %w", err)
+	}
+
+	if max > 0 {
+		info.Quota = float64(max) / float64(period)
+	}
+	info.Period = time.Duration(period) * time.Microsecond
+
+	if burst, err := intFromFile(fsys, path.Join(dir, "cpu.max.burst")); err == nil {
+		info.Burst = time.Duration(burst) * time.Microsecond
+	}
+
+	if weight, err := intFromFile(fsys, path.Join(dir, "cpu.weight")); err == nil {
+		info.Weight = int(weight)
+	}
+
+	if cpuset, err := cpuSetFromFile(fsys, path.Join(dir, "cpuset.cpus.effective")); err == nil {
+		info.CPUSet = cpuset
+	}
+
+	return info, nil
+}
+
+// memLimitFromFile reads memory.max or memory.high file. Zero is returned
+// if the file does not exist or limit is not defined.
+func memLimitFromFile(fsys fs.FS, path string) (int64, error) {
+	file, err := openFile(fsys, path)
+	if err != nil {
+		// If file is missing then memory controller is not enabled
+		// or memory limits are not defined.
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if scanner.Scan() {
+		text := scanner.Text()
+		fields := strings.Fields(text)
+		if len(fields) != 1 {
+			return 0, &ParseError{Path: path, Content: text}
+		}
+
+		// No memory limits.
+		if fields[0] == "max" {
+			return 0, nil
+		}
+
+		max, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, &ParseError{Path: path, Content: text, Err: err}
+		}
+
+		if max < 0 {
+			return 0, &ParseError{Path: path, Content: text, Err: errors.New("negative value")}
+		}
+
+		return max, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to scan %s: %w", path, err)
+	}
+
+	return 0, &ParseError{Path: path, Err: io.ErrUnexpectedEOF}
+}
+
+// memoryInfoFromDir reads memory interface files in cgroup directory dir.
+// Missing files are ignored, as they depend on enabled controllers and kernel
+// configuration, and are not defined for root cgroup. memory.stat is only used
+// for memory usage breakdown, thus errors reading it are ignored, and memory
+// usage breakdown is left as zero, i.e unknown.
+func memoryInfoFromDir(fsys fs.FS, dir string) (MemoryInfo, error) {
+	info := MemoryInfo{Source: "cgroup"}
+	for _, item := range []struct {
+		name  string
+		value *int64
+	}{
+		{name: "memory.max", value: &info.Max},
+		{name: "memory.high", value: &info.High},
+		{name: "memory.low", value: &info.Low},
+		{name: "memory.min", value: &info.Min},
+		{name: "memory.swap.max", value: &info.SwapMax},
+		// memory.current and memory.peak are not limits, but have same format.
+		{name: "memory.current", value: &info.Current},
+		{name: "memory.peak", value: &info.Peak},
+	} {
+		v, err := memLimitFromFile(fsys, path.Join(dir, item.name))
+		if err != nil {
+			return MemoryInfo{}, fmt.Errorf("quota(cgroup): failed to get %s: %w", item.name, err)
+		}
+		*item.value = v
+	}
+
+	if stat, err := memoryStatFromFile(fsys, path.Join(dir, "memory.stat")); err == nil {
+		info.Anon = stat["anon"]
+		info.File = stat["file"]
+		info.Sock = stat["sock"]
+
+		// kernel key is only available on Linux 5.18 or later.
+		if v, ok := stat["kernel"]; ok {
+			info.Kernel = v
+		} else {
+			info.Kernel = stat["kernel_stack"] + stat["pagetables"] + stat["percpu"] + stat["slab"]
+		}
+	}
+
+	return info, nil
+}
+
+// InterfaceFiles is a list of cgroup interface files read by [FSDetector]
+// for detecting limits, usage and number of processes.
+//
+//nolint:gochecknoglobals // read only list.
+var InterfaceFiles = []string{
+	"cpu.max",
+	"cpu.max.burst",
+	"cpu.weight",
+	"cpuset.cpus.effective",
+	"memory.max",
+	"memory.high",
+	"memory.low",
+	"memory.min",
+	"memory.swap.max",
+	"memory.current",
+	"memory.peak",
+	"memory.stat",
+	"cgroup.procs",
+}
+
+// procsFromDir returns number of processes in cgroup directory dir.
+func procsFromDir(fsys fs.FS, dir string) (int, error) {
+	procs, err := procsFromFile(fsys, path.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return 0, fmt.Errorf("quota(cgroup): failed to get cgroup.procs: %w", err)
+	}
+	return procs, nil
+}
+
+// procsFromFile returns number of processes listed in cgroup.procs file.
+// Zero is returned if the file does not exist.
+func procsFromFile(fsys fs.FS, path string) (int, error) {
+	file, err := openFile(fsys, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var count int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if _, err := strconv.ParseUint(text, 10, 32); err != nil {
+			return 0, &ParseError{Path: path, Content: text, Err: err}
+		}
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to scan %s: %w", path, err)
+	}
+	return count, nil
+}
+
+// memoryStatFromFile reads flat keyed memory.stat file. Nil map is returned
+// if the file does not exist.
+func memoryStatFromFile(fsys fs.FS, path string) (map[string]int64, error) {
+	file, err := openFile(fsys, path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	stat := make(map[string]int64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		text := scanner.Text()
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, &ParseError{Path: path, Content: text}
+		}
+
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, &ParseError{Path: path, Content: text, Err: err}
+		}
+		stat[fields[0]] = v
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", path, err)
+	}
+	return stat, nil
+}
+
+// readInterfaceFile reads raw contents of an interface file.
+func readInterfaceFile(fsys fs.FS, path string) (string, error) {
+	file, err := openFile(fsys, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	// Interface files used are always small, avoid reading large files.
+	buf, err := io.ReadAll(io.LimitReader(file, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(bytes.TrimSpace(buf)), nil
+}
@@ -0,0 +1,442 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package quota
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+)
+
+func TestFSDetector(t *testing.T) {
+	const mountinfo = "33 24 0:28 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9" +
+		" - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot\n"
+
+	fsys := fstest.MapFS{
+		"proc/self/mountinfo": {Data: []byte(mountinfo)},
+		"proc/self/cgroup":    {Data: []byte("0::/system.slice/run-u1801.service\n")},
+		"proc/1234/mountinfo": {Data: []byte(mountinfo)},
+		"proc/1234/cgroup":    {Data: []byte("0::/system.slice/invalid.service\n")},
+		"proc/4321/mountinfo": {Data: []byte("21 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n")},
+		"proc/4321/cgroup":    {Data: []byte("0::/\n")},
+		"sys/fs/cgroup/system.slice/run-u1801.service/cpu.max":     {Data: []byte("150000 100000\n")},
+		"sys/fs/cgroup/system.slice/run-u1801.service/memory.max":  {Data: []byte("314572800\n")},
+		"sys/fs/cgroup/system.slice/run-u1801.service/memory.high": {Data: []byte("262144000\n")},
+		"sys/fs/cgroup/system.slice/invalid.service/cpu.max":       {Data: []byte("foo\n")},
+		"sys/fs/cgroup/system.slice/invalid.service/memory.max":    {Data: []byte("bar\n")},
+	}
+
+	tt := []struct {
+		name     string
+		procfs   string
+		cgroupfs string
+		path     string
+		cpu      float64
+		max      int64
+		high     int64
+		err      error
+	}{
+		{
+			name:   "MountInfo",
+			procfs: "/proc/self",
+			path:   "/sys/fs/cgroup/system.slice/run-u1801.service",
+			cpu:    1.5,
+			max:    300 * shared.MiByte,
+			high:   250 * shared.MiByte,
+		},
+		{
+			name:     "CgroupFS",
+			procfs:   "proc/self",
+			cgroupfs: "sys/fs/cgroup",
+			path:     "sys/fs/cgroup/system.slice/run-u1801.service",
+			cpu:      1.5,
+			max:      300 * shared.MiByte,
+			high:     250 * shared.MiByte,
+		},
+		{
+			name:   "Malformed",
+			procfs: "proc/1234",
+			path:   "/sys/fs/cgroup/system.slice/invalid.service",
+			err:    ErrMalformed,
+		},
+		{
+			name:   "NoCgroup2Mount",
+			procfs: "proc/4321",
+			err:    ErrNoCgroup2Mount,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewFSDetector(fsys, tc.procfs, tc.cgroupfs)
+			if err == nil {
+				if v := d.InterfacePath(); v != tc.path {
+					t.Errorf("InterfacePath expected=%s, got=%s", tc.path, v)
+				}
+
+				var cpu float64
+				cpu, err = d.DetectCPUQuota(context.Background())
+				if err == nil && cpu != tc.cpu {
+					t.Errorf("cpu expected=%f, got=%f", tc.cpu, cpu)
+				}
+
+				if err == nil {
+					var max, high int64
+					max, high, err = d.DetectMemoryQuota(context.Background())
+					if err == nil && (max != tc.max || high != tc.high) {
+						t.Errorf("memory expected=(%d,%d), got=(%d,%d)", tc.max, tc.high, max, high)
+					}
+				}
+			}
+
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected error matching %q, got %v", tc.err, err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+		})
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	tt := []struct {
+		base   string
+		target string
+		expect string
+		ok     bool
+	}{
+		{base: "/", target: "/", expect: "", ok: true},
+		{base: "/", target: "/system.slice", expect: "system.slice", ok: true},
+		{base: "/docker/abc", target: "/docker/abc", expect: "", ok: true},
+		{base: "/docker/abc", target: "/docker/abc/child", expect: "child", ok: true},
+		{base: "/docker/abc", target: "/docker/abcd"},
+		{base: "/docker/abc", target: "/system.slice"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.base+":"+tc.target, func(t *testing.T) {
+			v, ok := relPath(tc.base, tc.target)
+			if v != tc.expect || ok != tc.ok {
+				t.Errorf("expected=(%q,%t), got=(%q,%t)", tc.expect, tc.ok, v, ok)
+			}
+		})
+	}
+}
+
+func TestMemoryInfoFromDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"all/memory.max":      {Data: []byte("314572800\n")},
+		"all/memory.high":     {Data: []byte("262144000\n")},
+		"all/memory.low":      {Data: []byte("52428800\n")},
+		"all/memory.min":      {Data: []byte("0\n")},
+		"all/memory.swap.max": {Data: []byte("max\n")},
+		"all/memory.current":  {Data: []byte("104857600\n")},
+		"all/memory.peak":     {Data: []byte("157286400\n")},
+		"all/cgroup.procs":    {Data: []byte("1\n42\n\n")},
+		"all/memory.stat": {
+			Data: []byte("anon 52428800\nfile 41943040\nkernel 8388608\nkernel_stack 1048576\nsock 1048576\n"),
+		},
+		"some/memory.max": {Data: []byte("314572800\n")},
+		"some/memory.stat": {
+			Data: []byte("anon 52428800\nkernel_stack 1048576\npagetables 1048576\npercpu 1048576\nslab 1048576\n"),
+		},
+		"invalid/memory.low":       {Data: []byte("foo\n")},
+		"invalid/memory.max":       {Data: []byte("max\n")},
+		"invalid/memory.high":      {Data: []byte("max\n")},
+		"invalid-stat/memory.max":  {Data: []byte("314572800\n")},
+		"invalid-stat/memory.stat": {Data: []byte("anon foo\n")},
+		"none/cgroup.controls":     {Data: []byte("\n")},
+	}
+
+	tt := []struct {
+		name   string
+		dir    string
+		expect MemoryInfo
+		err    error
+	}{
+		{
+			name: "All",
+			dir:  "all",
+			expect: MemoryInfo{
+				Max:     300 * shared.MiByte,
+				High:    250 * shared.MiByte,
+				Low:     50 * shared.MiByte,
+				Current: 100 * shared.MiByte,
+				Peak:    150 * shared.MiByte,
+				Anon:    50 * shared.MiByte,
+				File:    40 * shared.MiByte,
+				Kernel:  8 * shared.MiByte,
+				Sock:    shared.MiByte,
+				Source:  "cgroup",
+			},
+		},
+		{
+			name: "Some",
+			dir:  "some",
+			expect: MemoryInfo{
+				Max:    300 * shared.MiByte,
+				Anon:   50 * shared.MiByte,
+				Kernel: 4 * shared.MiByte,
+				Source: "cgroup",
+			},
+		},
+		{
+			name:   "None",
+			dir:    "none",
+			expect: MemoryInfo{Source: "cgroup"},
+		},
+		{
+			name: "Invalid",
+			dir:  "invalid",
+			err:  ErrMalformed,
+		},
+		{
+			name: "InvalidStat",
+			dir:  "invalid-stat",
+			expect: MemoryInfo{
+				Max:    300 * shared.MiByte,
+				Source: "cgroup",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := memoryInfoFromDir(fsys, tc.dir)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected error matching %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if info != tc.expect {
+				t.Errorf("expected=%+v, got=%+v", tc.expect, info)
+			}
+		})
+	}
+}
+
+func TestCPUInfoFromDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"all/cpu.max":                          {Data: []byte("150000 50000\n")},
+		"all/cpu.max.burst":                    {Data: []byte("20000\n")},
+		"all/cpu.weight":                       {Data: []byte("59\n")},
+		"all/cpuset.cpus.effective":            {Data: []byte("0-3,6,8-9\n")},
+		"all/cgroup.procs":                     {Data: []byte("1\n42\n")},
+		"invalid-procs/cgroup.procs":           {Data: []byte("foo\n")},
+		"unlimited/cpu.max":                    {Data: []byte("max 100000\n")},
+		"unlimited/cpu.weight":                 {Data: []byte("100\n")},
+		"unlimited/cpuset.cpus.effective":      {Data: []byte("\n")},
+		"none/cgroup.controllers":              {Data: []byte("\n")},
+		"invalid-cpuset/cpuset.cpus.effective": {Data: []byte("3-1\n")},
+		"invalid-weight/cpu.weight":            {Data: []byte("foo\n")},
+		"unreadable-weight/cpu.max":            {Data: []byte("200000 100000\n")},
+		"unreadable-weight/cpu.max.burst":      {Data: []byte("foo\n")},
+		"unreadable-weight/cpu.weight":         {Mode: fs.ModeDir},
+		"invalid-max/cpu.max":                  {Data: []byte("foo 100000\n")},
+		"invalid-max/cpu.weight":               {Data: []byte("100\n")},
+	}
+
+	tt := []struct {
+		name   string
+		dir    string
+		expect CPUInfo
+		err    error
+	}{
+		{
+			name: "All",
+			dir:  "all",
+			expect: CPUInfo{
+				Quota:  3,
+				Period: 50 * time.Millisecond,
+				Burst:  20 * time.Millisecond,
+				CPUSet: 7,
+				Weight: 59,
+				Source: "cgroup",
+			},
+		},
+		{
+			name: "Unlimited",
+			dir:  "unlimited",
+			expect: CPUInfo{
+				Period: 100 * time.Millisecond,
+				Weight: 100,
+				Source: "cgroup",
+			},
+		},
+		{
+			name:   "None",
+			dir:    "none",
+			expect: CPUInfo{Source: "cgroup"},
+		},
+		{
+			name:   "InvalidCPUSet",
+			dir:    "invalid-cpuset",
+			expect: CPUInfo{Source: "cgroup"},
+		},
+		{
+			name:   "InvalidWeight",
+			dir:    "invalid-weight",
+			expect: CPUInfo{Source: "cgroup"},
+		},
+		{
+			name: "UnreadableWeight",
+			dir:  "unreadable-weight",
+			expect: CPUInfo{
+				Quota:  2,
+				Period: 100 * time.Millisecond,
+				Source: "cgroup",
+			},
+		},
+		{
+			name: "InvalidMax",
+			dir:  "invalid-max",
+			err:  ErrMalformed,
+		},
+		{
+			name:   "InvalidProcs",
+			dir:    "invalid-procs",
+			expect: CPUInfo{Source: "cgroup"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := cpuInfoFromDir(fsys, tc.dir)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected error matching %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if info != tc.expect {
+				t.Errorf("expected=%+v, got=%+v", tc.expect, info)
+			}
+		})
+	}
+}
+
+func TestProcsFromDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"all/cgroup.procs":        {Data: []byte("1\n42\n\n")},
+		"empty/cgroup.procs":      {Data: []byte("\n")},
+		"invalid/cgroup.procs":    {Data: []byte("foo\n")},
+		"none/cgroup.controllers": {Data: []byte("\n")},
+	}
+
+	tt := []struct {
+		name   string
+		dir    string
+		expect int
+		err    error
+	}{
+		{
+			name:   "All",
+			dir:    "all",
+			expect: 2,
+		},
+		{
+			name: "Empty",
+			dir:  "empty",
+		},
+		{
+			name: "None",
+			dir:  "none",
+		},
+		{
+			name: "Invalid",
+			dir:  "invalid",
+			err:  ErrMalformed,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			procs, err := procsFromDir(fsys, tc.dir)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected error matching %q, got %v", tc.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if procs != tc.expect {
+				t.Errorf("expected=%d, got=%d", tc.expect, procs)
+			}
+		})
+	}
+}
+
+// recordFS is a [fs.FS] which records names of files opened.
+type recordFS struct {
+	fs.FS
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recordFS) Open(name string) (fs.File, error) {
+	r.mu.Lock()
+	r.names = append(r.names, path.Base(name))
+	r.mu.Unlock()
+	return r.FS.Open(name)
+}
+
+// TestInterfaceFiles ensures that InterfaceFiles contains exactly
+// the files read by the detector, as they are captured in bundles.
+func TestInterfaceFiles(t *testing.T) {
+	const dir = "sys/fs/cgroup/example.service"
+	fsys := &recordFS{FS: fstest.MapFS{
+		dir + "/cpu.max":               {Data: []byte("150000 100000\n")},
+		dir + "/cpu.max.burst":         {Data: []byte("0\n")},
+		dir + "/cpu.weight":            {Data: []byte("100\n")},
+		dir + "/cpuset.cpus.effective": {Data: []byte("0-3\n")},
+		dir + "/memory.max":            {Data: []byte("314572800\n")},
+		dir + "/memory.high":           {Data: []byte("max\n")},
+		dir + "/memory.low":            {Data: []byte("0\n")},
+		dir + "/memory.min":            {Data: []byte("0\n")},
+		dir + "/memory.swap.max":       {Data: []byte("max\n")},
+		dir + "/memory.current":        {Data: []byte("104857600\n")},
+		dir + "/memory.peak":           {Data: []byte("157286400\n")},
+		dir + "/memory.stat":           {Data: []byte("anon 52428800\nfile 41943040\n")},
+		dir + "/cgroup.procs":          {Data: []byte("1\n42\n")},
+	}}
+	d := NewFSDetectorWithCgroupPath(fsys, dir)
+	ctx := context.Background()
+	_, _ = d.DetectCPUQuota(ctx)
+	_, _, _ = d.DetectMemoryQuota(ctx)
+	_, _ = d.DetectCPUInfo(ctx)
+	_, _ = d.DetectMemoryInfo(ctx)
+	_, _ = d.DetectProcs(ctx)
+
+	slices.Sort(fsys.names)
+	opened := slices.Compact(fsys.names)
+	expect := slices.Clone(InterfaceFiles)
+	slices.Sort(expect)
+	if !slices.Equal(opened, expect) {
+		t.Errorf("expected=%v, got=%v", expect, opened)
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package quota
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoCgroup2Mount is returned when cgroup2 filesystem is not mounted.
+	ErrNoCgroup2Mount = errors.New("cgroup2 mount not found")
+
+	// ErrCgroupV1 is returned when only cgroup v1 hierarchies are available.
+	ErrCgroupV1 = errors.New("only cgroup v1 is available")
+
+	// ErrMalformed is returned when a file has invalid format. Errors
+	// matching it are always of type [*ParseError].
+	ErrMalformed = errors.New("malformed file")
+)
+
+// ParseError is returned when a file cannot be parsed.
+// It matches [ErrMalformed] with [errors.Is].
+type ParseError struct {
+	// Path of the file.
+	Path string
+
+	// Offending content.
+	Content string
+
+	// Underlying error, if any.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid format %s: %q: %s", e.Path, e.Content, e.Err)
+	}
+	return fmt.Sprintf("invalid format %s: %q", e.Path, e.Content)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is [ErrMalformed].
+func (e *ParseError) Is(target error) bool {
+	return target == ErrMalformed
+}
@@ -12,3 +12,67 @@
 //
 // [QueryInformationJobObject]: https://learn.microsoft.com/en-us/windows/desktop/api/jobapi2/nf-jobapi2-queryinformationjobobject
 package quota
+
+import "time"
+
+// MemoryInfo describes memory limits and usage of the workload.
+// All values are in bytes and zero indicates not defined or not supported.
+type MemoryInfo struct {
+	// Hard memory limit.
+	Max int64
+
+	// Soft memory limit.
+	High int64
+
+	// Best effort memory protection.
+	Low int64
+
+	// Hard memory protection.
+	Min int64
+
+	// Swap limit.
+	SwapMax int64
+
+	// Current memory usage.
+	Current int64
+
+	// Peak memory usage.
+	Peak int64
+
+	// Anonymous memory usage, like anon in memory.stat.
+	Anon int64
+
+	// Page cache usage, like file in memory.stat.
+	File int64
+
+	// Kernel memory usage, like kernel in memory.stat.
+	Kernel int64
+
+	// Network socket buffer usage, like sock in memory.stat.
+	Sock int64
+
+	// Source of memory info, for example cgroup or jobobject.
+	Source string
+}
+
+// CPUInfo describes CPU limits of the workload. Zero values indicate not
+// defined or not supported.
+type CPUInfo struct {
+	// CPU quota, in number of CPUs.
+	Quota float64
+
+	// Period over which quota is enforced.
+	Period time.Duration
+
+	// Burst allowed over quota.
+	Burst time.Duration
+
+	// Number of CPUs in effective cpuset.
+	CPUSet int
+
+	// Relative CPU weight, in range [1, 10000].
+	Weight int
+
+	// Source of CPU info, for example cgroup or jobobject.
+	Source string
+}
@@ -6,111 +6,122 @@
 package quota
 
 import (
-	"bufio"
 	"context"
-	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"sync/atomic"
 )
 
+// Detector detects CPU and memory limits from cgroup v2 interface files.
+// It is safe for concurrent use.
 type Detector struct {
 	cgroupfs string
+
+	// Path resolved with DefaultCgroupInterfacePath, if cgroupfs is empty.
+	resolved atomic.Pointer[string]
 }
 
 // NewDetectorWithCgroupPath returns a [Detector] with custom path to cgroup interface files.
 // This is typically useful in tests or to avoid parsing mountinfo file more than once and re-use
 // detected cgroup interface paths. Use [GetCgroupInterfacePath] for computing path to cgroup
-// interface files.
+// interface files. If path is empty, it is resolved with [DefaultCgroupInterfacePath]
+// on first use, and again if the cgroup is removed, for example when the process
+// is moved to another cgroup.
 func NewDetectorWithCgroupPath(path string) *Detector {
 	return &Detector{
 		cgroupfs: path,
 	}
 }
 
-func (d *Detector) DetectCPUQuota(_ context.Context) (float64, error) {
-	var err error
-	if d.cgroupfs == "" {
-		d.cgroupfs, err = GetCgroupInterfacePath("")
+// path returns path to cgroup interface files, resolving it with
+// [DefaultCgroupInterfacePath] if necessary. Returns an error if ctx is done.
+func (d *Detector) path(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("quota(cgroup): %w", err)
 	}
 
-	if err != nil {
-		return 0, err
+	if d.cgroupfs != "" {
+		return d.cgroupfs, nil
 	}
 
-	file, err := os.Open(filepath.Join(d.cgroupfs, "cpu.max"))
-	if err != nil {
-		// If file is missing then cpu controller is not enabled
-		// or cpu limits are not defined.
-		if errors.Is(err, os.ErrNotExist) {
-			return 0, nil
+	// Re-resolve if cgroup no longer exists.
+	if v := d.resolved.Load(); v != nil {
+		if _, err := os.Stat(*v); err == nil {
+			return *v, nil
 		}
 	}
 
-	scanner := bufio.NewScanner(file)
-	if scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
-		if len(fields) == 0 || len(fields) > 2 {
-			return 0, errors.New("quota(cgroup): invalid format cpu.max")
-		}
-
-		// No CPU limits.
-		if fields[0] == "max" {
-			return 0, nil
-		}
-
-		// Get Maximum CPU quota
-		max, err := strconv.ParseUint(fields[0], 10, 64)
-		if err != nil || max == 0 {
-			return 0, errors.New("quota(cgroup): invalid format cpu.max")
-		}
-
-		// Check if period is defined.
-		var period uint64
-		if len(fields) == 2 {
-			period, err = strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				return 0, fmt.Errorf("quota(cgroup): invalid format cpu.max: %w", err)
-			}
-		} else {
-			// Default CPU period value.
-			period = 100000
-		}
-
-		return float64(max) / float64(period), nil
+	path, err := DefaultCgroupInterfacePath()
+	if err != nil {
+		return "", err
 	}
+	d.resolved.Store(&path)
+	return path, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return 0, fmt.Errorf("quota(cgroup): failed to scan cpu.max: %w", err)
+func (d *Detector) DetectCPUQuota(ctx context.Context) (float64, error) {
+	cgroupfs, err := d.path(ctx)
+	if err != nil {
+		return 0, err
 	}
 
-	return 0, io.ErrUnexpectedEOF
+	quota, err := cpuQuotaFromFile(nil, filepath.Join(cgroupfs, "cpu.max"))
+	if err != nil {
+		return 0, fmt.Errorf("quota(cgroup): %w", err)
+	}
+	return quota, nil
 }
 
 //nolint:nonamedreturns // for docs.
-func (d *Detector) DetectMemoryQuota(_ context.Context) (hard, soft int64, err error) {
-	if d.cgroupfs == "" {
-		d.cgroupfs, err = GetCgroupInterfacePath("")
-	}
-
+func (d *Detector) DetectMemoryQuota(ctx context.Context) (hard, soft int64, err error) {
+	cgroupfs, err := d.path(ctx)
 	if err != nil {
 		return 0, 0, err
 	}
 
 	// Read memory.max
-	hard, err = memLimitFromFile(filepath.Join(d.cgroupfs, "memory.max"))
+	hard, err = memLimitFromFile(nil, filepath.Join(cgroupfs, "memory.max"))
 	if err != nil {
 		return 0, 0, fmt.Errorf("quota(linux): failed to get memory max: %w", err)
 	}
 
 	// Read memory.high
-	soft, err = memLimitFromFile(filepath.Join(d.cgroupfs, "memory.high"))
+	soft, err = memLimitFromFile(nil, filepath.Join(cgroupfs, "memory.high"))
 	if err != nil {
 		return 0, 0, fmt.Errorf("quota(linux): failed to get memory high: %w", err)
 	}
 
 	return hard, soft, nil
 }
+
+// DetectMemoryInfo returns memory limits and usage from cgroup interface files.
+func (d *Detector) DetectMemoryInfo(ctx context.Context) (MemoryInfo, error) {
+	cgroupfs, err := d.path(ctx)
+	if err != nil {
+		return MemoryInfo{}, err
+	}
+
+	return memoryInfoFromDir(nil, cgroupfs)
+}
+
+// DetectCPUInfo returns CPU limits from cgroup interface files.
+func (d *Detector) DetectCPUInfo(ctx context.Context) (CPUInfo, error) {
+	cgroupfs, err := d.path(ctx)
+	if err != nil {
+		return CPUInfo{}, err
+	}
+
+	return cpuInfoFromDir(nil, cgroupfs)
+}
+
+// DetectProcs returns number of processes in the cgroup, from cgroup.procs
+// interface file.
+func (d *Detector) DetectProcs(ctx context.Context) (int, error) {
+	cgroupfs, err := d.path(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return procsFromDir(nil, cgroupfs)
+}
@@ -7,7 +7,10 @@ package quota_test
 
 import (
 	"context"
+	"errors"
+	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/tprasadtp/go-autotune/internal/quota"
@@ -75,8 +78,8 @@ func TestDetectCPUQuota(t *testing.T) {
 			v, err := d.DetectCPUQuota(ctx)
 
 			if tc.err {
-				if err == nil {
-					t.Errorf("expected an error, got nil")
+				if !errors.Is(err, quota.ErrMalformed) {
+					t.Errorf("expected ErrMalformed, got %v", err)
 				}
 
 				if v != 0 {
@@ -168,8 +171,8 @@ func TestDetectMemoryQuota(t *testing.T) {
 			max, high, err := d.DetectMemoryQuota(ctx)
 
 			if tc.err {
-				if err == nil {
-					t.Errorf("expected an error, got nil")
+				if !errors.Is(err, quota.ErrMalformed) {
+					t.Errorf("expected ErrMalformed, got %v", err)
 				}
 
 				if max != 0 {
@@ -195,6 +198,102 @@ func TestDetectMemoryQuota(t *testing.T) {
 	}
 }
 
+func TestDetectOpenError(t *testing.T) {
+	// Path to interface files is a regular file, thus opening
+	// interface files fails with an error other than ErrNotExist.
+	path := filepath.Join(t.TempDir(), "cgroup")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("failed to create file: %s", err)
+	}
+
+	d := quota.NewDetectorWithCgroupPath(path)
+	ctx := context.Background()
+
+	if _, err := d.DetectCPUQuota(ctx); err == nil || errors.Is(err, quota.ErrMalformed) {
+		t.Errorf("DetectCPUQuota expected open error, got %v", err)
+	}
+
+	if _, _, err := d.DetectMemoryQuota(ctx); err == nil || errors.Is(err, quota.ErrMalformed) {
+		t.Errorf("DetectMemoryQuota expected open error, got %v", err)
+	}
+}
+
+func TestDetectWithCgroupPathEnv(t *testing.T) {
+	t.Setenv(quota.EnvCgroupPath, filepath.Join("testdata", "cgroup", "cpu-250"))
+	d := &quota.Detector{}
+
+	v, err := d.DetectCPUQuota(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if v != 2.5 {
+		t.Errorf("expected=2.5, got=%f", v)
+	}
+}
+
+func TestDetectConcurrent(t *testing.T) {
+	t.Setenv(quota.EnvCgroupPath, filepath.Join("testdata", "cgroup", "cpu-250"))
+	d := &quota.Detector{}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			info, err := d.DetectCPUInfo(context.Background())
+			if err != nil {
+				t.Errorf("DetectCPUInfo expected no error, got %s", err)
+			}
+
+			if info.Quota != 2.5 {
+				t.Errorf("DetectCPUInfo expected=2.5, got=%f", info.Quota)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := d.DetectMemoryInfo(context.Background()); err != nil {
+				t.Errorf("DetectMemoryInfo expected no error, got %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDetectRemovedCgroup(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o700); err != nil {
+			t.Fatalf("failed to create cgroup: %s", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "b", "cpu.max"), []byte("150000 100000\n"), 0o600); err != nil {
+		t.Fatalf("failed to write cpu.max: %s", err)
+	}
+
+	t.Setenv(quota.EnvCgroupPath, filepath.Join(dir, "a"))
+	d := &quota.Detector{}
+	if v, err := d.DetectCPUQuota(context.Background()); err != nil || v != 0 {
+		t.Fatalf("expected no quota, got=%f, err=%v", v, err)
+	}
+
+	// Cgroup is removed and process is moved to another cgroup.
+	if err := os.Remove(filepath.Join(dir, "a")); err != nil {
+		t.Fatalf("failed to remove cgroup: %s", err)
+	}
+	t.Setenv(quota.EnvCgroupPath, filepath.Join(dir, "b"))
+
+	v, err := d.DetectCPUQuota(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if v != 1.5 {
+		t.Errorf("expected=1.5, got=%f", v)
+	}
+}
+
 func TestTrampolineLinux(t *testing.T) {
 	tt := []trampoline.Scenario{
 		{
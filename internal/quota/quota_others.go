@@ -20,3 +20,15 @@ func (d *Detector) DetectCPUQuota(_ context.Context) (float64, error) {
 func (d *Detector) DetectMemoryQuota(_ context.Context) (max, high int64, err error) {
 	return 0, 0, errors.ErrUnsupported
 }
+
+func (d *Detector) DetectMemoryInfo(_ context.Context) (MemoryInfo, error) {
+	return MemoryInfo{}, errors.ErrUnsupported
+}
+
+func (d *Detector) DetectCPUInfo(_ context.Context) (CPUInfo, error) {
+	return CPUInfo{}, errors.ErrUnsupported
+}
+
+func (d *Detector) DetectProcs(_ context.Context) (int, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -10,19 +10,63 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"syscall"
 	"unsafe"
 
 	"github.com/tprasadtp/go-autotune/internal/shared"
 	"golang.org/x/sys/windows"
 )
 
+//nolint:gochecknoglobals
+var (
+	kernel32                 = windows.NewLazySystemDLL("kernel32.dll")
+	procGetProcessMemoryInfo = kernel32.NewProc("K32GetProcessMemoryInfo")
+)
+
+// getProcessMemoryInfo returns memory counters of the current process.
+func getProcessMemoryInfo() (shared.PROCESS_MEMORY_COUNTERS_EX, error) {
+	counters := shared.PROCESS_MEMORY_COUNTERS_EX{}
+	counters.CB = uint32(unsafe.Sizeof(counters))
+	r1, _, e1 := syscall.SyscallN(
+		procGetProcessMemoryInfo.Addr(),
+		uintptr(windows.CurrentProcess()),
+		uintptr(unsafe.Pointer(&counters)),
+		uintptr(counters.CB),
+	)
+	if r1 == 0 {
+		return counters, e1
+	}
+	return counters, nil
+}
+
+// activeProcesses returns number of active processes in the job object.
+// Zero is returned if process is not in a job object or it is not accessible.
+func activeProcesses() (int, error) {
+	info := shared.JOBOBJECT_BASIC_ACCOUNTING_INFORMATION{}
+	err := windows.QueryInformationJobObject(
+		windows.Handle(0),
+		windows.JobObjectBasicAccountingInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+		nil,
+	)
+	if err != nil && !errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		return 0, fmt.Errorf("quota(windows): failed to get job accounting info: %w", err)
+	}
+	return int(info.ActiveProcesses), nil
+}
+
 func isFlagSet(ref, value uint32) bool {
 	return (ref & value) == ref
 }
 
 type Detector struct{}
 
-func (d *Detector) DetectCPUQuota(_ context.Context) (float64, error) {
+func (d *Detector) DetectCPUQuota(ctx context.Context) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("quota(windows): %w", err)
+	}
+
 	cpuInfo := shared.JOBOBJECT_CPU_RATE_CONTROL_INFORMATION{}
 	err := windows.QueryInformationJobObject(
 		windows.Handle(0),
@@ -58,7 +102,11 @@ func (d *Detector) DetectCPUQuota(_ context.Context) (float64, error) {
 }
 
 //nolint:nonamedreturns // for docs.
-func (d *Detector) DetectMemoryQuota(_ context.Context) (max, high int64, err error) {
+func (d *Detector) DetectMemoryQuota(ctx context.Context) (max, high int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, 0, fmt.Errorf("quota(windows): %w", err)
+	}
+
 	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
 	err = windows.QueryInformationJobObject(
 		windows.Handle(0),
@@ -85,3 +133,65 @@ func (d *Detector) DetectMemoryQuota(_ context.Context) (max, high int64, err er
 		return 0, 0, nil
 	}
 }
+
+// DetectMemoryInfo returns memory limits and usage. Job objects only support
+// hard limits, thus only [MemoryInfo.Max] limit is populated. [MemoryInfo.Current]
+// is private commit charge of the current process, which is what job memory limits
+// are enforced on. [MemoryInfo.Peak] is peak memory used by the job, if job memory
+// limit is defined, otherwise it is peak memory used by the process.
+func (d *Detector) DetectMemoryInfo(ctx context.Context) (MemoryInfo, error) {
+	max, _, err := d.DetectMemoryQuota(ctx)
+	if err != nil {
+		return MemoryInfo{}, err
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	err = windows.QueryInformationJobObject(
+		windows.Handle(0),
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+		nil,
+	)
+	if err != nil && !errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		return MemoryInfo{}, fmt.Errorf("quota(windows): failed get to memory usage: %w", err)
+	}
+
+	counters, err := getProcessMemoryInfo()
+	if err != nil {
+		return MemoryInfo{}, fmt.Errorf("quota(windows): failed get to process memory info: %w", err)
+	}
+
+	rv := MemoryInfo{
+		Max:     max,
+		Current: int64(counters.PrivateUsage),
+		Peak:    int64(counters.PeakPagefileUsage),
+		Source:  "jobobject",
+	}
+
+	switch {
+	case info.JobMemoryLimit > 0 && info.PeakJobMemoryUsed > 0:
+		rv.Peak = int64(info.PeakJobMemoryUsed)
+	case info.PeakProcessMemoryUsed > 0:
+		rv.Peak = int64(info.PeakProcessMemoryUsed)
+	}
+	return rv, nil
+}
+
+// DetectCPUInfo returns CPU rate limit of the job object. Only
+// [CPUInfo.Quota] is populated.
+func (d *Detector) DetectCPUInfo(ctx context.Context) (CPUInfo, error) {
+	quota, err := d.DetectCPUQuota(ctx)
+	if err != nil {
+		return CPUInfo{}, err
+	}
+	return CPUInfo{Quota: quota, Source: "jobobject"}, nil
+}
+
+// DetectProcs returns number of active processes in the job object.
+func (d *Detector) DetectProcs(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("quota(windows): %w", err)
+	}
+	return activeProcesses()
+}
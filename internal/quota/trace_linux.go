@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package quota
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Trace describes each step taken to resolve cgroup interface path
+// and raw contents of interface files used to detect limits.
+type Trace struct {
+	// Path to mountinfo file.
+	MountInfoPath string
+
+	// Line from mountinfo file which matched cgroup2 mount.
+	MountInfoLine string
+
+	// Mount point of cgroup2 filesystem.
+	MountPoint string
+
+	// Root of the cgroup2 hierarchy mounted at the mount point.
+	MountRoot string
+
+	// Error encountered while parsing mountinfo file.
+	MountInfoErr error
+
+	// Path to cgroup file.
+	CgroupPath string
+
+	// Name of the cgroup from cgroup file.
+	CgroupName string
+
+	// Error encountered while parsing cgroup file.
+	CgroupErr error
+
+	// Resolved path to cgroup interface files.
+	InterfacePath string
+
+	// How cgroup interface path was resolved. This is GOAUTOTUNE_CGROUP_PATH if it
+	// was overridden by the environment variable, "procfs" if it was resolved from
+	// mountinfo and cgroup files, or "cgroupns" if /sys/fs/cgroup was used,
+	// as cgroup interface path could not be resolved from procfs.
+	InterfaceSource string
+
+	// Error encountered while resolving cgroup interface path.
+	InterfaceErr error
+
+	// Interface files read.
+	Files []FileTrace
+}
+
+// FileTrace describes a cgroup interface file read.
+type FileTrace struct {
+	// Name of the interface file, for example cpu.max.
+	Name string
+
+	// Path to the interface file.
+	Path string
+
+	// Raw contents of the file, with leading and trailing whitespace removed.
+	Contents string
+
+	// Error encountered reading the file.
+	Err error
+}
+
+// Inspect resolves cgroup interface path like [GetCgroupInterfacePath], but records
+// each step and reads raw contents of interface files. If procfs is empty,
+// cgroup interface path of the current process is resolved like
+// [DefaultCgroupInterfacePath], honoring GOAUTOTUNE_CGROUP_PATH and
+// GOAUTOTUNE_PROCFS environment variables. Errors are recorded in
+// the returned [Trace].
+func Inspect(procfs string) Trace {
+	self := procfs == ""
+	if self {
+		procfs = os.Getenv(EnvProcFS)
+	}
+
+	if procfs == "" {
+		procfs = "/proc/self"
+	}
+
+	trace := Trace{
+		MountInfoPath: filepath.Join(procfs, "mountinfo"),
+		CgroupPath:    filepath.Join(procfs, "cgroup"),
+	}
+
+	var mount cgroupMount
+	mount, trace.MountInfoErr = cgroupMountFromFile(nil, trace.MountInfoPath)
+	trace.MountPoint, trace.MountRoot, trace.MountInfoLine = mount.MountPoint, mount.Root, mount.Line
+	trace.CgroupName, trace.CgroupErr = cgroupNameFromFile(nil, trace.CgroupPath)
+
+	switch {
+	case self:
+		trace.InterfacePath, trace.InterfaceSource, trace.InterfaceErr = defaultCgroupInterfacePath()
+	case trace.MountInfoErr == nil && trace.CgroupErr == nil:
+		trace.InterfacePath, trace.InterfaceErr = GetCgroupInterfacePath(procfs)
+		if trace.InterfaceErr == nil {
+			trace.InterfaceSource = sourceProcFS
+		}
+	}
+
+	if trace.InterfacePath == "" {
+		return trace
+	}
+
+	for _, name := range InterfaceFiles {
+		item := FileTrace{
+			Name: name,
+			Path: filepath.Join(trace.InterfacePath, name),
+		}
+		item.Contents, item.Err = readInterfaceFile(nil, item.Path)
+		trace.Files = append(trace.Files, item)
+	}
+	return trace
+}
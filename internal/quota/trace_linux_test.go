@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package quota
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	t.Run("systemd-system", func(t *testing.T) {
+		trace := Inspect(filepath.Join("testdata", "procfs", "systemd-system"))
+		if trace.MountInfoErr != nil {
+			t.Errorf("expected no error, got %s", trace.MountInfoErr)
+		}
+		if trace.CgroupErr != nil {
+			t.Errorf("expected no error, got %s", trace.CgroupErr)
+		}
+		if trace.MountPoint != "/sys/fs/cgroup" {
+			t.Errorf("expected mount point=/sys/fs/cgroup, got=%s", trace.MountPoint)
+		}
+		if trace.MountInfoLine == "" {
+			t.Errorf("expected non empty mountinfo line")
+		}
+		if trace.CgroupName != "/system.slice/run-u1801.service" {
+			t.Errorf("unexpected cgroup name=%s", trace.CgroupName)
+		}
+		if trace.InterfacePath != "/sys/fs/cgroup/system.slice/run-u1801.service" {
+			t.Errorf("unexpected interface path=%s", trace.InterfacePath)
+		}
+		if trace.InterfaceSource != "procfs" {
+			t.Errorf("unexpected interface source=%s", trace.InterfaceSource)
+		}
+		if len(trace.Files) != len(InterfaceFiles) {
+			t.Errorf("expected %d files, got=%d", len(InterfaceFiles), len(trace.Files))
+		}
+	})
+	t.Run("docker-no-cgroupns", func(t *testing.T) {
+		trace := Inspect(filepath.Join("testdata", "procfs", "docker-no-cgroupns"))
+		if trace.MountRoot != "/docker/0f1e2d3c" {
+			t.Errorf("unexpected mount root=%s", trace.MountRoot)
+		}
+		if trace.InterfacePath != "/sys/fs/cgroup" {
+			t.Errorf("expected interface path=/sys/fs/cgroup, got=%s", trace.InterfacePath)
+		}
+	})
+	t.Run("mount-root-mismatch", func(t *testing.T) {
+		trace := Inspect(filepath.Join("testdata", "procfs", "mount-root-mismatch"))
+		if trace.InterfaceErr == nil {
+			t.Errorf("expected an error, got nil")
+		}
+		if trace.InterfacePath != "" {
+			t.Errorf("expected empty interface path, got=%s", trace.InterfacePath)
+		}
+		if len(trace.Files) != 0 {
+			t.Errorf("expected no files, got=%d", len(trace.Files))
+		}
+	})
+	t.Run("cgroup-v1", func(t *testing.T) {
+		trace := Inspect(filepath.Join("testdata", "procfs", "cgroup-v1"))
+		if trace.MountInfoErr == nil {
+			t.Errorf("expected an error, got nil")
+		}
+		if trace.InterfacePath != "" {
+			t.Errorf("expected empty interface path, got=%s", trace.InterfacePath)
+		}
+		if len(trace.Files) != 0 {
+			t.Errorf("expected no files, got=%d", len(trace.Files))
+		}
+	})
+}
+
+func TestInspectSelf(t *testing.T) {
+	t.Run("CgroupPathEnv", func(t *testing.T) {
+		path := filepath.Join("testdata", "cgroup", "cpu-250")
+		t.Setenv(EnvProcFS, filepath.Join("testdata", "procfs", "cgroup-v1"))
+		t.Setenv(EnvCgroupPath, path)
+		trace := Inspect("")
+		if trace.MountInfoPath != filepath.Join("testdata", "procfs", "cgroup-v1", "mountinfo") {
+			t.Errorf("unexpected mountinfo path=%s", trace.MountInfoPath)
+		}
+		if trace.InterfaceErr != nil {
+			t.Errorf("expected no error, got %s", trace.InterfaceErr)
+		}
+		if trace.InterfacePath != path {
+			t.Errorf("expected interface path=%s, got=%s", path, trace.InterfacePath)
+		}
+		if trace.InterfaceSource != EnvCgroupPath {
+			t.Errorf("expected interface source=%s, got=%s", EnvCgroupPath, trace.InterfaceSource)
+		}
+		if len(trace.Files) != len(InterfaceFiles) || trace.Files[0].Contents != "250000 100000" {
+			t.Errorf("unexpected files=%v", trace.Files)
+		}
+	})
+	t.Run("InvalidCgroupPathEnv", func(t *testing.T) {
+		t.Setenv(EnvCgroupPath, filepath.Join("testdata", "cgroup", "does-not-exist"))
+		trace := Inspect("")
+		if trace.InterfaceErr == nil {
+			t.Errorf("expected an error, got nil")
+		}
+		if len(trace.Files) != 0 {
+			t.Errorf("expected no files, got=%d", len(trace.Files))
+		}
+	})
+}
+
+func TestReadInterfaceFile(t *testing.T) {
+	v, err := readInterfaceFile(nil, filepath.Join("testdata", "cgroup", "cpu-250", "cpu.max"))
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if v != "250000 100000" {
+		t.Errorf("expected=%q, got=%q", "250000 100000", v)
+	}
+
+	_, err = readInterfaceFile(nil, filepath.Join("testdata", "cgroup", "no-limits-no-files", "cpu.max"))
+	if err == nil {
+		t.Errorf("expected an error, got nil")
+	}
+}
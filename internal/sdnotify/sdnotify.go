@@ -0,0 +1,126 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+// Package sdnotify implements [sd_notify] protocol without libsystemd,
+// to report GOMAXPROCS and GOMEMLIMIT to the service manager, which is
+// shown by "systemctl status".
+//
+// [sd_notify]: https://www.freedesktop.org/software/systemd/man/latest/sd_notify.html
+package sdnotify
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+// status reported to the service manager.
+//
+//nolint:gochecknoglobals // status is shared by maxprocs and memlimit packages.
+var status struct {
+	mu       sync.Mutex
+	maxprocs string
+	memlimit string
+
+	// Last status sent and the socket it was sent to.
+	sent   string
+	sentTo string
+}
+
+// Notify sends state to the service manager via unix datagram socket specified
+// by NOTIFY_SOCKET environment variable. If NOTIFY_SOCKET is not set, this does
+// nothing and returns nil. Abstract sockets (starting with "@") are supported.
+func Notify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("sdnotify: failed to connect to NOTIFY_SOCKET(%s): %w", path, err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		return fmt.Errorf("sdnotify: failed to write to NOTIFY_SOCKET(%s): %w", path, err)
+	}
+	return nil
+}
+
+// Status returns current status message, without "STATUS=" prefix.
+func Status() string {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	return format()
+}
+
+// format formats status. status.mu must be held.
+func format() string {
+	var parts []string
+	if status.maxprocs != "" {
+		parts = append(parts, "GOMAXPROCS="+status.maxprocs)
+	}
+
+	if status.memlimit != "" {
+		parts = append(parts, "GOMEMLIMIT="+status.memlimit)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// UpdateMaxProcs updates GOMAXPROCS and its source in status, and sends updated
+// status to the service manager, unless it is same as the status last sent.
+func UpdateMaxProcs(procs int, source string) error {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.maxprocs = fmt.Sprintf("%d (%s)", procs, source)
+	return send()
+}
+
+// UpdateMemLimit updates GOMEMLIMIT and its source in status, and sends updated
+// status to the service manager, unless it is same as the status last sent.
+func UpdateMemLimit(limit int64, source string) error {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.memlimit = fmt.Sprintf("%s (%s)", units.Format(limit), source)
+	return send()
+}
+
+// send sends status to the service manager, if it differs from the status
+// last sent. This avoids flooding the service manager with same status,
+// when GOMAXPROCS and GOMEMLIMIT are periodically re-applied. status.mu
+// must be held by the caller.
+func send() error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+
+	state := "STATUS=" + format()
+	if state == status.sent && path == status.sentTo {
+		return nil
+	}
+
+	err := Notify(state)
+	if err != nil {
+		return err
+	}
+	status.sent = state
+	status.sentTo = path
+	return nil
+}
+
+// Reset resets status. This is only intended for tests.
+func Reset() {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	status.maxprocs = ""
+	status.memlimit = ""
+	status.sent = ""
+	status.sentTo = ""
+}
@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package sdnotify_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/sdnotify"
+)
+
+// listen listens on a unixgram socket and sets NOTIFY_SOCKET.
+func listen(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// receive reads a message from conn.
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Run("NotSet", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+		if err := sdnotify.Notify("READY=1"); err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	})
+	t.Run("Missing", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+		if err := sdnotify.Notify("READY=1"); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		conn := listen(t)
+		if err := sdnotify.Notify("READY=1"); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		if got := receive(t, conn); got != "READY=1" {
+			t.Errorf("expected=%q, got=%q", "READY=1", got)
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	sdnotify.Reset()
+	t.Cleanup(sdnotify.Reset)
+	conn := listen(t)
+
+	if err := sdnotify.UpdateMaxProcs(2, "quota"); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	expect := "STATUS=GOMAXPROCS=2 (quota)"
+	if got := receive(t, conn); got != expect {
+		t.Errorf("expected=%q, got=%q", expect, got)
+	}
+
+	if err := sdnotify.UpdateMemLimit(1024, "env"); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	expect = "STATUS=GOMAXPROCS=2 (quota), GOMEMLIMIT=1KiB (env)"
+	if got := receive(t, conn); got != expect {
+		t.Errorf("expected=%q, got=%q", expect, got)
+	}
+
+	if got := sdnotify.Status(); "STATUS="+got != expect {
+		t.Errorf("expected=%q, got=%q", expect, "STATUS="+got)
+	}
+
+	// Unchanged status is not sent again.
+	if err := sdnotify.UpdateMaxProcs(2, "quota"); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if err := sdnotify.UpdateMemLimit(1024, "memory pressure"); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	expect = "STATUS=GOMAXPROCS=2 (quota), GOMEMLIMIT=1KiB (memory pressure)"
+	if got := receive(t, conn); got != expect {
+		t.Errorf("expected=%q, got=%q", expect, got)
+	}
+}
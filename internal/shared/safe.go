@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package shared
+
+import (
+	"context"
+	"fmt"
+)
+
+// Recover recovers from a panic and stores it as an error in err.
+// It must be called directly via defer.
+//
+//	defer shared.Recover(&err)
+func Recover(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("recovered from panic: %v", r)
+	}
+}
+
+// Call calls fn in a separate goroutine and waits until it returns or ctx is done.
+// This ensures ctx is honored even if fn ignores it, for example when fn is blocked
+// reading a file. If ctx is done before fn returns, ctx error is returned and fn is
+// abandoned. Panics in fn are recovered and returned as errors.
+func Call[T any](ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		var rv result
+		defer func() {
+			ch <- rv
+		}()
+		defer Recover(&rv.err)
+		rv.value, rv.err = fn(ctx)
+	}()
+
+	select {
+	case rv := <-ch:
+		return rv.value, rv.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package shared
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCall(t *testing.T) {
+	t.Run("Value", func(t *testing.T) {
+		v, err := Call(context.Background(), func(context.Context) (int, error) {
+			return 1, nil
+		})
+		if v != 1 || err != nil {
+			t.Errorf("expected=1, got=%d, err=%v", v, err)
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		expect := errors.New("test: error")
+		_, err := Call(context.Background(), func(context.Context) (int, error) {
+			return 0, expect
+		})
+		if !errors.Is(err, expect) {
+			t.Errorf("expected error %q, got %v", expect, err)
+		}
+	})
+	t.Run("Panic", func(t *testing.T) {
+		v, err := Call(context.Background(), func(context.Context) (int, error) {
+			panic("test: panic")
+		})
+		if v != 0 || err == nil {
+			t.Errorf("expected error, got=%d, err=%v", v, err)
+		}
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := Call(ctx, func(context.Context) (int, error) {
+			t.Errorf("function must not be called when context is done")
+			return 1, nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+	t.Run("Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		done := make(chan struct{})
+		defer close(done)
+		_, err := Call(ctx, func(context.Context) (int, error) {
+			<-done
+			return 1, nil
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
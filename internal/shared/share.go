@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package shared
+
+import (
+	"context"
+	"errors"
+)
+
+// Share returns share of limits for the current process. If share is in range
+// (0, 1), it is returned as is. Otherwise, if auto is true and procs is more than
+// one, limits are divided equally among procs. Otherwise, 1 is returned.
+func Share(share float64, auto bool, procs int) float64 {
+	switch {
+	case share > 0 && share < 1:
+		return share
+	case auto && procs > 1:
+		return 1 / float64(procs)
+	default:
+		return 1
+	}
+}
+
+// ProcsDetector is implemented by detectors which can detect number of
+// processes sharing limits, like number of processes in cgroup.procs on Linux.
+type ProcsDetector interface {
+	DetectProcs(ctx context.Context) (int, error)
+}
+
+// Procs returns number of processes sharing limits, if detector implements
+// [ProcsDetector]. Otherwise, [errors.ErrUnsupported] is returned.
+func Procs(ctx context.Context, detector any) (int, error) {
+	d, ok := detector.(ProcsDetector)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return Call(ctx, d.DetectProcs)
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package shared
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShare(t *testing.T) {
+	tt := []struct {
+		name   string
+		share  float64
+		auto   bool
+		procs  int
+		expect float64
+	}{
+		{name: "Default", expect: 1},
+		{name: "Explicit", share: 0.5, expect: 0.5},
+		{name: "ExplicitOverridesAuto", share: 0.5, auto: true, procs: 4, expect: 0.5},
+		{name: "InvalidExplicit", share: 1.5, expect: 1},
+		{name: "NaN", share: math.NaN(), expect: 1},
+		{name: "Auto", auto: true, procs: 4, expect: 0.25},
+		{name: "AutoSingleProcess", auto: true, procs: 1, expect: 1},
+		{name: "AutoUnknown", auto: true, expect: 1},
+		{name: "AutoDisabled", procs: 4, expect: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if v := Share(tc.share, tc.auto, tc.procs); v != tc.expect {
+				t.Errorf("expected=%f, got=%f", tc.expect, v)
+			}
+		})
+	}
+}
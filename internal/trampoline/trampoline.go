@@ -7,6 +7,7 @@ package trampoline
 
 import (
 	"math"
+	"os"
 	"runtime"
 	"runtime/debug"
 	"testing"
@@ -27,6 +28,12 @@ type Options struct {
 	// memory.high on Linux and JobObject memory limit on windows.
 	M2 int
 
+	// CPUs allowed (cpuset.cpus), for example "0-1". Only supported on Linux.
+	CPUSet string
+
+	// Maximum number of tasks (pids.max). Only supported on Linux.
+	PIDs int
+
 	// Additional environment variables in KEY=VALUE format.
 	// MUST NOT contain string GO_TEST_EXEC_TRAMPOLINE(case insensitive).
 	Env []string
@@ -70,6 +77,8 @@ func VerifyFunc(cpus int, memory int64) func(tb testing.TB) {
 
 // Trampoline re-runs the current test function via [systemd-run] on linux and
 // [golang.org/x/sys/windows.CreateProcess] with appropriate resource limits.
+// On linux, if systemd is not available, test is re-run in a child cgroup of a
+// writable, delegated cgroup2 hierarchy specified by GO_TEST_TRAMPOLINE_CGROUP.
 // verify is the test function which should be checked. configure is a hook
 // to run any setup tasks before running verify. Though configure can be nil
 // verify must be a non nil test function.
@@ -78,3 +87,18 @@ func VerifyFunc(cpus int, memory int64) func(tb testing.TB) {
 func Trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configure func()) {
 	trampoline(tb, opts, verify, configure)
 }
+
+// IsTrampoline reports whether current process is a trampoline,
+// i.e. it was re-executed by [Trampoline].
+func IsTrampoline() bool {
+	_, ok := os.LookupEnv("GO_TEST_EXEC_TRAMPOLINE")
+	return ok
+}
+
+// Check checks if [Trampoline] can run with given options. If it cannot,
+// returned error describes the reason, like neither systemd nor delegated cgroup2
+// hierarchy being available or required controllers not being delegated. This is useful to skip tests
+// instead of failing them.
+func Check(opts Options) error {
+	return check(opts)
+}
@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package trampoline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Environment variable which specifies the delegated cgroup under which
+// trampoline cgroups are created, for example /sys/fs/cgroup.
+const cgroupEnv = "GO_TEST_TRAMPOLINE_CGROUP"
+
+// Period used for cpu.max. This is same as the default used by systemd.
+const cgroupCPUPeriod = 100000
+
+// delegatedCgroup returns path to the cgroup specified by GO_TEST_TRAMPOLINE_CGROUP,
+// under which child cgroups are created.
+//
+// It is not inferred from the cgroup of the current process, as creating cgroups
+// and enabling controllers outside of a delegated subtree modifies the host.
+// Because of the no internal process rule of cgroup v2, controllers cannot be
+// enabled for children of a non-root cgroup with processes in it. Thus, in
+// containers, this is typically the root of the cgroup namespace (/sys/fs/cgroup),
+// after entrypoint moves itself to a leaf cgroup (for example /init).
+func delegatedCgroup() (string, error) {
+	base := os.Getenv(cgroupEnv)
+	if base == "" {
+		return "", fmt.Errorf("%s is not set", cgroupEnv)
+	}
+
+	if _, err := os.Stat(filepath.Join(base, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%s is not a cgroup2 directory: %w", base, err)
+	}
+
+	if err := unix.Access(base, unix.W_OK); err != nil {
+		return "", fmt.Errorf("cgroup %s is not writable: %w", base, err)
+	}
+	return base, nil
+}
+
+// checkCgroupFS checks if cgroupfs backend can be used with given options,
+// and returns the delegated cgroup. Controllers required by opts must be
+// available in it. This does not modify the cgroup.
+func checkCgroupFS(opts Options) (string, error) {
+	base, err := delegatedCgroup()
+	if err != nil {
+		return "", err
+	}
+
+	required := requiredControllers(opts)
+	if len(required) == 0 {
+		return base, nil
+	}
+
+	buf, err := os.ReadFile(filepath.Join(base, "cgroup.controllers"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read available controllers: %w", err)
+	}
+
+	available := strings.Fields(string(buf))
+	var missing []string
+	for _, item := range required {
+		if !slices.Contains(available, item) {
+			missing = append(missing, item)
+		}
+	}
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("controllers %s are not available in cgroup %s",
+			strings.Join(missing, ","), base)
+	}
+	return base, nil
+}
+
+// enableControllers enables controllers required by opts in cgroup.subtree_control
+// of the delegated cgroup base, if not already enabled.
+func enableControllers(base string, opts Options) error {
+	buf, err := os.ReadFile(filepath.Join(base, "cgroup.subtree_control"))
+	if err != nil {
+		return fmt.Errorf("failed to read enabled controllers: %w", err)
+	}
+
+	enabled := strings.Fields(string(buf))
+	var enable []string
+	for _, item := range requiredControllers(opts) {
+		if !slices.Contains(enabled, item) {
+			enable = append(enable, "+"+item)
+		}
+	}
+
+	if len(enable) == 0 {
+		return nil
+	}
+
+	err = os.WriteFile(
+		filepath.Join(base, "cgroup.subtree_control"),
+		[]byte(strings.Join(enable, " ")),
+		0o644,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enable controllers %s in cgroup %s: %w",
+			strings.Join(enable, " "), base, err)
+	}
+	return nil
+}
+
+// trampolineCgroupFS re-execs the test in a child cgroup of the delegated
+// cgroup with limits applied. Unlike systemd backend, this does not require
+// systemd and only requires a writable cgroup2 hierarchy.
+func trampolineCgroupFS(tb testing.TB, opts Options) {
+	// Like systemd backend, skip the test if backend is not available.
+	base, err := checkCgroupFS(opts)
+	if err != nil {
+		tb.Skipf("cgroupfs is not available: %s", err)
+	}
+
+	err = enableControllers(base, opts)
+	if err != nil {
+		tb.Skipf("cgroupfs is not available: %s", err)
+	}
+
+	cgroup, err := os.MkdirTemp(base, "go-test-trampoline-")
+	if err != nil {
+		tb.Fatalf("failed to create cgroup: %s", err)
+	}
+
+	// Remove the cgroup once trampoline exits. cgroup directories are
+	// removed with rmdir even though they contain interface files.
+	// Processes left behind (for example, after a timeout) are killed first,
+	// as cgroups with processes cannot be removed. This also runs when test
+	// fails via Fatalf.
+	defer func() {
+		if err := killCgroup(cgroup); err != nil {
+			tb.Errorf("failed to kill processes in cgroup %s: %s", cgroup, err)
+		}
+
+		if err := unix.Rmdir(cgroup); err != nil {
+			tb.Errorf("failed to remove cgroup %s: %s", cgroup, err)
+		}
+	}()
+
+	files := map[string]string{}
+
+	// CPU limit.
+	if opts.CPU > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", int(opts.CPU*cgroupCPUPeriod), cgroupCPUPeriod)
+	}
+
+	// M1 corresponds to memory.max
+	if opts.M1 > 0 {
+		files["memory.max"] = strconv.Itoa(opts.M1)
+	}
+
+	// M2 corresponds to memory.high
+	if opts.M2 > 0 {
+		files["memory.high"] = strconv.Itoa(opts.M2)
+	}
+
+	// CPUSet corresponds to cpuset.cpus
+	if opts.CPUSet != "" {
+		files["cpuset.cpus"] = opts.CPUSet
+	}
+
+	// PIDs corresponds to pids.max
+	if opts.PIDs > 0 {
+		files["pids.max"] = strconv.Itoa(opts.PIDs)
+	}
+
+	for name, value := range files {
+		if err = os.WriteFile(filepath.Join(cgroup, name), []byte(value), 0o644); err != nil {
+			tb.Fatalf("failed to set %s: %s", name, err)
+		}
+	}
+
+	exe, args := testCommand(tb, opts)
+
+	// Set timeouts.
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	// Trampoline must be in the cgroup before it starts, as limits are
+	// typically configured during init. Thus, the shell moves itself to the
+	// cgroup by writing 0 to cgroup.procs and then execs the test binary.
+	//
+	//nolint:gosec // input is from trusted source.
+	cmd := exec.CommandContext(ctx,
+		"/bin/sh",
+		append([]string{
+			"-c",
+			`echo 0 > "$0" && exec "$@"`,
+			filepath.Join(cgroup, "cgroup.procs"),
+			exe,
+		}, args...)...,
+	)
+	cmd.Env = append(os.Environ(), opts.Env...)
+	// Always override GO_TEST_EXEC_TRAMPOLINE env set by opts.
+	cmd.Env = append(cmd.Env, "GO_TEST_EXEC_TRAMPOLINE=true")
+	cmd.Stdin = nil
+	cmd.Stdout = NewWriter(tb, "trampoline")
+	cmd.Stderr = NewWriter(tb, "trampoline")
+	tb.Logf("Running in cgroup %s via : %v", cgroup, cmd.Args)
+	err = cmd.Run()
+	if err != nil {
+		tb.Fatalf("Failed to re-exec test: %s", err)
+	}
+}
+
+// killCgroup kills all processes in cgroup and waits for it to be empty.
+// If cgroup.kill is not supported (before Linux 5.14), processes listed
+// in cgroup.procs are killed instead.
+func killCgroup(cgroup string) error {
+	err := os.WriteFile(filepath.Join(cgroup, "cgroup.kill"), []byte("1"), 0o644)
+	for range 100 {
+		buf, rerr := os.ReadFile(filepath.Join(cgroup, "cgroup.procs"))
+		if rerr != nil {
+			return fmt.Errorf("failed to read cgroup.procs: %w", rerr)
+		}
+
+		pids := strings.Fields(string(buf))
+		if len(pids) == 0 {
+			return nil
+		}
+
+		if err != nil {
+			for _, item := range pids {
+				if pid, perr := strconv.Atoi(item); perr == nil {
+					_ = unix.Kill(pid, unix.SIGKILL)
+				}
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("processes in cgroup %s did not exit", cgroup)
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -37,24 +38,16 @@ func HasCommandSystemdRun() bool {
 
 //nolint:gochecknoglobals
 var (
-	hasCPUControllerCache bool
-	hasCPUControllerOnce  sync.Once
+	delegatedControllersCache string
+	delegatedControllersErr   error
+	delegatedControllersOnce  sync.Once
 )
 
-// SkipIfCPUControllerNotAvailable skips the test if CPU controller is not available.
-// See https://github.com/systemd/systemd/pull/23887. This does not change test coverage
-// much as unit test can use interfaces to emulate responses.
-func SkipIfCPUControllerNotAvailable(tb testing.TB) {
+// delegatedControllers returns controllers delegated to user manager
+// of the current user, as reported by systemctl.
+func delegatedControllers() (string, error) {
 	// systemctl show user@$(id -u).service --property=DelegateControllers
-	hasCPUControllerOnce.Do(func() {
-		uid := os.Getuid()
-		// Assume root always has access to CPU controller.
-		// Tests do not support running in a systemd unit with already applied
-		// resource limits or cgroup sandbox options.
-		if uid == 0 {
-			hasCPUControllerCache = true
-			return
-		}
+	delegatedControllersOnce.Do(func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
@@ -63,37 +56,135 @@ func SkipIfCPUControllerNotAvailable(tb testing.TB) {
 			"systemctl",
 			"show",
 			"--property=DelegateControllers",
-			fmt.Sprintf("user@%d.service", uid),
+			fmt.Sprintf("user@%d.service", os.Getuid()),
 		)
 		buf := &bytes.Buffer{}
 		cmd.Stderr = buf
 		cmd.Stdout = buf
 
-		tb.Log("Checking is CPU controllers are available")
 		err := cmd.Run()
 		if err != nil {
-			tb.Errorf("Failed to run cmd '%s': %s", cmd, err)
-		}
-
-		tb.Logf("systemctl output: %s", buf.String())
-		if strings.Contains(buf.String(), "cpu") {
-			hasCPUControllerCache = true
+			delegatedControllersErr = fmt.Errorf("failed to run cmd '%s': %w: %s", cmd, err, buf.String())
+			return
 		}
+		delegatedControllersCache = buf.String()
 	})
-	if !hasCPUControllerCache {
+	return delegatedControllersCache, delegatedControllersErr
+}
+
+// SkipIfCPUControllerNotAvailable skips the test if CPU controller is not available.
+// See https://github.com/systemd/systemd/pull/23887. This does not change test coverage
+// much as unit test can use interfaces to emulate responses.
+func SkipIfCPUControllerNotAvailable(tb testing.TB) {
+	// Assume root always has access to CPU controller.
+	// Tests do not support running in a systemd unit with already applied
+	// resource limits or cgroup sandbox options.
+	if os.Getuid() == 0 {
+		return
+	}
+
+	tb.Log("Checking is CPU controllers are available")
+	controllers, err := delegatedControllers()
+	if err != nil {
+		tb.Errorf("%s", err)
+	}
+
+	tb.Logf("systemctl output: %s", controllers)
+	if !slices.Contains(strings.Fields(strings.TrimPrefix(controllers, "DelegateControllers=")), "cpu") {
 		tb.Skipf("CPUController is not available. See https://github.com/systemd/systemd/pull/23887")
 	}
 }
 
+// useSystemd reports whether systemd backend should be used. Otherwise,
+// cgroupfs backend is used, which manages cgroups directly.
+func useSystemd() bool {
+	if !HasCommandSystemdRun() {
+		return false
+	}
+
+	// See sd_booted(3).
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}
+
+// requiredControllers returns cgroup controllers required by opts.
+func requiredControllers(opts Options) []string {
+	var required []string
+	if opts.CPU > 0 {
+		required = append(required, "cpu")
+	}
+
+	if opts.CPUSet != "" {
+		required = append(required, "cpuset")
+	}
+
+	if opts.M1 > 0 || opts.M2 > 0 {
+		required = append(required, "memory")
+	}
+
+	if opts.PIDs > 0 {
+		required = append(required, "pids")
+	}
+	return required
+}
+
+func check(opts Options) error {
+	if opts.CPU > float64(runtime.NumCPU()) {
+		return fmt.Errorf("CPU=%f > runtime.NumCPU(%d)", opts.CPU, runtime.NumCPU())
+	}
+
+	if useSystemd() {
+		return checkSystemd(opts)
+	}
+
+	if _, err := checkCgroupFS(opts); err != nil {
+		if !HasCommandSystemdRun() {
+			return fmt.Errorf("systemd-run command is not available and %w", err)
+		}
+		return fmt.Errorf("systemd is not running and %w", err)
+	}
+	return nil
+}
+
+// checkSystemd checks if systemd backend can be used with given options.
+func checkSystemd(opts Options) error {
+	// Assume root always has access to all controllers.
+	if os.Getuid() == 0 {
+		return nil
+	}
+
+	required := requiredControllers(opts)
+	if len(required) == 0 {
+		return nil
+	}
+
+	controllers, err := delegatedControllers()
+	if err != nil {
+		return err
+	}
+
+	available := strings.Fields(strings.TrimPrefix(strings.TrimSpace(controllers), "DelegateControllers="))
+	var missing []string
+	for _, item := range required {
+		if !slices.Contains(available, item) {
+			missing = append(missing, item)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("controllers %s are not delegated to user manager. "+
+			"See https://github.com/systemd/systemd/pull/23887", strings.Join(missing, ","))
+	}
+	return nil
+}
+
+// trampoline re-execs the test via systemd-run if systemd is available.
+// Otherwise, it manages cgroups directly via delegated cgroupfs.
 func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configure func()) {
 	if verify == nil {
 		tb.Fatalf("no verify function defined")
 	}
 
-	if !HasCommandSystemdRun() {
-		tb.Fatalf("systemd-run command is not available")
-	}
-
 	// Options default overrides.
 	if opts.Timeout <= 0 {
 		opts.Timeout = time.Second * 30
@@ -113,17 +204,68 @@ func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configu
 		return
 	}
 
-	// Skip if CPU controller is not available.
-	if opts.CPU > 0 {
-		SkipIfCPUControllerNotAvailable(tb)
-	}
-
 	// Skip if available CPUs < configured CPUs. Though systemd handles
 	// this fine It is not supported on Windows.
 	if opts.CPU > float64(runtime.NumCPU()) {
 		tb.Skipf("CPU=%f > runtime.NumCPU(%d)", opts.CPU, runtime.NumCPU())
 	}
 
+	if useSystemd() {
+		trampolineSystemd(tb, opts)
+	} else {
+		trampolineCgroupFS(tb, opts)
+	}
+}
+
+// testCommand returns the test executable and arguments to re-exec
+// the current test. Env variables in opts are validated.
+func testCommand(tb testing.TB, opts Options) (string, []string) {
+	// Check Env variables do not include GO_TEST_EXEC_TRAMPOLINE.
+	for _, item := range opts.Env {
+		if strings.Contains(strings.ToUpper(item), "GO_TEST_EXEC_TRAMPOLINE") {
+			tb.Fatalf("env GO_TEST_EXEC_TRAMPOLINE is already defined")
+		}
+	}
+
+	// Breaker.
+	for _, item := range os.Environ() {
+		if strings.Contains(strings.ToUpper(item), "GO_TEST_EXEC_TRAMPOLINE") {
+			tb.Fatalf("env GO_TEST_EXEC_TRAMPOLINE is already defined")
+		}
+	}
+
+	// Get Current Executable.
+	exe, err := os.Executable()
+	if err != nil {
+		tb.Fatalf("failed to get executable: %s", err)
+	}
+
+	args := []string{
+		// Only run a single test.
+		fmt.Sprintf("-test.run=^%s$", tb.Name()),
+		// Apply default timeout.
+		fmt.Sprintf("-test.timeout=%s", opts.Timeout),
+		// Always enable verbose logs. These are not necessarily printed
+		// to stderr unless verbose logs are enabled.
+		"-test.v=true",
+	}
+
+	// The return value will be empty if test coverage is not enabled.
+	if v := CoverDir(tb); v != "" {
+		args = append(args, fmt.Sprintf("-test.gocoverdir=%s", v))
+	}
+	return exe, args
+}
+
+// trampolineSystemd re-execs the test in a transient unit via [systemd-run].
+//
+// [systemd-run]: https://www.freedesktop.org/software/systemd/man/latest/systemd-run.html
+func trampolineSystemd(tb testing.TB, opts Options) {
+	// Skip if CPU controller is not available.
+	if opts.CPU > 0 {
+		SkipIfCPUControllerNotAvailable(tb)
+	}
+
 	// User or system systemd instance to use.
 	userOrSystem := "--user"
 	if unix.Geteuid() == 0 {
@@ -140,35 +282,15 @@ func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configu
 		"--pipe",     // do not log to journald, instead stream to pipe
 	}
 
-	// Check Env variables do not include GO_TEST_EXEC_TRAMPOLINE.
-	// and build --setenv arguments.
+	exe, testArgs := testCommand(tb, opts)
+
+	// Build --setenv arguments.
 	for _, item := range opts.Env {
 		if item != "" {
-			if strings.Contains(strings.ToUpper(item), "GO_TEST_EXEC_TRAMPOLINE") {
-				tb.Fatalf("env GO_TEST_EXEC_TRAMPOLINE is already defined")
-			} else {
-				args = append(
-					args,
-					fmt.Sprintf("--setenv=%s", item),
-				)
-			}
+			args = append(args, fmt.Sprintf("--setenv=%s", item))
 		}
 	}
 
-	// Breaker.
-	envv := os.Environ()
-	for _, item := range envv {
-		if strings.Contains(strings.ToUpper(item), "GO_TEST_EXEC_TRAMPOLINE") {
-			tb.Fatalf("env GO_TEST_EXEC_TRAMPOLINE is already defined")
-		}
-	}
-
-	// Get Current Executable.
-	exe, err := os.Executable()
-	if err != nil {
-		tb.Fatalf("failed to get executable: %s", err)
-	}
-
 	// CPU limit flags.
 	if opts.CPU > 0 {
 		args = append(
@@ -187,6 +309,16 @@ func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configu
 		args = append(args, fmt.Sprintf("--property=MemoryHigh=%d", opts.M2))
 	}
 
+	// CPUSet corresponds to cpuset.cpus
+	if opts.CPUSet != "" {
+		args = append(args, fmt.Sprintf("--property=AllowedCPUs=%s", opts.CPUSet))
+	}
+
+	// PIDs corresponds to pids.max
+	if opts.PIDs > 0 {
+		args = append(args, fmt.Sprintf("--property=TasksMax=%d", opts.PIDs))
+	}
+
 	// Set timeouts.
 	//
 	// Ideally we would set per set timeouts, but they are not available yet.
@@ -201,26 +333,15 @@ func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configu
 		// Pass other arguments to test binary.
 		"--",
 		exe,
-		// Only run a single test.
-		fmt.Sprintf("-test.run=^%s$", tb.Name()),
-		// Apply default timeout.
-		fmt.Sprintf("-test.timeout=%s", opts.Timeout),
-		// Always enable verbose logs. These are not necessarily printed
-		// to stderr unless verbose logs are enabled.
-		"-test.v=true",
 	)
-
-	// The return value will be empty if test coverage is not enabled.
-	if v := CoverDir(tb); v != "" {
-		args = append(args, fmt.Sprintf("-test.gocoverdir=%s", v))
-	}
+	args = append(args, testArgs...)
 
 	cmd := exec.CommandContext(ctx, "systemd-run", args...)
 	cmd.Stdin = nil
 	cmd.Stdout = NewWriter(tb, "trampoline")
 	cmd.Stderr = NewWriter(tb, "trampoline")
 	tb.Logf("Running via : %v", cmd.Args)
-	err = cmd.Run()
+	err := cmd.Run()
 	if err != nil {
 		tb.Fatalf("Failed to re-exec test: %s", err)
 	}
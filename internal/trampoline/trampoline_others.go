@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -39,10 +40,10 @@ func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configu
 		return
 	}
 
-	// If CPUm M1 or M2 are defined, then error,
+	// If resource limits are defined, then error,
 	// as they are only supported on windows and linux.
-	if opts.CPU > 0 || opts.M1 > 0 || opts.M2 > 0 {
-		tb.Errorf("Resource limits not supported: CPU=%f, M1=%d, M2=%d", opts.CPU, opts.M1, opts.M2)
+	if err := check(opts); err != nil {
+		tb.Errorf("%s", err)
 	}
 
 	// Check Env variables do not include GO_TEST_EXEC_TRAMPOLINE.
@@ -97,3 +98,11 @@ func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configu
 		tb.Fatalf("Failed to re-exec test: %s", err)
 	}
 }
+
+func check(opts Options) error {
+	if opts.CPU > 0 || opts.M1 > 0 || opts.M2 > 0 || opts.CPUSet != "" || opts.PIDs > 0 {
+		return fmt.Errorf("resource limits are not supported on %s: CPU=%f, M1=%d, M2=%d, CPUSet=%q, PIDs=%d",
+			runtime.GOOS, opts.CPU, opts.M1, opts.M2, opts.CPUSet, opts.PIDs)
+	}
+	return nil
+}
@@ -151,6 +151,11 @@ func trampoline(tb testing.TB, opts Options, verify func(tb testing.TB), configu
 		tb.Skipf("CPU=%f > runtime.NumCPU(%d)", opts.CPU, runtime.NumCPU())
 	}
 
+	// CPUSet and PIDs are not supported.
+	if opts.CPUSet != "" || opts.PIDs > 0 {
+		tb.Errorf("Resource limits not supported: CPUSet=%q, PIDs=%d", opts.CPUSet, opts.PIDs)
+	}
+
 	// Set timeouts.
 	//
 	// Ideally we would set per set timeouts, but they are not available yet.
@@ -443,3 +448,15 @@ func addCriticalEnv(env []string) []string {
 	}
 	return append(env, "SYSTEMROOT="+os.Getenv("SYSTEMROOT"))
 }
+
+func check(opts Options) error {
+	if opts.CPUSet != "" || opts.PIDs > 0 {
+		return fmt.Errorf("resource limits are not supported on windows: CPUSet=%q, PIDs=%d",
+			opts.CPUSet, opts.PIDs)
+	}
+
+	if opts.CPU > float64(runtime.NumCPU()) {
+		return fmt.Errorf("CPU=%f > runtime.NumCPU(%d)", opts.CPU, runtime.NumCPU())
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package maxprocs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+)
+
+// Capacity describes CPU capacity available to the workload. Unlike GOMAXPROCS,
+// which is an integer, this retains fractional CPU quota, which is useful for sizing
+// worker pools, server concurrency and batch parallelism with a rounding policy of
+// their own. See [Budget].
+type Capacity struct {
+	// Effective number of CPUs available to the workload. This is the lowest of
+	// CPU quota, number of CPUs in effective cpuset and [runtime.NumCPU], and is
+	// fractional if CPU quota is fractional.
+	CPU float64 `json:"cpu"`
+
+	// CPU quota, in number of CPUs. Zero if not defined.
+	Quota float64 `json:"quota,omitempty"`
+
+	// Number of CPUs in effective cpuset. Zero if not supported.
+	CPUSet int `json:"cpuset,omitempty"`
+
+	// Number of logical CPUs usable by the process, as returned by [runtime.NumCPU].
+	NumCPU int `json:"num_cpu"`
+
+	// Source of CPU limits, for example cgroup or jobobject.
+	// This is runtime if the platform does not support CPU limits.
+	Source string `json:"source"`
+}
+
+// EffectiveCPU returns CPU capacity available to the workload, obtained from the
+// detector specified via [WithCPUQuotaDetector], which should implement [CPUInfoDetector]
+// to report number of CPUs in effective cpuset. CPU capacity is scaled by share
+// of the process, if [WithShare] or [WithProcessShare] is specified. Other options
+// are ignored. GOMAXPROCS environment variable is not considered.
+//
+// If the platform does not support CPU limits, capacity is [runtime.NumCPU]
+// and no error is returned.
+func EffectiveCPU(ctx context.Context, opts ...Option) (Capacity, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cfg := newConfig(opts...)
+	rv := Capacity{
+		CPU:    float64(runtime.NumCPU()),
+		NumCPU: runtime.NumCPU(),
+		Source: "runtime",
+	}
+
+	info, err := shared.Call(ctx, func(ctx context.Context) (CPUInfo, error) {
+		return detectCPUInfo(ctx, cfg.detector)
+	})
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return rv, nil
+		}
+		return rv, fmt.Errorf("maxprocs: %w", err)
+	}
+
+	info, _ = shareCPUInfo(ctx, cfg, info)
+	rv.Quota = info.Quota
+	rv.CPUSet = info.CPUSet
+	rv.NumCPU = info.NumCPU
+	rv.Source = info.Source
+	rv.CPU = float64(info.NumCPU)
+	if info.CPUSet > 0 {
+		rv.CPU = min(rv.CPU, float64(info.CPUSet))
+	}
+
+	if info.Quota > 0 {
+		rv.CPU = min(rv.CPU, info.Quota)
+	}
+	return rv, nil
+}
+
+// WithResizeHandler configures a handler which is called by [Watch] with
+// CPU capacity, initially and whenever it changes. This can be used to resize
+// worker pools, for example with [Budget]. Handler runs on the goroutine
+// running [Watch], and must not block for long.
+func WithResizeHandler(fn func(ctx context.Context, capacity Capacity)) Option {
+	if fn != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.resizeHandler = fn
+			},
+		}
+	}
+	return nil
+}
+
+// Watch configures GOMAXPROCS with [Apply] every interval, until ctx is done.
+// This is useful when CPU limits are expected to change, for example with
+// Kubernetes [in place resource resize]. If [WithResizeHandler] is specified,
+// handler is called with CPU capacity (see [EffectiveCPU]), initially and whenever
+// it changes. Errors are logged and do not stop the watch. This always returns
+// a non-nil error, which wraps ctx error when ctx is done.
+//
+// [in place resource resize]: https://kubernetes.io/docs/tasks/configure-pod-container/resize-container-resources/
+func Watch(ctx context.Context, interval time.Duration, opts ...Option) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("maxprocs: invalid watch interval: %s", interval)
+	}
+
+	cfg := newConfig(opts...)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var previous Capacity
+	var initialized bool
+	for {
+		// Errors are already logged by Apply.
+		_, err := Apply(ctx, opts...)
+		if err != nil && errors.Is(err, ctx.Err()) {
+			return fmt.Errorf("maxprocs: %w", err)
+		}
+
+		if cfg.resizeHandler != nil {
+			capacity, err := EffectiveCPU(ctx, opts...)
+			switch {
+			case err != nil && errors.Is(err, ctx.Err()):
+				return err
+			case err != nil:
+				cfg.logger.LogAttrs(ctx, slog.LevelError, "Failed to obtain cpu capacity",
+					slog.Any("err", err),
+				)
+			case !initialized || capacity != previous:
+				cfg.logger.LogAttrs(ctx, slog.LevelInfo, "CPU capacity changed",
+					slog.Float64("cpu", capacity.CPU),
+					slog.Float64("cpu.quota", capacity.Quota),
+					slog.Int("cpu.cpuset", capacity.CPUSet),
+				)
+				cfg.resizeHandler(ctx, capacity)
+				previous, initialized = capacity, true
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("maxprocs: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Budget splits CPU capacity among named subsystems, like worker pools or
+// server concurrency, proportional to their weights. Subsystems with weight
+// which is not positive or is infinite are ignored.
+//
+//	budget := maxprocs.Budget{"http": 2, "batch": 1}
+//	workers := budget.Workers(capacity.CPU, nil) // With 3 CPUs, http=2, batch=1
+type Budget map[string]float64
+
+// Split splits cpu among subsystems in proportion to their weights.
+func (b Budget) Split(cpu float64) map[string]float64 {
+	var total float64
+	for _, weight := range b {
+		if weight > 0 && !math.IsInf(weight, 0) {
+			total += weight
+		}
+	}
+
+	rv := make(map[string]float64, len(b))
+	if total <= 0 || cpu <= 0 || math.IsNaN(cpu) {
+		return rv
+	}
+
+	for name, weight := range b {
+		if weight > 0 && !math.IsInf(weight, 0) {
+			rv[name] = cpu * weight / total
+		}
+	}
+	return rv
+}
+
+// Workers splits cpu among subsystems in proportion to their weights, and rounds
+// share of each subsystem with round. If round is nil, [math.Floor] is used.
+// Each subsystem gets at least 1 worker.
+func (b Budget) Workers(cpu float64, round func(float64) int) map[string]int {
+	if round == nil {
+		round = func(f float64) int {
+			return int(math.Floor(f))
+		}
+	}
+
+	shares := b.Split(cpu)
+	rv := make(map[string]int, len(shares))
+	for name, share := range shares {
+		rv[name] = max(round(share), 1)
+	}
+	return rv
+}
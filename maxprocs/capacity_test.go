@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package maxprocs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+)
+
+func TestEffectiveCPU(t *testing.T) {
+	numCPU := runtime.NumCPU()
+	tt := []struct {
+		name     string
+		detector maxprocs.CPUQuotaDetector
+		expect   maxprocs.Capacity
+		err      bool
+	}{
+		{
+			name:     "Quota",
+			detector: infoDetector{Quota: 0.5, Source: "cgroup"},
+			expect:   maxprocs.Capacity{CPU: 0.5, Quota: 0.5, NumCPU: numCPU, Source: "cgroup"},
+		},
+		{
+			name:     "CPUSetLowerThanQuota",
+			detector: infoDetector{Quota: 2.5, CPUSet: 1, Source: "cgroup"},
+			expect:   maxprocs.Capacity{CPU: 1, Quota: 2.5, CPUSet: 1, NumCPU: numCPU, Source: "cgroup"},
+		},
+		{
+			name:     "NoLimits",
+			detector: infoDetector{Source: "cgroup"},
+			expect:   maxprocs.Capacity{CPU: float64(numCPU), NumCPU: numCPU, Source: "cgroup"},
+		},
+		{
+			name: "Unsupported",
+			detector: maxprocs.CPUQuotaDetectorFunc(func(context.Context) (float64, error) {
+				return 0, fmt.Errorf("test: %w", errors.ErrUnsupported)
+			}),
+			expect: maxprocs.Capacity{CPU: float64(numCPU), NumCPU: numCPU, Source: "runtime"},
+		},
+		{
+			name: "Error",
+			detector: maxprocs.CPUQuotaDetectorFunc(func(context.Context) (float64, error) {
+				return 0, errors.New("test: error")
+			}),
+			expect: maxprocs.Capacity{CPU: float64(numCPU), NumCPU: numCPU, Source: "runtime"},
+			err:    true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := maxprocs.EffectiveCPU(context.Background(),
+				maxprocs.WithCPUQuotaDetector(tc.detector))
+			if tc.err != (err != nil) {
+				t.Errorf("expected error=%t, got=%v", tc.err, err)
+			}
+
+			// Quota is always lower than NumCPU, except when NumCPU is 1.
+			tc.expect.CPU = min(tc.expect.CPU, float64(numCPU))
+			if v != tc.expect {
+				t.Errorf("expected=%+v, got=%+v", tc.expect, v)
+			}
+		})
+	}
+}
+
+func TestBudget(t *testing.T) {
+	budget := maxprocs.Budget{"http": 2, "batch": 1, "ignored": 0, "negative": -1, "inf": math.Inf(1)}
+	tt := []struct {
+		name   string
+		cpu    float64
+		round  func(float64) int
+		expect map[string]int
+	}{
+		{name: "Zero", cpu: 0, expect: map[string]int{}},
+		{name: "NaN", cpu: math.NaN(), expect: map[string]int{}},
+		{name: "Exact", cpu: 3, expect: map[string]int{"http": 2, "batch": 1}},
+		{name: "Fractional", cpu: 1.5, expect: map[string]int{"http": 1, "batch": 1}},
+		{name: "Large", cpu: 16, expect: map[string]int{"http": 10, "batch": 5}},
+		{
+			name:   "Ceil",
+			cpu:    16,
+			round:  func(f float64) int { return int(math.Ceil(f)) },
+			expect: map[string]int{"http": 11, "batch": 6},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			v := budget.Workers(tc.cpu, tc.round)
+			if len(v) != len(tc.expect) {
+				t.Fatalf("expected=%v, got=%v", tc.expect, v)
+			}
+
+			for name, workers := range tc.expect {
+				if v[name] != workers {
+					t.Errorf("expected=%v, got=%v", tc.expect, v)
+				}
+			}
+		})
+	}
+
+	t.Run("Split", func(t *testing.T) {
+		v := budget.Split(1.5)
+		if len(v) != 2 || v["http"] != 1 || v["batch"] != 0.5 {
+			t.Errorf("expected http=1, batch=0.5, got=%v", v)
+		}
+	})
+}
+
+func TestWatch(t *testing.T) {
+	t.Cleanup(reset)
+	t.Setenv("GOMAXPROCS", "")
+
+	t.Run("InvalidInterval", func(t *testing.T) {
+		err := maxprocs.Watch(context.Background(), 0)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("Resize", func(t *testing.T) {
+		t.Cleanup(reset)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var quota atomic.Uint64
+		quota.Store(math.Float64bits(0.5))
+		detector := maxprocs.CPUQuotaDetectorFunc(func(context.Context) (float64, error) {
+			return math.Float64frombits(quota.Load()), nil
+		})
+
+		resized := make(chan maxprocs.Capacity, 8)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- maxprocs.Watch(ctx, 10*time.Millisecond,
+				maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				maxprocs.WithCPUQuotaDetector(detector),
+				maxprocs.WithResizeHandler(func(_ context.Context, c maxprocs.Capacity) {
+					resized <- c
+				}),
+			)
+		}()
+
+		for _, expect := range []float64{0.5, 0.25} {
+			select {
+			case c := <-resized:
+				if c.CPU != expect {
+					t.Errorf("expected cpu=%f, got=%f", expect, c.CPU)
+				}
+			case err := <-errCh:
+				t.Fatalf("watch returned early: %v", err)
+			case <-time.After(10 * time.Second):
+				t.Fatalf("timeout waiting for resize handler")
+			}
+			quota.Store(math.Float64bits(0.25))
+		}
+
+		// Handler is not called when capacity is unchanged.
+		select {
+		case c := <-resized:
+			t.Errorf("unexpected resize: %+v", c)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if maxprocs.Current() != 1 {
+			t.Errorf("expected GOMAXPROCS=1, got=%d", maxprocs.Current())
+		}
+
+		cancel()
+		if err := <-errCh; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package maxprocs_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/maxprocs"
+)
+
+// This example checks CPU limits every 10 seconds, and splits CPU capacity between
+// HTTP handlers and batch jobs in 2:1 ratio, whenever it changes.
+func ExampleWatch() {
+	ctx := context.Background()
+	budget := maxprocs.Budget{"http": 2, "batch": 1}
+	go func() {
+		err := maxprocs.Watch(ctx, 10*time.Second,
+			maxprocs.WithLogger(slog.Default()),
+			maxprocs.WithResizeHandler(func(_ context.Context, capacity maxprocs.Capacity) {
+				workers := budget.Workers(capacity.CPU, nil)
+				slog.Info("Resizing worker pools",
+					"http", workers["http"], "batch", workers["batch"])
+			}),
+		)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("Failed to watch CPU limits", "err", err)
+		}
+	}()
+}
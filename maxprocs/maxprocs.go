@@ -9,18 +9,115 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"math"
 	"os"
 	"runtime"
 	"strconv"
+	"sync/atomic"
 
 	"github.com/tprasadtp/go-autotune/internal/discard"
+	"github.com/tprasadtp/go-autotune/internal/sdnotify"
+	"github.com/tprasadtp/go-autotune/internal/shared"
 )
 
 type config struct {
 	logger    *slog.Logger
 	detector  CPUQuotaDetector
 	roundFunc func(float64) int
+	policy    CPUPolicy
+	preserve  bool
+	notify    bool
+
+	// Share of CPU limits. See [WithShare] and [WithProcessShare].
+	share        float64
+	processShare bool
+
+	// Options used by [Watch].
+	resizeHandler func(context.Context, Capacity)
+}
+
+// Source indicates how GOMAXPROCS value was determined.
+type Source string
+
+// Possible values for [Source].
+const (
+	// GOMAXPROCS is unchanged, as CPU quota is not defined
+	// or not supported on the platform.
+	SourceDefault Source = "default"
+
+	// GOMAXPROCS is set from GOMAXPROCS environment variable.
+	SourceEnv Source = "env"
+
+	// GOMAXPROCS is set from CPU quota.
+	SourceQuota Source = "quota"
+
+	// GOMAXPROCS was modified by another package and is left unchanged.
+	// See [WithPreserveExternal].
+	SourceExternal Source = "external"
+)
+
+// Result describes GOMAXPROCS value applied by [Apply].
+type Result struct {
+	// GOMAXPROCS value before applying.
+	Previous int `json:"previous"`
+
+	// GOMAXPROCS value after applying.
+	Value int `json:"value"`
+
+	// CPU quota detected. This is zero if CPU quota is not defined
+	// or was not checked.
+	Quota float64 `json:"quota,omitempty"`
+
+	// Source of the GOMAXPROCS value.
+	Source Source `json:"source"`
+
+	// Reason returned by [CPUPolicy], describing how GOMAXPROCS
+	// was computed from CPU limits. This is empty if CPU policy
+	// was not used.
+	Reason string `json:"reason,omitempty"`
+
+	// Share of CPU limits used for this process, when CPU limits are shared
+	// by multiple processes. See [WithShare] and [WithProcessShare]. This is
+	// zero if CPU limits are not shared.
+	Share float64 `json:"share,omitempty"`
+}
+
+// applied is the last GOMAXPROCS value set by [Configure]. It is used to detect
+// if GOMAXPROCS was modified by other packages. Zero indicates [Configure]
+// has not modified GOMAXPROCS yet.
+//
+//nolint:gochecknoglobals // tracks runtime state, which is global.
+var applied atomic.Int64
+
+// initial is GOMAXPROCS value at startup, before it is modified by [Configure].
+// This is the default chosen by the runtime, which is not always [runtime.NumCPU],
+// for example, newer Go versions use CPU quota of the container.
+//
+//nolint:gochecknoglobals // tracks runtime state, which is global.
+var initial = runtime.GOMAXPROCS(-1)
+
+// IsModified reports whether GOMAXPROCS was modified by something other than
+// GOMAXPROCS environment variable or [Configure]. This typically indicates use of
+// other packages which also modify GOMAXPROCS like [go.uber.org/automaxprocs]
+// or direct calls to [runtime.GOMAXPROCS]. Default value chosen by the runtime
+// is not considered as modified.
+//
+// [go.uber.org/automaxprocs]: https://pkg.go.dev/go.uber.org/automaxprocs
+func IsModified() bool {
+	if os.Getenv("GOMAXPROCS") != "" {
+		return false
+	}
+
+	current := Current()
+	if current == initial {
+		return false
+	}
+	return int64(current) != applied.Load()
+}
+
+// set sets GOMAXPROCS and records it as applied.
+func set(procs int) {
+	applied.Store(int64(procs))
+	runtime.GOMAXPROCS(procs)
 }
 
 // Current returns current GOMAXPROCS settings.
@@ -38,11 +135,21 @@ func Current() int {
 //     ensures maximum resource utilization.
 //   - If CPU quota is less than 1, GOMAXPROCS is set to 1.
 //
+// This is implemented by [DefaultCPUPolicy], and can be customized with [WithCPUPolicy].
+//
 // Workload with fractional CPU quota (for example, 2.1) may encounter some CPU
 // throttling. For workloads sensitive to CPU throttling, when using [Vertical Pod autoscaling]
 // it is recommended to set [cpu-integer-post-processor-enabled], to ensure CPU recommendation
 // is an integer.
 //
+// If GOMAXPROCS was already modified by another package (see [IsModified]),
+// a warning is logged and it is overridden, unless [WithPreserveExternal] is specified.
+//
+// Errors returned by the default detector wrap errors defined in package
+// [github.com/tprasadtp/go-autotune/cgroup], like [github.com/tprasadtp/go-autotune/cgroup.ErrCgroupV1]
+// and [github.com/tprasadtp/go-autotune/cgroup.ParseError], and can be checked with
+// [errors.Is] and [errors.As].
+//
 // For Windows containers with Hyper-V isolation, hypervisor emulates specified
 // CPU cores, thus the default value of GOMAXPROCS is optimal and need not be changed.
 //
@@ -51,14 +158,84 @@ func Current() int {
 // [Vertical Pod autoscaling]: https://github.com/kubernetes/autoscaler/tree/master/vertical-pod-autoscaler
 // [cpu-integer-post-processor-enabled]: https://github.com/kubernetes/autoscaler/blob/master/vertical-pod-autoscaler/FAQ.md#what-are-the-parameters-to-vpa-recommender
 func Configure(ctx context.Context, opts ...Option) error {
+	_, err := Apply(ctx, opts...)
+	return err
+}
+
+// Apply is same as [Configure], but also returns a [Result] describing
+// the applied GOMAXPROCS value and its [Source]. Even when an error is
+// returned, [Result] contains current GOMAXPROCS value.
+func Apply(ctx context.Context, opts ...Option) (Result, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	if ctx.Err() != nil {
-		return fmt.Errorf("maxprocs: %w", ctx.Err())
+	cfg := newConfig(opts...)
+	result, err := compute(ctx, cfg)
+	if err != nil {
+		return result, err
+	}
+
+	switch result.Source {
+	case SourceEnv, SourceQuota:
+		if result.Previous != result.Value {
+			if result.Source == SourceEnv {
+				cfg.logger.LogAttrs(ctx, slog.LevelInfo,
+					"Setting GOMAXPROCS from environment variable",
+					slog.String("GOMAXPROCS", strconv.FormatInt(int64(result.Value), 10)))
+			} else {
+				cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Setting GOMAXPROCS",
+					slog.String("GOMAXPROCS", strconv.FormatInt(int64(result.Value), 10)),
+					slog.String("reason", result.Reason))
+			}
+			set(result.Value)
+		} else {
+			// Record value as applied, even though it is unchanged. As [Watch]
+			// calls Apply periodically, only log at info level the first time.
+			level := slog.LevelInfo
+			if applied.Swap(int64(result.Value)) == int64(result.Value) {
+				level = slog.LevelDebug
+			}
+			if result.Source == SourceEnv {
+				cfg.logger.LogAttrs(ctx, level,
+					"GOMAXPROCS is already set from environment variable",
+					slog.String("GOMAXPROCS", strconv.FormatInt(int64(result.Value), 10)))
+			} else {
+				cfg.logger.LogAttrs(ctx, level, "GOMAXPROCS is already set",
+					slog.String("GOMAXPROCS", strconv.FormatInt(int64(result.Value), 10)))
+			}
+		}
+	default:
+		// GOMAXPROCS is left unchanged.
+	}
+
+	if cfg.notify {
+		notify(ctx, cfg, result.Value, string(result.Source))
+	}
+	return result, nil
+}
+
+// notify reports GOMAXPROCS to the service manager. Errors are only logged.
+func notify(ctx context.Context, cfg *config, procs int, source string) {
+	err := sdnotify.UpdateMaxProcs(procs, source)
+	if err != nil {
+		cfg.logger.LogAttrs(ctx, slog.LevelWarn, "Failed to notify service manager",
+			slog.Any("err", err),
+		)
+	}
+}
+
+// Compute returns GOMAXPROCS value which would be set by [Apply],
+// without modifying it. This is useful for diagnostics.
+func Compute(ctx context.Context, opts ...Option) (Result, error) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	return compute(ctx, newConfig(opts...))
+}
 
+// newConfig builds config from options and applies defaults.
+func newConfig(opts ...Option) *config {
 	// Apply all options.
 	cfg := &config{}
 	for i := range opts {
@@ -77,80 +254,122 @@ func Configure(ctx context.Context, opts ...Option) error {
 		cfg.detector = DefaultCPUQuotaDetector()
 	}
 
-	// If rounding function is not specified, use math.Ceil
-	if cfg.roundFunc == nil {
-		cfg.roundFunc = func(f float64) int {
-			return int(math.Ceil(f))
+	// If CPU policy is not specified, round CPU quota using rounding
+	// function. If rounding function is not specified, use math.Ceil.
+	if cfg.policy == nil {
+		if cfg.roundFunc != nil {
+			cfg.policy = RoundPolicy(cfg.roundFunc)
+		} else {
+			cfg.policy = DefaultCPUPolicy()
 		}
 	}
+	return cfg
+}
 
+// compute computes GOMAXPROCS value to set, but does not set it.
+func compute(ctx context.Context, cfg *config) (Result, error) {
 	snapshot := Current()
+	result := Result{
+		Previous: snapshot,
+		Value:    snapshot,
+		Source:   SourceDefault,
+	}
+
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("maxprocs: %w", ctx.Err())
+	}
 
 	// Check if GOMAXPROCS env variable is set.
 	env := os.Getenv("GOMAXPROCS")
 	if env != "" {
 		maxProcsEnv, err := strconv.Atoi(env)
 		if err == nil && maxProcsEnv > 0 {
-			if snapshot != maxProcsEnv {
-				cfg.logger.LogAttrs(ctx, slog.LevelInfo,
-					"Setting GOMAXPROCS from environment variable",
-					slog.String("GOMAXPROCS", env))
-				runtime.GOMAXPROCS(maxProcsEnv)
-			} else {
-				cfg.logger.LogAttrs(ctx, slog.LevelInfo,
-					"GOMAXPROCS is already set from environment variable",
-					slog.String("GOMAXPROCS", env))
-			}
-			return nil
+			result.Value = maxProcsEnv
+			result.Source = SourceEnv
+			return result, nil
 		}
 
-		return fmt.Errorf("maxprocs: invalid GOMAXPROCS environment variable: %q", env)
+		return result, fmt.Errorf("maxprocs: invalid GOMAXPROCS environment variable: %q", env)
 	}
 
-	// Get CPU quota.
-	quota, err := cfg.detector.DetectCPUQuota(ctx)
+	// Check if GOMAXPROCS was modified by other packages.
+	if IsModified() {
+		if cfg.preserve {
+			cfg.logger.LogAttrs(ctx, slog.LevelWarn,
+				"GOMAXPROCS was modified by another package, leaving it unchanged",
+				slog.String("GOMAXPROCS", strconv.FormatInt(int64(snapshot), 10)),
+			)
+			result.Source = SourceExternal
+			return result, nil
+		}
+		cfg.logger.LogAttrs(ctx, slog.LevelWarn,
+			"GOMAXPROCS was modified by another package, overriding it",
+			slog.String("GOMAXPROCS", strconv.FormatInt(int64(snapshot), 10)),
+		)
+	}
+
+	// Get CPU limits.
+	info, err := shared.Call(ctx, func(ctx context.Context) (CPUInfo, error) {
+		return detectCPUInfo(ctx, cfg.detector)
+	})
 	if err != nil {
 		// Ignore unsupported platform error and do nothing.
 		if errors.Is(err, errors.ErrUnsupported) {
-			return nil
+			return result, nil
 		}
 		cfg.logger.LogAttrs(ctx, slog.LevelError, "Failed to obtain cpu quota",
 			slog.Any("err", err),
 		)
-		return fmt.Errorf("maxprocs: %w", err)
+		return result, fmt.Errorf("maxprocs: %w", err)
 	}
 
-	if quota <= 0 {
-		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "CPU quota is not defined")
-		return nil
+	if info.Quota > 0 {
+		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Successfully obtained cpu quota",
+			slog.Float64("cpu.quota", info.Quota),
+			slog.String("cpu.source", info.Source),
+		)
+		result.Quota = info.Quota
 	}
 
-	cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Successfully obtained cpu quota",
-		slog.Float64("cpu.quota", quota),
-	)
+	// Scale CPU quota by share of this process, if CPU limits are shared.
+	var share float64
+	info, share = shareCPUInfo(ctx, cfg, info)
+	if share < 1 {
+		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Scaling cpu quota by share of the process",
+			slog.Float64("cpu.share", share),
+			slog.Float64("cpu.quota", info.Quota),
+			slog.Int("cpu.procs", info.Procs),
+		)
+		result.Share = share
+	}
 
-	// Round off fractional CPU using defined RoundFunc. Default is math.Ceil.
-	procs := cfg.roundFunc(quota)
+	// Compute GOMAXPROCS using defined CPU policy. Default is math.Ceil of CPU quota.
+	procs, reason, err := maxProcs(cfg.policy, info)
+	if err != nil {
+		cfg.logger.LogAttrs(ctx, slog.LevelError, "CPU policy failed",
+			slog.Any("err", err),
+		)
+		return result, fmt.Errorf("maxprocs: CPU policy failed: %w", err)
+	}
 
 	if procs < 0 {
-		return fmt.Errorf("maxprocs: RoundFunc returned negative value: %d", procs)
+		return result, fmt.Errorf("maxprocs: CPU policy returned negative value: %d", procs)
 	}
 
-	// GOMAXPROCS ensure at-least 1
-	if procs < 1 {
+	if procs == 0 {
+		if info.Quota <= 0 {
+			cfg.logger.LogAttrs(ctx, slog.LevelInfo, "CPU quota is not defined",
+				slog.String("reason", reason))
+			return result, nil
+		}
+
+		// GOMAXPROCS ensure at-least 1
 		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Selecting minimum possible GOMAXPROCS value")
 		procs = 1
 	}
 
-	if snapshot != procs {
-		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Setting GOMAXPROCS",
-			slog.String("GOMAXPROCS", strconv.FormatInt(int64(procs), 10)),
-		)
-		runtime.GOMAXPROCS(procs)
-	} else {
-		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "GOMAXPROCS is already set",
-			slog.String("GOMAXPROCS", strconv.FormatInt(int64(procs), 10)),
-		)
-	}
-	return nil
+	result.Value = procs
+	result.Source = SourceQuota
+	result.Reason = reason
+	return result, nil
 }
@@ -4,6 +4,7 @@
 package maxprocs_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -11,7 +12,9 @@ import (
 	"math"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tprasadtp/go-autotune/internal/trampoline"
 	"github.com/tprasadtp/go-autotune/maxprocs"
@@ -219,3 +222,257 @@ func TestConfigure(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigureModified(t *testing.T) {
+	tt := []struct {
+		name     string
+		preserve bool
+		expect   int
+	}{
+		{
+			name:   "Override",
+			expect: 1,
+		},
+		{
+			name:     "Preserve",
+			preserve: true,
+			expect:   runtime.NumCPU() + 7,
+		},
+	}
+	t.Cleanup(reset) // avoid side effects in other tests.
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+			t.Setenv("GOMAXPROCS", "")
+
+			// Emulate another package modifying GOMAXPROCS.
+			runtime.GOMAXPROCS(runtime.NumCPU() + 7)
+			if !maxprocs.IsModified() {
+				t.Fatalf("expected IsModified to be true")
+			}
+
+			err := maxprocs.Configure(context.Background(),
+				maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				maxprocs.WithPreserveExternal(tc.preserve),
+				maxprocs.WithCPUQuotaDetector(
+					maxprocs.CPUQuotaDetectorFunc(
+						func(context.Context) (float64, error) {
+							return 0.5, nil
+						},
+					),
+				),
+			)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+
+			if v := maxprocs.Current(); v != tc.expect {
+				t.Errorf("GOMAXPROCS expected=%d, got=%d", tc.expect, v)
+			}
+
+			// Values set by Configure are not considered as modified.
+			if !tc.preserve && maxprocs.IsModified() {
+				t.Errorf("expected IsModified to be false after Configure")
+			}
+		})
+	}
+}
+
+func TestApplyUnchanged(t *testing.T) {
+	t.Cleanup(reset)
+	t.Setenv("GOMAXPROCS", "")
+
+	// Emulate another package setting GOMAXPROCS to the value Apply would set.
+	runtime.GOMAXPROCS(2)
+	detector := maxprocs.CPUQuotaDetectorFunc(func(context.Context) (float64, error) {
+		return 2, nil
+	})
+	policy := maxprocs.CPUPolicyFunc(func(maxprocs.CPUInfo) (int, string) {
+		return 2, "test"
+	})
+
+	// Unchanged values are logged at info level only once, as [maxprocs.Watch]
+	// applies periodically.
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	for i, preserve := range []bool{false, true, false} {
+		result, err := maxprocs.Apply(context.Background(),
+			maxprocs.WithLogger(logger),
+			maxprocs.WithPreserveExternal(preserve),
+			maxprocs.WithCPUQuotaDetector(detector),
+			maxprocs.WithCPUPolicy(policy),
+		)
+		if err != nil {
+			t.Fatalf("apply=%d expected no error, got %s", i, err)
+		}
+
+		if result.Source != maxprocs.SourceQuota || result.Value != 2 {
+			t.Errorf("apply=%d expected source=%s, value=2, got=%+v", i, maxprocs.SourceQuota, result)
+		}
+
+		// Unchanged values are recorded as applied.
+		if maxprocs.IsModified() {
+			t.Errorf("apply=%d expected IsModified to be false", i)
+		}
+	}
+
+	if v := strings.Count(buf.String(), `level=INFO msg="GOMAXPROCS is already set"`); v > 1 {
+		t.Errorf("expected at most one info log, got=%d\n%s", v, buf.String())
+	}
+
+	if v := strings.Count(buf.String(), `level=DEBUG msg="GOMAXPROCS is already set"`); v < 2 {
+		t.Errorf("expected at least two debug logs, got=%d\n%s", v, buf.String())
+	}
+}
+
+func TestApply(t *testing.T) {
+	tt := []struct {
+		name   string
+		env    string
+		quota  float64
+		expect maxprocs.Result
+	}{
+		{
+			name: "Default",
+			expect: maxprocs.Result{
+				Previous: runtime.NumCPU(),
+				Value:    runtime.NumCPU(),
+				Source:   maxprocs.SourceDefault,
+			},
+		},
+		{
+			name: "Env",
+			env:  "1",
+			expect: maxprocs.Result{
+				Previous: runtime.NumCPU(),
+				Value:    1,
+				Source:   maxprocs.SourceEnv,
+			},
+		},
+		{
+			name:  "Quota",
+			quota: 0.5,
+			expect: maxprocs.Result{
+				Previous: runtime.NumCPU(),
+				Value:    1,
+				Quota:    0.5,
+				Source:   maxprocs.SourceQuota,
+				Reason:   "rounded cpu quota",
+			},
+		},
+	}
+	t.Cleanup(reset) // avoid side effects in other tests.
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+			t.Setenv("GOMAXPROCS", tc.env)
+
+			result, err := maxprocs.Apply(context.Background(),
+				maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				maxprocs.WithCPUQuotaDetector(
+					maxprocs.CPUQuotaDetectorFunc(
+						func(context.Context) (float64, error) {
+							return tc.quota, nil
+						},
+					),
+				),
+			)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+
+			if result != tc.expect {
+				t.Errorf("expected=%+v, got=%+v", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestApplyFailures(t *testing.T) {
+	quota := maxprocs.CPUQuotaDetectorFunc(func(context.Context) (float64, error) {
+		return 2, nil
+	})
+	tt := []struct {
+		name    string
+		timeout time.Duration
+		opts    []maxprocs.Option
+		err     error
+	}{
+		{
+			name: "DetectorPanic",
+			opts: []maxprocs.Option{
+				maxprocs.WithCPUQuotaDetector(maxprocs.CPUQuotaDetectorFunc(
+					func(context.Context) (float64, error) {
+						panic("test: detector panic")
+					},
+				)),
+			},
+		},
+		{
+			name:    "DetectorHung",
+			timeout: 50 * time.Millisecond,
+			err:     context.DeadlineExceeded,
+			opts: []maxprocs.Option{
+				maxprocs.WithCPUQuotaDetector(maxprocs.CPUQuotaDetectorFunc(
+					func(context.Context) (float64, error) {
+						// Detector which ignores context.
+						time.Sleep(time.Minute)
+						return 2, nil
+					},
+				)),
+			},
+		},
+		{
+			name: "RoundFuncPanic",
+			opts: []maxprocs.Option{
+				maxprocs.WithCPUQuotaDetector(quota),
+				maxprocs.WithRoundFunc(func(float64) int {
+					panic("test: round func panic")
+				}),
+			},
+		},
+		{
+			name: "CPUPolicyPanic",
+			opts: []maxprocs.Option{
+				maxprocs.WithCPUQuotaDetector(quota),
+				maxprocs.WithCPUPolicy(maxprocs.CPUPolicyFunc(func(maxprocs.CPUInfo) (int, string) {
+					panic("test: cpu policy panic")
+				})),
+			},
+		},
+	}
+	t.Cleanup(reset)
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+			t.Setenv("GOMAXPROCS", "")
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			opts := append([]maxprocs.Option{
+				maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			}, tc.opts...)
+			result, err := maxprocs.Apply(ctx, opts...)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Errorf("expected error wrapping %q, got %q", tc.err, err)
+			}
+
+			if result.Value != runtime.NumCPU() || maxprocs.Current() != runtime.NumCPU() {
+				t.Errorf("GOMAXPROCS must be unchanged on error")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package maxprocs_test
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+)
+
+func TestApplyWithSystemdNotify(t *testing.T) {
+	t.Cleanup(reset)
+	t.Setenv("GOMAXPROCS", "")
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	_, err = maxprocs.Apply(context.Background(),
+		maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+		maxprocs.WithSystemdNotify(true),
+		maxprocs.WithCPUQuotaDetector(
+			maxprocs.CPUQuotaDetectorFunc(
+				func(_ context.Context) (float64, error) {
+					return 1.5, nil
+				},
+			),
+		),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+
+	got := string(buf[:n])
+	expect := "GOMAXPROCS=2 (quota)"
+	if !strings.HasPrefix(got, "STATUS=") || !strings.Contains(got, expect) {
+		t.Errorf("expected status containing %q, got %q", expect, got)
+	}
+}
@@ -78,6 +78,54 @@ func WithRoundFunc(fn func(float64) int) Option {
 	return nil
 }
 
+// WithCPUPolicy configures the [CPUPolicy] used to compute GOMAXPROCS from
+// CPU limits. This takes precedence over [WithRoundFunc]. By default, CPU quota is
+// rounded with rounding function. This has no effect if GOMAXPROCS environment
+// variable is set.
+func WithCPUPolicy(policy CPUPolicy) Option {
+	if policy != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.policy = policy
+			},
+		}
+	}
+	return nil
+}
+
+// WithPreserveExternal leaves GOMAXPROCS unchanged if it was modified by
+// another package like [go.uber.org/automaxprocs] or by calling [runtime.GOMAXPROCS]
+// directly. See [IsModified] for more info. By default, a warning is logged
+// and GOMAXPROCS is overridden.
+//
+// [go.uber.org/automaxprocs]: https://pkg.go.dev/go.uber.org/automaxprocs
+func WithPreserveExternal(preserve bool) Option {
+	if preserve {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.preserve = true
+			},
+		}
+	}
+	return nil
+}
+
+// WithSystemdNotify reports GOMAXPROCS and its source to the service manager
+// via [sd_notify] protocol, which is shown by "systemctl status". This does
+// nothing if NOTIFY_SOCKET environment variable is not set.
+//
+// [sd_notify]: https://www.freedesktop.org/software/systemd/man/latest/sd_notify.html
+func WithSystemdNotify(notify bool) Option {
+	if notify {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.notify = true
+			},
+		}
+	}
+	return nil
+}
+
 // WithCPUQuotaDetector can be used to replace default CPU quota detection algorithm.
 //
 // This is an advanced option intended to be used to support custom configurations.
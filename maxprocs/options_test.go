@@ -51,6 +51,23 @@ func TestWithRoundFunc(t *testing.T) {
 	})
 }
 
+func TestWithCPUPolicy(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		opt := WithCPUPolicy(nil)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("NotNil", func(t *testing.T) {
+		cfg := config{}
+		opt := WithCPUPolicy(CPUSetPolicy())
+		opt.apply(&cfg)
+		if cfg.policy == nil {
+			t.Errorf("expected non nil policy")
+		}
+	})
+}
+
 func TestWithLogger(t *testing.T) {
 	t.Run("Nil", func(t *testing.T) {
 		opt := WithLogger(nil)
@@ -67,3 +84,89 @@ func TestWithLogger(t *testing.T) {
 		}
 	})
 }
+
+func TestWithPreserveExternal(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithPreserveExternal(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithPreserveExternal(true)
+		opt.apply(&cfg)
+		if !cfg.preserve {
+			t.Errorf("expected preserve to be true")
+		}
+	})
+}
+
+func TestWithSystemdNotify(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithSystemdNotify(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithSystemdNotify(true)
+		opt.apply(&cfg)
+		if !cfg.notify {
+			t.Errorf("expected notify to be true")
+		}
+	})
+}
+
+func TestWithResizeHandler(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		opt := WithResizeHandler(nil)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithResizeHandler(func(context.Context, Capacity) {})
+		opt.apply(&cfg)
+		if cfg.resizeHandler == nil {
+			t.Errorf("expected non nil value for cfg.resizeHandler")
+		}
+	})
+}
+
+func TestWithShare(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, v := range []float64{0, -0.5, 1, 1.5, math.NaN()} {
+			if opt := WithShare(v); opt != nil {
+				t.Errorf("expected nil for share=%f", v)
+			}
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithShare(0.5)
+		opt.apply(&cfg)
+		if cfg.share != 0.5 {
+			t.Errorf("expected share to be 0.5, got %f", cfg.share)
+		}
+	})
+}
+
+func TestWithProcessShare(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithProcessShare(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithProcessShare(true)
+		opt.apply(&cfg)
+		if !cfg.processShare {
+			t.Errorf("expected processShare to be true")
+		}
+	})
+}
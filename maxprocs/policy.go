@@ -0,0 +1,293 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package maxprocs
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/quota"
+	"github.com/tprasadtp/go-autotune/internal/shared"
+)
+
+var _ CPUPolicy = (*CPUPolicyFunc)(nil)
+
+// CPUInfo describes CPU limits of the workload, which is used by [CPUPolicy]
+// to compute GOMAXPROCS. Zero values indicate not defined or not supported
+// by the platform.
+type CPUInfo struct {
+	// CPU quota, in number of CPUs, like cpu.max on Linux.
+	// For example, 1.5 indicates 150ms of CPU time every 100ms.
+	Quota float64 `json:"quota,omitempty"`
+
+	// Period over which CPU quota is enforced, like period of cpu.max on Linux.
+	Period time.Duration `json:"period,omitempty"`
+
+	// Burst allowed over CPU quota, like cpu.max.burst on Linux.
+	Burst time.Duration `json:"burst,omitempty"`
+
+	// Number of CPUs in effective cpuset, like cpuset.cpus.effective on Linux.
+	CPUSet int `json:"cpuset,omitempty"`
+
+	// Relative CPU weight, like cpu.weight on Linux, in range [1, 10000].
+	Weight int `json:"weight,omitempty"`
+
+	// Number of processes sharing CPU limits, like number of processes in
+	// cgroup.procs on Linux. Detectors need not set it, if they implement
+	// DetectProcs method (see [WithProcessShare]). Zero if unknown.
+	Procs int `json:"procs,omitempty"`
+
+	// Number of logical CPUs usable by the process. This is always populated,
+	// detectors need not set it. If not set, [runtime.NumCPU] is used.
+	NumCPU int `json:"num_cpu"`
+
+	// Source of the CPU info, for example cgroup or jobobject.
+	// Info obtained from a [CPUQuotaDetector] which does not implement
+	// [CPUInfoDetector] has source detector.
+	Source string `json:"source,omitempty"`
+}
+
+// CPUInfoDetector is a [CPUQuotaDetector] which can also detect [CPUInfo].
+// If detector specified via [WithCPUQuotaDetector] implements this interface,
+// it is used to obtain CPU info for the [CPUPolicy]. Use [NewCPUInfoDetector]
+// to adapt an existing [CPUQuotaDetector].
+type CPUInfoDetector interface {
+	CPUQuotaDetector
+	DetectCPUInfo(ctx context.Context) (CPUInfo, error)
+}
+
+// NewCPUInfoDetector returns a [CPUInfoDetector] for detector. If detector
+// already implements [CPUInfoDetector], it is returned as is. Otherwise,
+// only [CPUInfo.Quota] and [CPUInfo.NumCPU] are populated. Returns nil if
+// detector is nil.
+func NewCPUInfoDetector(detector CPUQuotaDetector) CPUInfoDetector {
+	if detector == nil {
+		return nil
+	}
+
+	if d, ok := detector.(CPUInfoDetector); ok {
+		return d
+	}
+	return &cpuInfoAdapter{detector: detector}
+}
+
+// cpuInfoAdapter adapts a [CPUQuotaDetector] to [CPUInfoDetector].
+type cpuInfoAdapter struct {
+	detector CPUQuotaDetector
+}
+
+// DetectCPUQuota implements [CPUQuotaDetector].
+func (a *cpuInfoAdapter) DetectCPUQuota(ctx context.Context) (float64, error) {
+	return a.detector.DetectCPUQuota(ctx)
+}
+
+// DetectCPUInfo implements [CPUInfoDetector].
+func (a *cpuInfoAdapter) DetectCPUInfo(ctx context.Context) (CPUInfo, error) {
+	return detectCPUInfo(ctx, a.detector)
+}
+
+// CPUPolicy computes GOMAXPROCS from CPU info. It returns GOMAXPROCS and a
+// short human readable reason describing how it was computed, which is
+// logged and included in [Result].
+//
+// If returned value is zero and CPU quota is defined, GOMAXPROCS is set to 1.
+// If returned value is zero and CPU quota is not defined, GOMAXPROCS is left
+// unchanged. Negative values are treated as errors.
+type CPUPolicy interface {
+	MaxProcs(info CPUInfo) (procs int, reason string)
+}
+
+// CPUPolicyFunc is an adapter to allow the use of ordinary functions as
+// [CPUPolicy]. If f is a function with the appropriate signature,
+// CPUPolicyFunc(f) is a [CPUPolicy] that calls f.
+type CPUPolicyFunc func(info CPUInfo) (procs int, reason string)
+
+// MaxProcs implements [CPUPolicy] interface.
+//
+//nolint:nonamedreturns // for docs.
+func (fn CPUPolicyFunc) MaxProcs(info CPUInfo) (procs int, reason string) {
+	return fn(info)
+}
+
+// DefaultCPUPolicy returns default [CPUPolicy], which rounds CPU quota
+// with [math.Ceil]. This is same as [RoundPolicy] with [math.Ceil].
+func DefaultCPUPolicy() CPUPolicy {
+	return RoundPolicy(func(f float64) int {
+		return int(math.Ceil(f))
+	})
+}
+
+// RoundPolicy returns a [CPUPolicy] which rounds CPU quota with fn.
+// This is used when [WithRoundFunc] is specified. Returns nil if fn is nil.
+func RoundPolicy(fn func(float64) int) CPUPolicy {
+	if fn == nil {
+		return nil
+	}
+
+	return CPUPolicyFunc(func(info CPUInfo) (int, string) {
+		if info.Quota <= 0 {
+			return 0, "cpu quota is not defined"
+		}
+		return fn(info.Quota), "rounded cpu quota"
+	})
+}
+
+// CPUSetPolicy returns a [CPUPolicy] which uses the lower of CPU quota rounded
+// with [math.Ceil] and number of CPUs in effective cpuset. This avoids running more
+// threads than CPUs the process can be scheduled on, when cpuset is not reflected
+// by [runtime.NumCPU], for example when inspecting another process.
+func CPUSetPolicy() CPUPolicy {
+	return CPUPolicyFunc(func(info CPUInfo) (int, string) {
+		procs := int(math.Ceil(info.Quota))
+		switch {
+		case info.Quota > 0 && info.CPUSet > 0 && info.CPUSet < procs:
+			return info.CPUSet, "cpuset is lower than cpu quota"
+		case info.Quota > 0:
+			return procs, "rounded cpu quota"
+		}
+		return 0, "cpu quota is not defined"
+	})
+}
+
+// ShortPeriodPolicy returns a [CPUPolicy] which accounts for short CPU quota periods.
+// With short periods, quota is exhausted quickly when more threads than quota are
+// runnable, leading to frequent throttling. Thus, if period is shorter than threshold,
+// quota is rounded with [math.Floor] (with minimum of 1), otherwise with [math.Ceil].
+// If period is not known, it is assumed to be 100ms, which is the default on Linux.
+func ShortPeriodPolicy(threshold time.Duration) CPUPolicy {
+	return CPUPolicyFunc(func(info CPUInfo) (int, string) {
+		if info.Quota <= 0 {
+			return 0, "cpu quota is not defined"
+		}
+
+		period := info.Period
+		if period <= 0 {
+			period = 100 * time.Millisecond
+		}
+
+		if period < threshold {
+			return max(int(math.Floor(info.Quota)), 1), "cpu quota rounded down due to short period"
+		}
+		return int(math.Ceil(info.Quota)), "rounded cpu quota"
+	})
+}
+
+// CPURequestPolicy returns a [CPUPolicy] which sizes GOMAXPROCS from CPU requests
+// of Kubernetes pods, when CPU quota is not defined. This is useful for pods which set
+// CPU requests but not limits. If CPU quota is defined, it is rounded with [math.Ceil].
+//
+// kubelet translates CPU requests to CPU weight (see [WeightToMillicores]). CPU request
+// recovered from CPU weight is multiplied by multiplier, rounded with [math.Ceil] and
+// bounded by the smaller of limit and [runtime.NumCPU]. If multiplier is not positive, 1 is used.
+// If limit is not positive, only [runtime.NumCPU] is used as upper bound.
+//
+// Default CPU weight (100) is ignored, as it is same as CPU weight of workloads
+// without CPU requests. CPU weight of 1, used by best effort pods, is also ignored.
+func CPURequestPolicy(multiplier float64, limit int) CPUPolicy {
+	if math.IsNaN(multiplier) || multiplier <= 0 {
+		multiplier = 1
+	}
+
+	return CPUPolicyFunc(func(info CPUInfo) (int, string) {
+		if info.Quota > 0 {
+			return int(math.Ceil(info.Quota)), "rounded cpu quota"
+		}
+
+		if info.Weight == 100 {
+			return 0, "cpu quota is not defined and cpu weight is default"
+		}
+
+		millicores := WeightToMillicores(info.Weight)
+		if millicores <= 0 {
+			return 0, "cpu quota and cpu request are not defined"
+		}
+
+		procs := max(int(math.Ceil(float64(millicores)/1000*multiplier)), 1)
+		if limit > 0 && procs > limit && (info.NumCPU <= 0 || limit <= info.NumCPU) {
+			return limit, "cpu request from cpu weight is bounded by limit"
+		}
+
+		if info.NumCPU > 0 && procs > info.NumCPU {
+			return info.NumCPU, "cpu request from cpu weight is bounded by number of cpus"
+		}
+		return procs, "cpu request from cpu weight"
+	})
+}
+
+// WeightToMillicores returns CPU request in millicores which kubelet translates to
+// given cgroup v2 CPU weight. kubelet converts CPU requests to CPU shares
+// (shares = millicores * 1024 / 1000, minimum 2) and CPU shares to CPU weight
+// (weight = 1 + (shares - 2) * 9999 / 262142). As conversion is lossy, multiple
+// CPU requests map to the same CPU weight, and the smallest of them is returned.
+// Thus, returned value is up to 26 millicores lower than actual CPU request.
+// Zero is returned for CPU weight less than or equal to 1, which is used by
+// best effort pods.
+func WeightToMillicores(weight int) int64 {
+	if weight <= 1 {
+		return 0
+	}
+
+	weight = min(weight, 10000)
+
+	// Smallest shares value which maps to weight.
+	shares := 2 + (int64(weight-1)*262142+9999-1)/9999
+
+	// Smallest millicores value which maps to shares.
+	return (shares*1000 + 1024 - 1) / 1024
+}
+
+// maxProcs calls policy, recovering from panics. Rounding function
+// specified via [WithRoundFunc] is also called by the policy.
+//
+//nolint:nonamedreturns // required to recover from panics.
+func maxProcs(policy CPUPolicy, info CPUInfo) (procs int, reason string, err error) {
+	defer shared.Recover(&err)
+	procs, reason = policy.MaxProcs(info)
+	return procs, reason, nil
+}
+
+// quotaInfoDetector is implemented by detectors in package quota.
+type quotaInfoDetector interface {
+	DetectCPUInfo(ctx context.Context) (quota.CPUInfo, error)
+}
+
+// detectCPUInfo detects CPU info using detector. If detector does not
+// implement [CPUInfoDetector], only CPU quota is populated.
+func detectCPUInfo(ctx context.Context, detector CPUQuotaDetector) (CPUInfo, error) {
+	var info CPUInfo
+	switch d := detector.(type) {
+	case CPUInfoDetector:
+		v, err := d.DetectCPUInfo(ctx)
+		if err != nil {
+			return CPUInfo{}, err
+		}
+		info = v
+	case quotaInfoDetector:
+		v, err := d.DetectCPUInfo(ctx)
+		if err != nil {
+			return CPUInfo{}, err
+		}
+		info = CPUInfo{
+			Quota:  v.Quota,
+			Period: v.Period,
+			Burst:  v.Burst,
+			CPUSet: v.CPUSet,
+			Weight: v.Weight,
+			Source: v.Source,
+		}
+	default:
+		v, err := detector.DetectCPUQuota(ctx)
+		if err != nil {
+			return CPUInfo{}, err
+		}
+		info = CPUInfo{Quota: v, Source: "detector"}
+	}
+
+	if info.NumCPU <= 0 {
+		info.NumCPU = runtime.NumCPU()
+	}
+	return info, nil
+}
@@ -0,0 +1,285 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package maxprocs_test
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+)
+
+type infoDetector maxprocs.CPUInfo
+
+func (d infoDetector) DetectCPUQuota(_ context.Context) (float64, error) {
+	return d.Quota, nil
+}
+
+func (d infoDetector) DetectCPUInfo(_ context.Context) (maxprocs.CPUInfo, error) {
+	return maxprocs.CPUInfo(d), nil
+}
+
+func TestCPUPolicy(t *testing.T) {
+	floor := func(f float64) int { return int(math.Floor(f)) }
+	tt := []struct {
+		name   string
+		policy maxprocs.CPUPolicy
+		info   maxprocs.CPUInfo
+		expect int
+	}{
+		{name: "Default/None", policy: maxprocs.DefaultCPUPolicy()},
+		{name: "Default/Quota", policy: maxprocs.DefaultCPUPolicy(), info: maxprocs.CPUInfo{Quota: 1.5}, expect: 2},
+		{name: "Round/None", policy: maxprocs.RoundPolicy(floor)},
+		{name: "Round/Quota", policy: maxprocs.RoundPolicy(floor), info: maxprocs.CPUInfo{Quota: 2.5}, expect: 2},
+		{name: "CPUSet/None", policy: maxprocs.CPUSetPolicy(), info: maxprocs.CPUInfo{CPUSet: 2}},
+		{
+			name:   "CPUSet/Lower",
+			policy: maxprocs.CPUSetPolicy(),
+			info:   maxprocs.CPUInfo{Quota: 3.5, CPUSet: 2},
+			expect: 2,
+		},
+		{
+			name:   "CPUSet/Higher",
+			policy: maxprocs.CPUSetPolicy(),
+			info:   maxprocs.CPUInfo{Quota: 1.5, CPUSet: 4},
+			expect: 2,
+		},
+		{name: "CPUSet/Unknown", policy: maxprocs.CPUSetPolicy(), info: maxprocs.CPUInfo{Quota: 1.5}, expect: 2},
+		{name: "ShortPeriod/None", policy: maxprocs.ShortPeriodPolicy(50 * time.Millisecond)},
+		{
+			name:   "ShortPeriod/Short",
+			policy: maxprocs.ShortPeriodPolicy(50 * time.Millisecond),
+			info:   maxprocs.CPUInfo{Quota: 2.5, Period: 10 * time.Millisecond},
+			expect: 2,
+		},
+		{
+			name:   "ShortPeriod/ShortLessThanOne",
+			policy: maxprocs.ShortPeriodPolicy(50 * time.Millisecond),
+			info:   maxprocs.CPUInfo{Quota: 0.5, Period: 10 * time.Millisecond},
+			expect: 1,
+		},
+		{
+			name:   "ShortPeriod/Long",
+			policy: maxprocs.ShortPeriodPolicy(50 * time.Millisecond),
+			info:   maxprocs.CPUInfo{Quota: 2.5, Period: 100 * time.Millisecond},
+			expect: 3,
+		},
+		{
+			name:   "ShortPeriod/Unknown",
+			policy: maxprocs.ShortPeriodPolicy(50 * time.Millisecond),
+			info:   maxprocs.CPUInfo{Quota: 2.5},
+			expect: 3,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			procs, reason := tc.policy.MaxProcs(tc.info)
+			if procs != tc.expect {
+				t.Errorf("expected=%d, got=%d", tc.expect, procs)
+			}
+
+			if reason == "" {
+				t.Errorf("expected non empty reason")
+			}
+		})
+	}
+
+	t.Run("RoundPolicyNil", func(t *testing.T) {
+		if maxprocs.RoundPolicy(nil) != nil {
+			t.Errorf("expected nil policy")
+		}
+	})
+}
+
+func TestNewCPUInfoDetector(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		if maxprocs.NewCPUInfoDetector(nil) != nil {
+			t.Errorf("expected nil detector")
+		}
+	})
+
+	t.Run("InfoDetector", func(t *testing.T) {
+		d := infoDetector{Quota: 1.5, CPUSet: 2, Source: "test"}
+		if v, ok := maxprocs.NewCPUInfoDetector(d).(infoDetector); !ok || v != d {
+			t.Errorf("expected detector to be returned as is")
+		}
+	})
+
+	t.Run("QuotaDetector", func(t *testing.T) {
+		d := maxprocs.NewCPUInfoDetector(maxprocs.CPUQuotaDetectorFunc(
+			func(context.Context) (float64, error) {
+				return 1.5, nil
+			}))
+		info, err := d.DetectCPUInfo(context.Background())
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		expect := maxprocs.CPUInfo{Quota: 1.5, NumCPU: runtime.NumCPU(), Source: "detector"}
+		if info != expect {
+			t.Errorf("expected=%+v, got=%+v", expect, info)
+		}
+
+		quota, err := d.DetectCPUQuota(context.Background())
+		if err != nil || quota != 1.5 {
+			t.Errorf("expected quota 1.5, got %f(%v)", quota, err)
+		}
+	})
+}
+
+func TestComputeWithCPUPolicy(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "")
+	var got maxprocs.CPUInfo
+	result, err := maxprocs.Compute(context.Background(),
+		maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+		maxprocs.WithCPUQuotaDetector(infoDetector{
+			Quota:  1.5,
+			Period: 100 * time.Millisecond,
+			CPUSet: 1,
+			Weight: 100,
+			Source: "test",
+		}),
+		maxprocs.WithCPUPolicy(maxprocs.CPUPolicyFunc(func(info maxprocs.CPUInfo) (int, string) {
+			got = info
+			return info.CPUSet, "cpuset"
+		})),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	expect := maxprocs.CPUInfo{
+		Quota:  1.5,
+		Period: 100 * time.Millisecond,
+		CPUSet: 1,
+		Weight: 100,
+		NumCPU: runtime.NumCPU(),
+		Source: "test",
+	}
+	if got != expect {
+		t.Errorf("info expected=%+v, got=%+v", expect, got)
+	}
+
+	if result.Value != 1 || result.Reason != "cpuset" || result.Source != maxprocs.SourceQuota {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestWeightToMillicores(t *testing.T) {
+	// kubelet conversion of CPU request to cgroup v2 CPU weight.
+	weight := func(millicores int64) int {
+		shares := max(millicores*1024/1000, 2)
+		return int(1 + ((shares-2)*9999)/262142)
+	}
+
+	for millicores := int64(0); millicores <= 256000; millicores++ {
+		w := weight(millicores)
+		v := maxprocs.WeightToMillicores(w)
+		if w <= 1 {
+			if v != 0 {
+				t.Fatalf("weight=%d(%dm) expected=0, got=%d", w, millicores, v)
+			}
+			continue
+		}
+
+		if weight(v) != w || v > millicores || millicores-v > 26 {
+			t.Fatalf("weight=%d(%dm) got=%d", w, millicores, v)
+		}
+	}
+
+	if v := maxprocs.WeightToMillicores(20000); v != maxprocs.WeightToMillicores(10000) {
+		t.Errorf("expected weight to be capped at 10000, got %d", v)
+	}
+}
+
+func TestCPURequestPolicy(t *testing.T) {
+	tt := []struct {
+		name       string
+		multiplier float64
+		limit      int
+		info       maxprocs.CPUInfo
+		expect     int
+	}{
+		{
+			name:   "Quota",
+			info:   maxprocs.CPUInfo{Quota: 1.5, Weight: 59, NumCPU: 8},
+			expect: 2,
+		},
+		{
+			name: "DefaultWeight",
+			info: maxprocs.CPUInfo{Weight: 100, NumCPU: 8},
+		},
+		{
+			name: "BestEffort",
+			info: maxprocs.CPUInfo{Weight: 1, NumCPU: 8},
+		},
+		{
+			name: "NoWeight",
+			info: maxprocs.CPUInfo{NumCPU: 8},
+		},
+		{
+			name:   "500m",
+			info:   maxprocs.CPUInfo{Weight: 20, NumCPU: 8},
+			expect: 1,
+		},
+		{
+			name:   "1500m",
+			info:   maxprocs.CPUInfo{Weight: 59, NumCPU: 8},
+			expect: 2,
+		},
+		{
+			name:   "2000m",
+			info:   maxprocs.CPUInfo{Weight: 79, NumCPU: 8},
+			expect: 2,
+		},
+		{
+			name:       "1500m/Multiplier",
+			multiplier: 2,
+			info:       maxprocs.CPUInfo{Weight: 59, NumCPU: 8},
+			expect:     3,
+		},
+		{
+			name:   "4000m/Limit",
+			limit:  2,
+			info:   maxprocs.CPUInfo{Weight: 157, NumCPU: 8},
+			expect: 2,
+		},
+		{
+			name:   "4000m/NumCPU",
+			info:   maxprocs.CPUInfo{Weight: 157, NumCPU: 3},
+			expect: 3,
+		},
+		{
+			name:       "4000m/LimitAboveNumCPU",
+			multiplier: 3,
+			limit:      8,
+			info:       maxprocs.CPUInfo{Weight: 157, NumCPU: 4},
+			expect:     4,
+		},
+		{
+			name:       "4000m/LimitBelowNumCPU",
+			multiplier: 3,
+			limit:      6,
+			info:       maxprocs.CPUInfo{Weight: 157, NumCPU: 8},
+			expect:     6,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			procs, reason := maxprocs.CPURequestPolicy(tc.multiplier, tc.limit).MaxProcs(tc.info)
+			if procs != tc.expect {
+				t.Errorf("expected=%d, got=%d", tc.expect, procs)
+			}
+
+			if reason == "" {
+				t.Errorf("expected non empty reason")
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package maxprocs
+
+import (
+	"context"
+	"log/slog"
+	"math"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+)
+
+// WithShare configures share of CPU limits available to this process, when CPU
+// limits are shared by multiple processes, for example with sidecar binaries in
+// a single container, pre-fork servers or a supervisor and its workers. CPU quota
+// is multiplied by share before computing GOMAXPROCS. If CPU quota is not defined,
+// number of usable CPUs is used instead. For example, with two processes sharing
+// a quota of 4 CPUs, WithShare(0.5) sets GOMAXPROCS to 2.
+//
+// This takes precedence over [WithProcessShare]. If share is not in range (0, 1),
+// this returns nil, which is a no-op.
+func WithShare(share float64) Option {
+	if share > 0 && share < 1 {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.share = share
+			},
+		}
+	}
+	return nil
+}
+
+// WithProcessShare divides CPU limits equally among all processes sharing them,
+// like processes in cgroup.procs on Linux or active processes in the job object
+// on Windows, as reported by [CPUInfo.Procs] or by DetectProcs(context.Context) (int, error)
+// method of the detector, if implemented. Number of processes is only detected
+// when this is enabled, and limits are not divided if it cannot be detected.
+// This is only suitable when all processes are similar, for example multiple
+// instances of the same binary.
+// See [WithShare] for more info. If enable is false, this returns nil, which
+// is a no-op.
+func WithProcessShare(enable bool) Option {
+	if enable {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.processShare = true
+			},
+		}
+	}
+	return nil
+}
+
+// shareCPUInfo returns CPU info with CPU quota scaled by share of the process
+// (see [WithShare] and [WithProcessShare]) and the share. If share is 1, info is
+// returned as is. If CPU quota is not defined, lower of number of CPUs in
+// effective cpuset and number of usable CPUs is scaled instead.
+//
+// Number of processes is only detected when required by [WithProcessShare]
+// and not reported by the detector. Failures are treated as unknown.
+func shareCPUInfo(ctx context.Context, cfg *config, info CPUInfo) (CPUInfo, float64) {
+	if cfg.share == 0 && cfg.processShare && info.Procs == 0 {
+		procs, err := shared.Procs(ctx, cfg.detector)
+		if err == nil {
+			info.Procs = procs
+		} else {
+			cfg.logger.LogAttrs(ctx, slog.LevelDebug, "Failed to obtain number of processes",
+				slog.Any("err", err),
+			)
+		}
+	}
+
+	share := shared.Share(cfg.share, cfg.processShare, info.Procs)
+	if share >= 1 {
+		return info, 1
+	}
+
+	base := info.Quota
+	if base <= 0 {
+		base = float64(info.NumCPU)
+		if info.CPUSet > 0 {
+			base = math.Min(base, float64(info.CPUSet))
+		}
+	}
+
+	if base > 0 {
+		info.Quota = base * share
+	}
+	return info, share
+}
@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package maxprocs_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+)
+
+// procsDetector is a CPU quota detector which reports number of processes
+// only via DetectProcs, and counts calls to it.
+type procsDetector struct {
+	quota float64
+	procs int
+	err   error
+	calls *atomic.Int32
+}
+
+func (d procsDetector) DetectCPUQuota(_ context.Context) (float64, error) {
+	return d.quota, nil
+}
+
+func (d procsDetector) DetectProcs(_ context.Context) (int, error) {
+	d.calls.Add(1)
+	return d.procs, d.err
+}
+
+func TestComputeWithShare(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "")
+	tt := []struct {
+		name   string
+		info   infoDetector
+		opts   []maxprocs.Option
+		value  int
+		share  float64
+		source maxprocs.Source
+	}{
+		{
+			name:   "NoShare",
+			info:   infoDetector{Quota: 4, Procs: 4},
+			value:  4,
+			source: maxprocs.SourceQuota,
+		},
+		{
+			name:   "Explicit",
+			info:   infoDetector{Quota: 4, Procs: 4},
+			opts:   []maxprocs.Option{maxprocs.WithShare(0.5)},
+			value:  2,
+			share:  0.5,
+			source: maxprocs.SourceQuota,
+		},
+		{
+			name:   "ExplicitOverridesProcessShare",
+			info:   infoDetector{Quota: 4, Procs: 4},
+			opts:   []maxprocs.Option{maxprocs.WithShare(0.5), maxprocs.WithProcessShare(true)},
+			value:  2,
+			share:  0.5,
+			source: maxprocs.SourceQuota,
+		},
+		{
+			name:   "ProcessShare",
+			info:   infoDetector{Quota: 4, Procs: 4},
+			opts:   []maxprocs.Option{maxprocs.WithProcessShare(true)},
+			value:  1,
+			share:  0.25,
+			source: maxprocs.SourceQuota,
+		},
+		{
+			name:   "ProcessShareFractional",
+			info:   infoDetector{Quota: 2.5, Procs: 2},
+			opts:   []maxprocs.Option{maxprocs.WithProcessShare(true)},
+			value:  2,
+			share:  0.5,
+			source: maxprocs.SourceQuota,
+		},
+		{
+			name:   "ProcessShareMinimum",
+			info:   infoDetector{Quota: 1, Procs: 8},
+			opts:   []maxprocs.Option{maxprocs.WithProcessShare(true)},
+			value:  1,
+			share:  0.125,
+			source: maxprocs.SourceQuota,
+		},
+		{
+			name:   "ProcessShareSingleProcess",
+			info:   infoDetector{Quota: 4, Procs: 1},
+			opts:   []maxprocs.Option{maxprocs.WithProcessShare(true)},
+			value:  4,
+			source: maxprocs.SourceQuota,
+		},
+		{
+			name:   "ProcessShareCPUSet",
+			info:   infoDetector{CPUSet: 1, Procs: 2},
+			opts:   []maxprocs.Option{maxprocs.WithProcessShare(true)},
+			value:  1,
+			share:  0.5,
+			source: maxprocs.SourceQuota,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]maxprocs.Option{
+				maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				maxprocs.WithCPUQuotaDetector(tc.info),
+			}, tc.opts...)
+			result, err := maxprocs.Compute(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if result.Value != tc.value || result.Share != tc.share || result.Source != tc.source {
+				t.Errorf("expected value=%d, share=%f, source=%s, got=%+v",
+					tc.value, tc.share, tc.source, result)
+			}
+		})
+	}
+
+	t.Run("EffectiveCPU", func(t *testing.T) {
+		v, err := maxprocs.EffectiveCPU(context.Background(),
+			maxprocs.WithCPUQuotaDetector(infoDetector{Quota: 3, Procs: 2}),
+			maxprocs.WithProcessShare(true),
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+
+		expect := min(1.5, float64(runtime.NumCPU()))
+		if v.CPU != expect || v.Quota != 1.5 {
+			t.Errorf("expected cpu=%f, quota=1.5, got=%+v", expect, v)
+		}
+	})
+	t.Run("DetectProcs", func(t *testing.T) {
+		tt := []struct {
+			name  string
+			err   error
+			opts  []maxprocs.Option
+			value int
+			share float64
+			calls int32
+		}{
+			{
+				name:  "NoShare",
+				value: 4,
+			},
+			{
+				name:  "Explicit",
+				opts:  []maxprocs.Option{maxprocs.WithShare(0.5), maxprocs.WithProcessShare(true)},
+				value: 2,
+				share: 0.5,
+			},
+			{
+				name:  "ProcessShare",
+				opts:  []maxprocs.Option{maxprocs.WithProcessShare(true)},
+				value: 1,
+				share: 0.25,
+				calls: 1,
+			},
+			{
+				name:  "ProcessShareError",
+				err:   errors.New("failed to read cgroup.procs"),
+				opts:  []maxprocs.Option{maxprocs.WithProcessShare(true)},
+				value: 4,
+				calls: 1,
+			},
+		}
+		for _, tc := range tt {
+			t.Run(tc.name, func(t *testing.T) {
+				d := procsDetector{quota: 4, procs: 4, err: tc.err, calls: &atomic.Int32{}}
+				opts := append([]maxprocs.Option{
+					maxprocs.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+					maxprocs.WithCPUQuotaDetector(d),
+				}, tc.opts...)
+				result, err := maxprocs.Compute(context.Background(), opts...)
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+
+				if result.Value != tc.value || result.Share != tc.share {
+					t.Errorf("expected value=%d, share=%f, got=%+v", tc.value, tc.share, result)
+				}
+
+				if v := d.calls.Load(); v != tc.calls {
+					t.Errorf("expected DetectProcs calls=%d, got=%d", tc.calls, v)
+				}
+			})
+		}
+	})
+}
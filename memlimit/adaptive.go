@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithAdaptiveReserve enables adaptive reserve. GOMEMLIMIT only governs memory
+// managed by the Go runtime. Workloads using cgo libraries (like sqlite or rocksdb)
+// or large anonymous memory maps may be OOM killed even with the reserve computed by
+// reserve func. With adaptive reserve, memory used by the workload which is not
+// managed by the Go runtime is estimated and added to reserve computed by reserve
+// func. Reserve is then clamped to range [minimum, maximum].
+//
+// Memory not managed by the Go runtime is estimated by comparing memory usage of the
+// workload with memory used by the Go runtime, as reported by [runtime/metrics]. On
+// Linux, memory usage is sum of anon, kernel and sock in memory.stat. Page cache
+// (file in memory.stat) is excluded as it can be reclaimed by the kernel. Because
+// memory usage includes all processes in the cgroup, memory used by other processes
+// is also included in the estimate. On Windows, private commit charge of the process
+// is used. If memory usage is not supported by the platform or detector, nothing
+// is added to reserve. Reserve is only computed if hard memory limit is defined.
+//
+// As memory usage changes over time, adaptive reserve is typically used with [Watch],
+// which periodically re-computes GOMEMLIMIT. If minimum is negative or maximum is
+// less than minimum or not positive, nil is returned, which is a no-op.
+func WithAdaptiveReserve(minimum, maximum int64) Option {
+	if minimum < 0 || maximum <= 0 || maximum < minimum {
+		return nil
+	}
+
+	return &optionFunc{
+		fn: func(c *config) {
+			c.adaptive = true
+			c.reserveMin = minimum
+			c.reserveMax = maximum
+		},
+	}
+}
+
+// Watch configures GOMEMLIMIT with [Apply] every interval, until ctx is done.
+// This is useful when memory limits or memory usage not managed by the Go runtime
+// (see [WithAdaptiveReserve]) are expected to change. Errors returned by [Apply]
+// are logged and do not stop the watch. This always returns a non-nil error,
+// which wraps ctx error when ctx is done.
+func Watch(ctx context.Context, interval time.Duration, opts ...Option) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("memlimit: invalid watch interval: %s", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// Errors are already logged by Apply.
+		_, err := Apply(ctx, opts...)
+		if err != nil && errors.Is(err, ctx.Err()) {
+			return fmt.Errorf("memlimit: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("memlimit: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// nonGoMemory estimates memory used by the workload, which is not managed by
+// the Go runtime. Zero is returned if memory usage is not available.
+func nonGoMemory(info MemoryInfo) int64 {
+	usage := memoryUsage(info)
+	if usage <= 0 {
+		return 0
+	}
+
+	// Memory released to the operating system is still mapped by the runtime,
+	// but is not counted towards memory usage.
+	_, total, released := runtimeMemory()
+	return max(usage-(total-released), 0)
+}
+
+// memoryUsage returns memory usage of the workload, excluding page cache if
+// possible. Zero is returned if memory usage is not available.
+func memoryUsage(info MemoryInfo) int64 {
+	if info.Anon > 0 {
+		// Page cache is excluded as it can be reclaimed.
+		return info.Anon + info.Kernel + info.Sock
+	}
+	return max(info.Current, 0)
+}
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestComputeWithAdaptiveReserve(t *testing.T) {
+	tt := []struct {
+		name    string
+		info    memlimit.MemoryInfo
+		min     int64
+		max     int64
+		reserve int64
+		nonGo   bool
+	}{
+		{
+			name:    "NoUsage",
+			info:    memlimit.MemoryInfo{Max: shared.GiByte},
+			max:     512 * shared.MiByte,
+			reserve: 100 * shared.MiByte,
+		},
+		{
+			name:    "NoUsageMinimum",
+			info:    memlimit.MemoryInfo{Max: shared.GiByte},
+			min:     200 * shared.MiByte,
+			max:     512 * shared.MiByte,
+			reserve: 200 * shared.MiByte,
+		},
+		{
+			name: "AnonMaximum",
+			info: memlimit.MemoryInfo{
+				Max:  shared.GiByte,
+				Anon: 768 * shared.MiByte,
+				File: 128 * shared.MiByte,
+			},
+			max:     256 * shared.MiByte,
+			reserve: 256 * shared.MiByte,
+			nonGo:   true,
+		},
+		{
+			name: "Current",
+			info: memlimit.MemoryInfo{
+				Max:     shared.GiByte,
+				Current: 768 * shared.MiByte,
+			},
+			max:     256 * shared.MiByte,
+			reserve: 256 * shared.MiByte,
+			nonGo:   true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOMEMLIMIT", "")
+			result, err := memlimit.Compute(context.Background(),
+				memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				memlimit.WithMemoryQuotaDetector(infoDetector(tc.info)),
+				memlimit.WithAdaptiveReserve(tc.min, tc.max),
+			)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if result.Reserve != tc.reserve {
+				t.Errorf("expected reserve=%d, got=%d", tc.reserve, result.Reserve)
+			}
+
+			if result.Value != tc.info.Max-tc.reserve {
+				t.Errorf("expected value=%d, got=%d", tc.info.Max-tc.reserve, result.Value)
+			}
+
+			if tc.nonGo != (result.NonGo > 0) {
+				t.Errorf("expected non-go memory=%t, got=%d", tc.nonGo, result.NonGo)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Cleanup(reset)
+	t.Setenv("GOMEMLIMIT", "")
+
+	t.Run("InvalidInterval", func(t *testing.T) {
+		err := memlimit.Watch(context.Background(), 0)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		calls := make(chan struct{}, 8)
+		detector := memlimit.MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+			select {
+			case calls <- struct{}{}:
+			default:
+			}
+			return shared.GiByte, 0, nil
+		})
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- memlimit.Watch(ctx, 10*time.Millisecond,
+				memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				memlimit.WithMemoryQuotaDetector(detector),
+			)
+		}()
+
+		for i := range 2 {
+			select {
+			case <-calls:
+			case err := <-errCh:
+				t.Fatalf("watch returned early: %v", err)
+			case <-time.After(10 * time.Second):
+				t.Fatalf("timeout waiting for detector call %d", i)
+			}
+		}
+
+		if memlimit.Current() != shared.GiByte-100*shared.MiByte {
+			t.Errorf("expected GOMEMLIMIT=%d, got=%d", shared.GiByte-100*shared.MiByte, memlimit.Current())
+		}
+
+		cancel()
+		if err := <-errCh; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}
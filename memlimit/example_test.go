@@ -5,9 +5,14 @@ package memlimit_test
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/tprasadtp/go-autotune/memlimit"
+	"github.com/tprasadtp/go-autotune/units"
 )
 
 // This example wraps default memory quota detection algorithm, but ignores
@@ -30,3 +35,85 @@ func ExampleDefaultMemoryQuotaDetector() {
 		panic(err)
 	}
 }
+
+// This example watches for systemd memory pressure events, lowering GOMEMLIMIT
+// by 20% for a minute on memory pressure events.
+func ExampleWatchPressure() {
+	ctx := context.Background()
+	go func() {
+		err := memlimit.WatchPressure(ctx,
+			memlimit.WithLogger(slog.Default()),
+			memlimit.WithPressureLimit(0.8, time.Minute),
+		)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			slog.Default().Error("Failed to watch memory pressure", "err", err)
+		}
+	}()
+}
+
+// This example guards against GC death spiral by sampling GC CPU usage every
+// 10 seconds. If garbage collector uses over half of CPU time for 3 consecutive
+// samples, GOMEMLIMIT is raised towards hard memory limit minus 32MiB, and
+// a flag is set to shed load, until garbage collector recovers.
+func ExampleWatchGC() {
+	ctx := context.Background()
+	var overloaded atomic.Bool
+	go func() {
+		err := memlimit.WatchGC(ctx, 10*time.Second,
+			memlimit.WithLogger(slog.Default()),
+			memlimit.WithGCGuard(0.5, 3),
+			memlimit.WithGCRaiseLimit(32*units.MiB),
+			memlimit.WithGCHandler(func(_ context.Context, status memlimit.GCStatus) {
+				overloaded.Store(status.Spiral)
+			}),
+		)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Default().Error("Failed to watch garbage collector", "err", err)
+		}
+	}()
+}
+
+// This example writes a heap profile and a goroutine dump to a persistent volume,
+// when memory usage reaches 90% and 95% of hard memory limit, retaining only
+// the latest 3 profiles, which can be inspected after the workload is OOM killed.
+func ExampleWatchProfiles() {
+	ctx := context.Background()
+	go func() {
+		err := memlimit.WatchProfiles(ctx, 5*time.Second, "/var/lib/app/profiles",
+			memlimit.WithLogger(slog.Default()),
+			memlimit.WithProfileThresholds(0.9, 0.95),
+			memlimit.WithProfileRetention(3),
+		)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Default().Error("Failed to watch memory usage", "err", err)
+		}
+	}()
+}
+
+// This example implements a readiness probe, which reports the workload as not ready
+// if it is expected to reach its hard memory limit within 30 seconds, based on memory
+// usage sampled every 5 seconds over the last minute.
+func ExampleForecaster() {
+	ctx := context.Background()
+	forecaster := memlimit.NewForecaster(12)
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			headroom, err := memlimit.DetectHeadroom(ctx)
+			if err != nil {
+				slog.Default().Error("Failed to detect memory headroom", "err", err)
+				continue
+			}
+			forecaster.Observe(headroom)
+		}
+	}()
+
+	http.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if forecaster.Forecast().UntilMax < 30*time.Second {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
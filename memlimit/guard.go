@@ -0,0 +1,366 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime/metrics"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+// Default GC guard settings used by [WatchGC], if [WithGCGuard] is not specified.
+const (
+	DefaultGCThreshold = 0.4
+	DefaultGCSamples   = 3
+)
+
+// GCStatus describes CPU usage of the garbage collector, sampled by [WatchGC].
+type GCStatus struct {
+	// Time at which status was sampled.
+	Time time.Time `json:"time"`
+
+	// Share of CPU time spent in garbage collection since previous sample,
+	// in range [0, 1], as estimated by the Go runtime.
+	Share float64 `json:"share"`
+
+	// Limiter indicates GC CPU limiter was enabled since previous sample.
+	// Go runtime enables the limiter when garbage collector uses too much
+	// CPU, typically because heap is persistently near GOMEMLIMIT.
+	Limiter bool `json:"limiter"`
+
+	// Spiral indicates garbage collector is in a death spiral, that is share
+	// of CPU time spent in garbage collection is above the threshold or GC CPU
+	// limiter is enabled, for consecutive samples. See [WithGCGuard].
+	Spiral bool `json:"spiral"`
+
+	// GOMEMLIMIT value, after GC guard has acted.
+	Limit int64 `json:"limit"`
+}
+
+// WithGCGuard configures when [WatchGC] considers garbage collector to be in
+// a death spiral. Death spiral is entered when share of CPU time spent in
+// garbage collection is at or above threshold or GC CPU limiter is enabled,
+// for samples consecutive samples, and ends when neither is true for samples
+// consecutive samples. If not specified, [DefaultGCThreshold] and [DefaultGCSamples]
+// are used. If threshold is not in range (0, 1) or samples is not positive,
+// nil is returned, which is a no-op.
+func WithGCGuard(threshold float64, samples int) Option {
+	if math.IsNaN(threshold) || threshold <= 0 || threshold >= 1 || samples <= 0 {
+		return nil
+	}
+
+	return &optionFunc{
+		fn: func(c *config) {
+			c.gcThreshold = threshold
+			c.gcSamples = samples
+		},
+	}
+}
+
+// WithGCRaiseLimit raises GOMEMLIMIT while garbage collector is in a death spiral
+// (see [WatchGC]). On each sample during the death spiral, GOMEMLIMIT is raised
+// halfway towards hard memory limit minus floor bytes, but never above it.
+// Hard memory limit is obtained from detector (see [WithMemoryQuotaDetector]),
+// and is adjusted for other processes sharing it, if [WithShare], [WithProcessShare]
+// or [WithExcludeOthers] is specified. GOMEMLIMIT is restored once the death
+// spiral ends, unless it was modified in the meantime. GOMEMLIMIT is not raised,
+// if it is not set or hard memory limit is not defined.
+//
+// If floor is negative, nil is returned, which is a no-op.
+func WithGCRaiseLimit(floor int64) Option {
+	if floor < 0 {
+		return nil
+	}
+
+	return &optionFunc{
+		fn: func(c *config) {
+			c.gcRaise = true
+			c.gcFloor = floor
+		},
+	}
+}
+
+// WithGCHandler configures a handler which is called by [WatchGC] when garbage
+// collector enters or leaves a death spiral, as indicated by [GCStatus.Spiral].
+// This can be used to shed load or release caches. Handler runs on the
+// goroutine running [WatchGC], and must not block for long.
+func WithGCHandler(fn func(ctx context.Context, status GCStatus)) Option {
+	if fn != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.gcHandler = fn
+			},
+		}
+	}
+	return nil
+}
+
+// WatchGC samples CPU usage of the garbage collector from [runtime/metrics] every
+// interval, until ctx is done, and guards against GC death spiral. With a tight
+// GOMEMLIMIT, when heap is persistently near it, garbage collector runs frequently
+// and the workload can spend most of its CPU time in garbage collection.
+//
+// When garbage collector enters a death spiral (see [WithGCGuard]), GOMEMLIMIT is
+// raised towards hard memory limit if [WithGCRaiseLimit] is specified, and handler
+// specified via [WithGCHandler] is called. Once death spiral ends, GOMEMLIMIT is
+// restored and handler is called again. GOMEMLIMIT is also restored when ctx is done.
+//
+// This should not be used with [Watch], as it would override raised GOMEMLIMIT.
+// Only [WithLogger], [WithMemoryQuotaDetector], [WithShare], [WithProcessShare],
+// [WithExcludeOthers], [WithGCGuard], [WithGCRaiseLimit], [WithGCHandler] and
+// [WithSystemdNotify] options are used. This always returns a non-nil error,
+// which wraps ctx error when ctx is done.
+func WatchGC(ctx context.Context, interval time.Duration, opts ...Option) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("memlimit: invalid watch interval: %s", interval)
+	}
+
+	guard := newGCGuard(newConfig(opts...))
+	guard.observe(ctx, readGCSample(), time.Now())
+	defer guard.restore(context.WithoutCancel(ctx))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("memlimit: %w", ctx.Err())
+		case <-ticker.C:
+			guard.observe(ctx, readGCSample(), time.Now())
+		}
+	}
+}
+
+// gcSample is a sample of garbage collector metrics.
+type gcSample struct {
+	gc      float64 // CPU time spent in garbage collection.
+	total   float64 // Total available CPU time.
+	cycles  uint64  // Number of completed GC cycles.
+	limiter uint64  // GC cycle in which GC CPU limiter was last enabled.
+}
+
+// readGCSample reads garbage collector metrics.
+func readGCSample() gcSample {
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: "/gc/limiter/last-enabled:gc-cycle"},
+	}
+	metrics.Read(samples)
+
+	var rv gcSample
+	if samples[0].Value.Kind() == metrics.KindFloat64 {
+		rv.gc = samples[0].Value.Float64()
+	}
+
+	if samples[1].Value.Kind() == metrics.KindFloat64 {
+		rv.total = samples[1].Value.Float64()
+	}
+
+	if samples[2].Value.Kind() == metrics.KindUint64 {
+		rv.cycles = samples[2].Value.Uint64()
+	}
+
+	if samples[3].Value.Kind() == metrics.KindUint64 {
+		rv.limiter = samples[3].Value.Uint64()
+	}
+	return rv
+}
+
+// gcGuard tracks garbage collector death spiral and GOMEMLIMIT raised due to it.
+type gcGuard struct {
+	cfg         *config
+	previous    gcSample
+	initialized bool
+	above       int
+	below       int
+	spiral      bool
+	target      int64
+	limit       int64
+	raised      int64
+}
+
+// newGCGuard returns a new gcGuard, with defaults applied to cfg.
+func newGCGuard(cfg *config) *gcGuard {
+	if cfg.gcThreshold <= 0 {
+		cfg.gcThreshold = DefaultGCThreshold
+	}
+
+	if cfg.gcSamples <= 0 {
+		cfg.gcSamples = DefaultGCSamples
+	}
+	return &gcGuard{cfg: cfg}
+}
+
+// observe observes a sample and acts on death spiral transitions.
+// First sample is only used as a baseline.
+func (g *gcGuard) observe(ctx context.Context, sample gcSample, now time.Time) GCStatus {
+	previous := g.previous
+	g.previous = sample
+	if !g.initialized {
+		g.initialized = true
+		return GCStatus{Time: now, Limit: Current()}
+	}
+
+	status := GCStatus{
+		Time:    now,
+		Limiter: sample.limiter != previous.limiter && sample.limiter != 0,
+	}
+
+	if elapsed := sample.total - previous.total; elapsed > 0 {
+		status.Share = min(max((sample.gc-previous.gc)/elapsed, 0), 1)
+	}
+
+	if status.Share >= g.cfg.gcThreshold || status.Limiter {
+		g.above++
+		g.below = 0
+	} else {
+		g.below++
+		g.above = 0
+	}
+
+	switch {
+	case !g.spiral && g.above >= g.cfg.gcSamples:
+		g.spiral = true
+		g.cfg.logger.LogAttrs(ctx, slog.LevelWarn, "Garbage collector is in a death spiral",
+			slog.Float64("gc.share", status.Share),
+			slog.Bool("gc.limiter", status.Limiter),
+		)
+		g.raise(ctx)
+		status.Spiral = true
+		status.Limit = Current()
+		if g.cfg.gcHandler != nil {
+			g.cfg.gcHandler(ctx, status)
+		}
+	case g.spiral && g.above > 0:
+		g.raise(ctx)
+		status.Spiral = true
+		status.Limit = Current()
+	case g.spiral && g.below >= g.cfg.gcSamples:
+		g.spiral = false
+		g.cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Garbage collector recovered from death spiral",
+			slog.Float64("gc.share", status.Share),
+		)
+		g.restore(ctx)
+		status.Limit = Current()
+		if g.cfg.gcHandler != nil {
+			g.cfg.gcHandler(ctx, status)
+		}
+	default:
+		status.Spiral = g.spiral
+		status.Limit = Current()
+	}
+	return status
+}
+
+// raise raises GOMEMLIMIT halfway towards target, which is computed
+// when raise is first called during a death spiral.
+func (g *gcGuard) raise(ctx context.Context) {
+	if !g.cfg.gcRaise {
+		return
+	}
+
+	current := Current()
+	if g.raised == 0 {
+		if current == math.MaxInt64 {
+			g.cfg.logger.LogAttrs(ctx, slog.LevelInfo,
+				"GOMEMLIMIT is not set, not raising it due to death spiral")
+			return
+		}
+
+		target, err := g.targetOf(ctx)
+		if err != nil {
+			g.cfg.logger.LogAttrs(ctx, slog.LevelError, "Failed to get memory limits",
+				slog.Any("err", err),
+			)
+			return
+		}
+
+		if target <= current {
+			g.cfg.logger.LogAttrs(ctx, slog.LevelInfo,
+				"GOMEMLIMIT cannot be raised further due to death spiral",
+				slog.String("GOMEMLIMIT", units.Format(current)),
+				slog.String("memlimit.target", units.Format(target)),
+			)
+			return
+		}
+		g.limit = current
+		g.target = target
+	} else if current != g.raised {
+		// Leave GOMEMLIMIT modified by others unchanged.
+		return
+	}
+
+	next := current + (g.target-current)/2
+	if g.target-next < units.MiB {
+		next = g.target
+	}
+
+	if next == current {
+		return
+	}
+
+	g.cfg.logger.LogAttrs(ctx, slog.LevelWarn, "Raising GOMEMLIMIT due to death spiral",
+		slog.String("GOMEMLIMIT", units.Format(next)),
+		slog.String("memlimit.target", units.Format(g.target)),
+	)
+	g.raised = next
+	set(next)
+	if g.cfg.notify {
+		notify(ctx, g.cfg, next, "gc death spiral")
+	}
+}
+
+// targetOf returns hard memory limit minus floor. Zero is returned
+// if hard memory limit is not defined.
+func (g *gcGuard) targetOf(ctx context.Context) (int64, error) {
+	info, err := shared.Call(ctx, func(ctx context.Context) (MemoryInfo, error) {
+		return detectMemoryInfo(ctx, g.cfg.detector)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("memlimit: %w", err)
+	}
+
+	info, _, _ = shareMemoryInfo(ctx, g.cfg, info)
+	if info.Max <= 0 {
+		return 0, nil
+	}
+	return max(info.Max-g.cfg.gcFloor, 0), nil
+}
+
+// restore restores GOMEMLIMIT if it was raised and was not modified since.
+func (g *gcGuard) restore(ctx context.Context) {
+	if g.raised == 0 {
+		return
+	}
+
+	if Current() == g.raised {
+		g.cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Restoring GOMEMLIMIT after death spiral",
+			slog.String("GOMEMLIMIT", units.Format(g.limit)),
+		)
+		set(g.limit)
+		if g.cfg.notify {
+			notify(ctx, g.cfg, g.limit, "restored after gc death spiral")
+		}
+	} else {
+		g.cfg.logger.LogAttrs(ctx, slog.LevelWarn,
+			"GOMEMLIMIT was modified during death spiral, leaving it unchanged",
+			slog.String("GOMEMLIMIT", units.Format(Current())),
+		)
+	}
+	g.raised = 0
+	g.limit = 0
+	g.target = 0
+}
@@ -0,0 +1,203 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+)
+
+func TestGCGuard(t *testing.T) {
+	t.Setenv("GOMEMLIMIT", "")
+	t.Cleanup(func() {
+		debug.SetMemoryLimit(math.MaxInt64)
+	})
+
+	type step struct {
+		sample gcSample
+		spiral bool
+		limit  int64
+	}
+
+	tt := []struct {
+		name    string
+		opts    []Option
+		limit   int64
+		steps   []step
+		handler []bool
+	}{
+		{
+			name:  "Raise",
+			opts:  []Option{WithGCRaiseLimit(64 * shared.MiByte)},
+			limit: 512 * shared.MiByte,
+			steps: []step{
+				{sample: gcSample{gc: 0.6, total: 1}, limit: 512 * shared.MiByte},
+				{sample: gcSample{gc: 1.2, total: 2}, spiral: true, limit: 736 * shared.MiByte},
+				{sample: gcSample{gc: 1.8, total: 3}, spiral: true, limit: 848 * shared.MiByte},
+				{sample: gcSample{gc: 1.9, total: 4}, spiral: true, limit: 848 * shared.MiByte},
+				{sample: gcSample{gc: 2.0, total: 5}, limit: 512 * shared.MiByte},
+			},
+			handler: []bool{true, false},
+		},
+		{
+			name:  "Limiter",
+			opts:  []Option{WithGCRaiseLimit(64 * shared.MiByte)},
+			limit: 512 * shared.MiByte,
+			steps: []step{
+				{sample: gcSample{gc: 0.1, total: 1, cycles: 2, limiter: 1}, limit: 512 * shared.MiByte},
+				{sample: gcSample{gc: 0.2, total: 2, cycles: 4, limiter: 3}, spiral: true, limit: 736 * shared.MiByte},
+				{sample: gcSample{gc: 0.3, total: 3, cycles: 6, limiter: 3}, spiral: true, limit: 736 * shared.MiByte},
+				{sample: gcSample{gc: 0.4, total: 4, cycles: 8, limiter: 3}, limit: 512 * shared.MiByte},
+			},
+			handler: []bool{true, false},
+		},
+		{
+			name:  "HandlerOnly",
+			limit: 512 * shared.MiByte,
+			steps: []step{
+				{sample: gcSample{gc: 0.6, total: 1}, limit: 512 * shared.MiByte},
+				{sample: gcSample{gc: 1.2, total: 2}, spiral: true, limit: 512 * shared.MiByte},
+				{sample: gcSample{gc: 1.3, total: 3}, spiral: true, limit: 512 * shared.MiByte},
+				{sample: gcSample{gc: 1.4, total: 4}, limit: 512 * shared.MiByte},
+			},
+			handler: []bool{true, false},
+		},
+		{
+			name:  "LimitNotSet",
+			opts:  []Option{WithGCRaiseLimit(64 * shared.MiByte)},
+			limit: math.MaxInt64,
+			steps: []step{
+				{sample: gcSample{gc: 0.6, total: 1}, limit: math.MaxInt64},
+				{sample: gcSample{gc: 1.2, total: 2}, spiral: true, limit: math.MaxInt64},
+			},
+			handler: []bool{true},
+		},
+		{
+			name:  "AboveTarget",
+			opts:  []Option{WithGCRaiseLimit(64 * shared.MiByte)},
+			limit: 1000 * shared.MiByte,
+			steps: []step{
+				{sample: gcSample{gc: 0.6, total: 1}, limit: 1000 * shared.MiByte},
+				{sample: gcSample{gc: 1.2, total: 2}, spiral: true, limit: 1000 * shared.MiByte},
+			},
+			handler: []bool{true},
+		},
+		{
+			name:  "NotPersistent",
+			opts:  []Option{WithGCRaiseLimit(64 * shared.MiByte)},
+			limit: 512 * shared.MiByte,
+			steps: []step{
+				{sample: gcSample{gc: 0.6, total: 1}, limit: 512 * shared.MiByte},
+				{sample: gcSample{gc: 0.7, total: 2}, limit: 512 * shared.MiByte},
+				{sample: gcSample{gc: 1.3, total: 3}, limit: 512 * shared.MiByte},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			set(tc.limit)
+			var handler []bool
+			opts := append([]Option{
+				WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				WithMemoryQuotaDetector(MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+					return shared.GiByte, 0, nil
+				})),
+				WithGCGuard(0.5, 2),
+				WithGCHandler(func(_ context.Context, status GCStatus) {
+					handler = append(handler, status.Spiral)
+				}),
+			}, tc.opts...)
+
+			guard := newGCGuard(newConfig(opts...))
+			guard.observe(context.Background(), gcSample{}, time.Now())
+			for i, step := range tc.steps {
+				status := guard.observe(context.Background(), step.sample, time.Now())
+				if status.Spiral != step.spiral || status.Limit != step.limit {
+					t.Errorf("step=%d expected spiral=%t, limit=%d, got=%+v", i, step.spiral, step.limit, status)
+				}
+			}
+
+			if len(handler) != len(tc.handler) {
+				t.Fatalf("expected handler calls=%v, got=%v", tc.handler, handler)
+			}
+
+			for i := range handler {
+				if handler[i] != tc.handler[i] {
+					t.Errorf("expected handler calls=%v, got=%v", tc.handler, handler)
+				}
+			}
+		})
+	}
+
+	t.Run("LimiterOnce", func(t *testing.T) {
+		set(512 * shared.MiByte)
+		guard := newGCGuard(newConfig(
+			WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			WithGCGuard(0.5, 2),
+		))
+
+		// GC CPU limiter is enabled once, during a cycle which is still in progress.
+		guard.observe(context.Background(), gcSample{cycles: 2}, time.Now())
+		for i, expect := range []bool{true, false, false} {
+			sample := gcSample{gc: 0.1 * float64(i+1), total: float64(i + 1), cycles: 2, limiter: 3}
+			status := guard.observe(context.Background(), sample, time.Now())
+			if status.Limiter != expect || status.Spiral {
+				t.Errorf("step=%d expected limiter=%t, spiral=false, got=%+v", i, expect, status)
+			}
+		}
+	})
+
+	t.Run("ModifiedDuringSpiral", func(t *testing.T) {
+		set(512 * shared.MiByte)
+		guard := newGCGuard(newConfig(
+			WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			WithMemoryQuotaDetector(MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+				return shared.GiByte, 0, nil
+			})),
+			WithGCGuard(0.5, 1),
+			WithGCRaiseLimit(0),
+		))
+		guard.observe(context.Background(), gcSample{}, time.Now())
+		guard.observe(context.Background(), gcSample{gc: 0.6, total: 1}, time.Now())
+		if Current() != 768*shared.MiByte {
+			t.Fatalf("expected GOMEMLIMIT=768MiB, got=%d", Current())
+		}
+
+		debug.SetMemoryLimit(256 * shared.MiByte)
+		guard.observe(context.Background(), gcSample{gc: 1.2, total: 2}, time.Now())
+		guard.observe(context.Background(), gcSample{gc: 1.2, total: 3}, time.Now())
+		if Current() != 256*shared.MiByte {
+			t.Errorf("expected GOMEMLIMIT=256MiB, got=%d", Current())
+		}
+	})
+}
+
+func TestWatchGC(t *testing.T) {
+	t.Run("InvalidInterval", func(t *testing.T) {
+		err := WatchGC(context.Background(), 0)
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := WatchGC(ctx, 10*time.Millisecond,
+			WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+		)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
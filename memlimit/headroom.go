@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+)
+
+// Headroom describes memory usage of the workload and the Go runtime,
+// and headroom remaining to memory limits. All values are in bytes.
+type Headroom struct {
+	// Time at which memory usage was measured.
+	Time time.Time `json:"time"`
+
+	// Hard memory limit, like memory.max on Linux. Zero if not defined.
+	Max int64 `json:"max,omitempty"`
+
+	// Soft memory limit, like memory.high on Linux. Zero if not defined.
+	High int64 `json:"high,omitempty"`
+
+	// Current memory usage of the workload, like memory.current on Linux.
+	// This includes memory not managed by the Go runtime, like page cache and
+	// memory used by cgo or other processes in the cgroup. If the platform does
+	// not report memory usage, this is same as [Headroom.Runtime].
+	Current int64 `json:"current"`
+
+	// Peak memory usage of the workload, like memory.peak on Linux.
+	// Zero if not supported.
+	Peak int64 `json:"peak,omitempty"`
+
+	// Memory occupied by live and unswept heap objects, as reported by
+	// runtime metric /memory/classes/heap/objects:bytes.
+	Heap int64 `json:"heap"`
+
+	// All memory mapped by the Go runtime, as reported by runtime metric
+	// /memory/classes/total:bytes.
+	Runtime int64 `json:"runtime"`
+
+	// Headroom to hard memory limit, i.e Max - Current.
+	// This is [math.MaxInt64] if hard memory limit is not defined.
+	MaxHeadroom int64 `json:"max_headroom"`
+
+	// Headroom to soft memory limit, i.e High - Current. This may be negative,
+	// as soft memory limit can be exceeded. This is [math.MaxInt64] if soft
+	// memory limit is not defined.
+	HighHeadroom int64 `json:"high_headroom"`
+
+	// Source of memory limits and usage, for example cgroup or jobobject.
+	// This is runtime if the platform does not support memory limits.
+	Source string `json:"source"`
+}
+
+// DetectHeadroom returns memory usage of the workload and the Go runtime,
+// and headroom to memory limits. Memory limits and usage are obtained from
+// the detector specified via [WithMemoryQuotaDetector], which should implement
+// [MemoryInfoDetector] to report memory usage. Other options are ignored.
+//
+// Unlike GOMEMLIMIT, which is only a hint to the garbage collector, headroom to
+// hard memory limit indicates how close the workload is to being OOM killed.
+// This can be used by load shedders and readiness probes. See [Forecaster] to
+// estimate time until memory limits are reached.
+//
+// If the platform does not support memory limits, only runtime metrics are
+// populated and no error is returned. Otherwise, even when an error is
+// returned, runtime metrics are populated.
+func DetectHeadroom(ctx context.Context, opts ...Option) (Headroom, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cfg := newConfig(opts...)
+	rv := Headroom{
+		Time:         time.Now(),
+		MaxHeadroom:  math.MaxInt64,
+		HighHeadroom: math.MaxInt64,
+		Source:       "runtime",
+	}
+	rv.Heap, rv.Runtime, _ = runtimeMemory()
+	rv.Current = rv.Runtime
+
+	info, err := shared.Call(ctx, func(ctx context.Context) (MemoryInfo, error) {
+		return detectMemoryInfo(ctx, cfg.detector)
+	})
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return rv, nil
+		}
+		return rv, fmt.Errorf("memlimit: %w", err)
+	}
+
+	rv.Max = info.Max
+	rv.High = info.High
+	rv.Peak = info.Peak
+	rv.Source = info.Source
+	if info.Current > 0 {
+		rv.Current = info.Current
+	}
+
+	if rv.Max > 0 {
+		rv.MaxHeadroom = rv.Max - rv.Current
+	}
+
+	if rv.High > 0 {
+		rv.HighHeadroom = rv.High - rv.Current
+	}
+	return rv, nil
+}
+
+// runtimeMemory returns heap objects, total memory mapped by the Go runtime
+// and memory released to the operating system by the Go runtime.
+func runtimeMemory() (int64, int64, int64) {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+
+	values := make([]int64, len(samples))
+	for i := range samples {
+		if samples[i].Value.Kind() == metrics.KindUint64 {
+			values[i] = int64(min(samples[i].Value.Uint64(), math.MaxInt64))
+		}
+	}
+	return values[0], values[1], values[2]
+}
+
+// Forecast is time until memory limits are reached, estimated by [Forecaster].
+type Forecast struct {
+	// Rate at which memory usage is changing, in bytes per second.
+	// This is negative if memory usage is decreasing.
+	Rate float64 `json:"rate"`
+
+	// Estimated time until hard memory limit is reached. Zero if already reached.
+	// This is [math.MaxInt64] if hard memory limit is not defined, memory usage
+	// is not increasing or there are not enough samples to estimate the trend.
+	UntilMax time.Duration `json:"until_max"`
+
+	// Estimated time until soft memory limit is reached. Zero if already reached.
+	// This is [math.MaxInt64] if soft memory limit is not defined, memory usage
+	// is not increasing or there are not enough samples to estimate the trend.
+	UntilHigh time.Duration `json:"until_high"`
+
+	// Number of samples used to estimate the trend.
+	Samples int `json:"samples"`
+}
+
+// Forecaster estimates time until memory limits are reached, from linear trend
+// of memory usage in recently observed [Headroom] samples. This is only an
+// estimate and assumes memory usage keeps growing at the same rate.
+// It is safe for concurrent use.
+type Forecaster struct {
+	mu      sync.Mutex
+	samples []Headroom
+	next    int
+	count   int
+}
+
+// NewForecaster returns a new [Forecaster], which estimates trend from last
+// n samples. If n is less than 2, 2 is used. Samples should be observed at
+// regular intervals, for example, with n=12 and samples observed every 5 seconds,
+// trend is estimated over the last minute.
+func NewForecaster(n int) *Forecaster {
+	return &Forecaster{samples: make([]Headroom, max(n, 2))}
+}
+
+// Observe adds sample h and returns the updated [Forecast]. Samples which are not
+// newer than the last observed sample are ignored.
+func (f *Forecaster) Observe(h Headroom) Forecast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.count == 0 || h.Time.After(f.last().Time) {
+		f.samples[f.next] = h
+		f.next = (f.next + 1) % len(f.samples)
+		f.count = min(f.count+1, len(f.samples))
+	}
+	return f.forecast()
+}
+
+// Forecast returns [Forecast] based on samples observed so far.
+func (f *Forecaster) Forecast() Forecast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.forecast()
+}
+
+// last returns last observed sample. f.mu must be held and f.count must be positive.
+func (f *Forecaster) last() Headroom {
+	return f.samples[(f.next-1+len(f.samples))%len(f.samples)]
+}
+
+// forecast computes forecast. f.mu must be held.
+func (f *Forecaster) forecast() Forecast {
+	rv := Forecast{
+		UntilMax:  math.MaxInt64,
+		UntilHigh: math.MaxInt64,
+		Samples:   f.count,
+	}
+
+	if f.count < 2 {
+		return rv
+	}
+
+	// Least squares fit of memory usage over time, relative to the oldest
+	// sample to avoid loss of precision.
+	oldest := (f.next - f.count + len(f.samples)) % len(f.samples)
+	origin := f.samples[oldest].Time
+	var meanX, meanY float64
+	for i := range f.count {
+		s := f.samples[(oldest+i)%len(f.samples)]
+		meanX += s.Time.Sub(origin).Seconds()
+		meanY += float64(s.Current)
+	}
+	meanX /= float64(f.count)
+	meanY /= float64(f.count)
+
+	var num, den float64
+	for i := range f.count {
+		s := f.samples[(oldest+i)%len(f.samples)]
+		dx := s.Time.Sub(origin).Seconds() - meanX
+		num += dx * (float64(s.Current) - meanY)
+		den += dx * dx
+	}
+
+	if den > 0 {
+		rv.Rate = num / den
+	}
+
+	last := f.last()
+	if last.Max > 0 {
+		rv.UntilMax = until(last.MaxHeadroom, rv.Rate)
+	}
+
+	if last.High > 0 {
+		rv.UntilHigh = until(last.HighHeadroom, rv.Rate)
+	}
+	return rv
+}
+
+// until returns time until headroom is exhausted at given rate.
+func until(headroom int64, rate float64) time.Duration {
+	if headroom <= 0 {
+		return 0
+	}
+
+	if rate <= 0 {
+		return math.MaxInt64
+	}
+
+	seconds := float64(headroom) / rate
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,256 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestDetectHeadroom(t *testing.T) {
+	tt := []struct {
+		name     string
+		detector memlimit.MemoryQuotaDetector
+		expect   memlimit.Headroom
+		err      bool
+	}{
+		{
+			name: "MaxAndHigh",
+			detector: infoDetector{
+				Max:     500 * shared.MiByte,
+				High:    400 * shared.MiByte,
+				Current: 450 * shared.MiByte,
+				Peak:    480 * shared.MiByte,
+				Source:  "cgroup",
+			},
+			expect: memlimit.Headroom{
+				Max:          500 * shared.MiByte,
+				High:         400 * shared.MiByte,
+				Current:      450 * shared.MiByte,
+				Peak:         480 * shared.MiByte,
+				MaxHeadroom:  50 * shared.MiByte,
+				HighHeadroom: -50 * shared.MiByte,
+				Source:       "cgroup",
+			},
+		},
+		{
+			name: "MaxOnly",
+			detector: infoDetector{
+				Max:     500 * shared.MiByte,
+				Current: 100 * shared.MiByte,
+				Source:  "cgroup",
+			},
+			expect: memlimit.Headroom{
+				Max:          500 * shared.MiByte,
+				Current:      100 * shared.MiByte,
+				MaxHeadroom:  400 * shared.MiByte,
+				HighHeadroom: math.MaxInt64,
+				Source:       "cgroup",
+			},
+		},
+		{
+			name: "Unsupported",
+			detector: memlimit.MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+				return 0, 0, fmt.Errorf("test: %w", errors.ErrUnsupported)
+			}),
+			expect: memlimit.Headroom{
+				MaxHeadroom:  math.MaxInt64,
+				HighHeadroom: math.MaxInt64,
+				Source:       "runtime",
+			},
+		},
+		{
+			name: "Error",
+			detector: memlimit.MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+				return 0, 0, errors.New("test: error")
+			}),
+			expect: memlimit.Headroom{
+				MaxHeadroom:  math.MaxInt64,
+				HighHeadroom: math.MaxInt64,
+				Source:       "runtime",
+			},
+			err: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := memlimit.DetectHeadroom(context.Background(),
+				memlimit.WithMemoryQuotaDetector(tc.detector))
+			if tc.err != (err != nil) {
+				t.Errorf("expected error=%t, got=%v", tc.err, err)
+			}
+
+			if v.Time.IsZero() {
+				t.Errorf("expected time to be set")
+			}
+
+			if v.Heap <= 0 || v.Runtime <= 0 || v.Heap > v.Runtime {
+				t.Errorf("invalid runtime metrics heap=%d, runtime=%d", v.Heap, v.Runtime)
+			}
+
+			// Without memory usage, runtime total is used.
+			if tc.expect.Current == 0 {
+				tc.expect.Current = v.Runtime
+			}
+
+			tc.expect.Time, tc.expect.Heap, tc.expect.Runtime = v.Time, v.Heap, v.Runtime
+			if v != tc.expect {
+				t.Errorf("expected=%+v, got=%+v", tc.expect, v)
+			}
+		})
+	}
+}
+
+func TestForecaster(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(offset time.Duration, current int64) memlimit.Headroom {
+		return memlimit.Headroom{
+			Time:         epoch.Add(offset),
+			Max:          1000,
+			High:         800,
+			Current:      current,
+			MaxHeadroom:  1000 - current,
+			HighHeadroom: 800 - current,
+		}
+	}
+
+	tt := []struct {
+		name    string
+		size    int
+		samples []memlimit.Headroom
+		expect  memlimit.Forecast
+	}{
+		{
+			name: "NoSamples",
+			expect: memlimit.Forecast{
+				UntilMax:  math.MaxInt64,
+				UntilHigh: math.MaxInt64,
+			},
+		},
+		{
+			name:    "SingleSample",
+			samples: []memlimit.Headroom{sample(0, 100)},
+			expect: memlimit.Forecast{
+				UntilMax:  math.MaxInt64,
+				UntilHigh: math.MaxInt64,
+				Samples:   1,
+			},
+		},
+		{
+			name: "Increasing",
+			samples: []memlimit.Headroom{
+				sample(0, 100),
+				sample(time.Second, 110),
+				sample(2*time.Second, 120),
+				sample(3*time.Second, 130),
+			},
+			expect: memlimit.Forecast{
+				Rate:      10,
+				UntilMax:  87 * time.Second,
+				UntilHigh: 67 * time.Second,
+				Samples:   4,
+			},
+		},
+		{
+			name: "Decreasing",
+			samples: []memlimit.Headroom{
+				sample(0, 130),
+				sample(time.Second, 120),
+			},
+			expect: memlimit.Forecast{
+				Rate:      -10,
+				UntilMax:  math.MaxInt64,
+				UntilHigh: math.MaxInt64,
+				Samples:   2,
+			},
+		},
+		{
+			name: "HighExceeded",
+			samples: []memlimit.Headroom{
+				sample(0, 800),
+				sample(time.Second, 900),
+			},
+			expect: memlimit.Forecast{
+				Rate:      100,
+				UntilMax:  time.Second,
+				UntilHigh: 0,
+				Samples:   2,
+			},
+		},
+		{
+			name: "OnlyRecentSamples",
+			size: 2,
+			samples: []memlimit.Headroom{
+				sample(0, 900),
+				sample(time.Second, 100),
+				sample(2*time.Second, 200),
+			},
+			expect: memlimit.Forecast{
+				Rate:      100,
+				UntilMax:  8 * time.Second,
+				UntilHigh: 6 * time.Second,
+				Samples:   2,
+			},
+		},
+		{
+			name: "IgnoreOlderSamples",
+			samples: []memlimit.Headroom{
+				sample(0, 100),
+				sample(2*time.Second, 300),
+				sample(time.Second, 900),
+			},
+			expect: memlimit.Forecast{
+				Rate:      100,
+				UntilMax:  7 * time.Second,
+				UntilHigh: 5 * time.Second,
+				Samples:   2,
+			},
+		},
+		{
+			name: "NoLimits",
+			samples: []memlimit.Headroom{
+				{Time: epoch, Current: 100},
+				{Time: epoch.Add(time.Second), Current: 200},
+			},
+			expect: memlimit.Forecast{
+				Rate:      100,
+				UntilMax:  math.MaxInt64,
+				UntilHigh: math.MaxInt64,
+				Samples:   2,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			size := tc.size
+			if size == 0 {
+				size = 12
+			}
+
+			f := memlimit.NewForecaster(size)
+			for _, s := range tc.samples {
+				f.Observe(s)
+			}
+
+			v := f.Forecast()
+			if math.Abs(v.Rate-tc.expect.Rate) > 1e-9 {
+				t.Errorf("expected rate=%f, got=%f", tc.expect.Rate, v.Rate)
+			}
+
+			v.Rate = tc.expect.Rate
+			if v != tc.expect {
+				t.Errorf("expected=%+v, got=%+v", tc.expect, v)
+			}
+		})
+	}
+}
@@ -9,19 +9,157 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"math"
 	"os"
 	"runtime/debug"
-	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/tprasadtp/go-autotune/internal/discard"
+	"github.com/tprasadtp/go-autotune/internal/sdnotify"
 	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/units"
 )
 
 type config struct {
 	logger      *slog.Logger
 	detector    MemoryQuotaDetector
 	reserveFunc func(int64) int64
+	policy      LimitPolicy
+	preserve    bool
+	notify      bool
+
+	// Options used by [WithAdaptiveReserve].
+	adaptive   bool
+	reserveMin int64
+	reserveMax int64
+
+	// Options used by [WithShare], [WithProcessShare] and [WithExcludeOthers].
+	share         float64
+	processShare  bool
+	excludeOthers bool
+
+	// Options used by [WatchPressure].
+	pressureHandler  func(context.Context)
+	pressureFactor   float64
+	pressureCooldown time.Duration
+
+	// Options used by [WatchGC].
+	gcThreshold float64
+	gcSamples   int
+	gcRaise     bool
+	gcFloor     int64
+	gcHandler   func(context.Context, GCStatus)
+
+	// Options used by [WatchProfiles].
+	profileThresholds []float64
+	profileRateLimit  time.Duration
+	profileRetention  int
+}
+
+// Source indicates how GOMEMLIMIT value was determined.
+type Source string
+
+// Possible values for [Source].
+const (
+	// GOMEMLIMIT is unchanged, as memory limits are not defined
+	// or not supported on the platform.
+	SourceDefault Source = "default"
+
+	// GOMEMLIMIT is set from GOMEMLIMIT environment variable.
+	SourceEnv Source = "env"
+
+	// GOMEMLIMIT is set from memory limits.
+	SourceQuota Source = "quota"
+
+	// GOMEMLIMIT was modified by another package and is left unchanged.
+	// See [WithPreserveExternal].
+	SourceExternal Source = "external"
+)
+
+// Result describes GOMEMLIMIT value applied by [Apply].
+type Result struct {
+	// GOMEMLIMIT value before applying.
+	Previous int64 `json:"previous"`
+
+	// GOMEMLIMIT value after applying.
+	Value int64 `json:"value"`
+
+	// Hard memory limit detected. This is zero if not defined or not checked.
+	Max int64 `json:"max,omitempty"`
+
+	// Soft memory limit detected. This is zero if not defined or not checked.
+	High int64 `json:"high,omitempty"`
+
+	// Memory set aside as reserved, computed from hard memory limit. If memory
+	// limits are shared by multiple processes, this is computed from hard memory
+	// limit adjusted for this process (see [Result.Share] and [Result.Others]).
+	Reserve int64 `json:"reserve,omitempty"`
+
+	// Memory used by the workload which is not managed by the Go runtime,
+	// estimated when [WithAdaptiveReserve] is used. This is included in reserve.
+	NonGo int64 `json:"non_go,omitempty"`
+
+	// Share of memory limits used for this process, when memory limits are
+	// shared by multiple processes. See [WithShare] and [WithProcessShare].
+	// This is zero if memory limits are not divided.
+	Share float64 `json:"share,omitempty"`
+
+	// Memory used by other processes sharing memory limits, which was excluded
+	// from memory limits. See [WithExcludeOthers].
+	Others int64 `json:"others,omitempty"`
+
+	// Source of the GOMEMLIMIT value.
+	Source Source `json:"source"`
+
+	// Reason returned by [LimitPolicy], describing how GOMEMLIMIT
+	// was computed from memory limits. This is empty if limit policy
+	// was not used.
+	Reason string `json:"reason,omitempty"`
+}
+
+// applied is the last GOMEMLIMIT value set by [Configure]. It is used to detect
+// if GOMEMLIMIT was modified by other packages. Zero indicates [Configure]
+// has not modified GOMEMLIMIT yet.
+//
+//nolint:gochecknoglobals // tracks runtime state, which is global.
+var applied atomic.Int64
+
+// initial is GOMEMLIMIT value at startup, before it is modified by [Configure].
+// This is the default chosen by the runtime, which is [math.MaxInt64] unless
+// GOMEMLIMIT environment variable is set.
+//
+//nolint:gochecknoglobals // tracks runtime state, which is global.
+var initial = debug.SetMemoryLimit(-1)
+
+// last is the [Result] of the most recent successful [Apply]. It is used by
+// [WatchProfiles] to avoid detecting memory limits on every check.
+//
+//nolint:gochecknoglobals // tracks runtime state, which is global.
+var last atomic.Pointer[Result]
+
+// IsModified reports whether GOMEMLIMIT was modified by something other than
+// GOMEMLIMIT environment variable or [Configure]. This typically indicates use of
+// other packages which also modify GOMEMLIMIT like [github.com/KimMachineGun/automemlimit]
+// or direct calls to [runtime/debug.SetMemoryLimit]. Default value chosen by the
+// runtime is not considered as modified.
+//
+// [github.com/KimMachineGun/automemlimit]: https://pkg.go.dev/github.com/KimMachineGun/automemlimit
+func IsModified() bool {
+	if os.Getenv("GOMEMLIMIT") != "" {
+		return false
+	}
+
+	current := Current()
+	if current == initial {
+		return false
+	}
+	return current != applied.Load()
+}
+
+// set sets GOMEMLIMIT and records it as applied.
+func set(limit int64) {
+	applied.Store(limit)
+	debug.SetMemoryLimit(limit)
 }
 
 // Current returns current GOMEMLIMIT in bytes.
@@ -55,23 +193,105 @@ func Current() int64 {
 //   - If only [memory.max] is specified, GOMEMLIMIT is set to ([memory.max] - reserved).
 //   - If only [memory.high] limit is specified, GOMEMLIMIT is set to [memory.high].
 //
+// This is implemented by [DefaultLimitPolicy], and can be customized with [WithLimitPolicy].
+//
 // For Windows, [QueryInformationJobObject] API is used to get memory limits.
 // [JOBOBJECT_EXTENDED_LIMIT_INFORMATION] defines per process(ProcessMemoryLimit)
 // and per job memory limits(JobMemoryLimit). ProcessMemoryLimit is preferred
 // over JobMemoryLimit. Both are considered hard limits.
 //
+// If GOMEMLIMIT was already modified by another package (see [IsModified]),
+// a warning is logged and it is overridden, unless [WithPreserveExternal] is specified.
+//
+// Errors returned by the default detector wrap errors defined in package
+// [github.com/tprasadtp/go-autotune/cgroup], like [github.com/tprasadtp/go-autotune/cgroup.ErrCgroupV1]
+// and [github.com/tprasadtp/go-autotune/cgroup.ParseError], and can be checked with
+// [errors.Is] and [errors.As].
+//
 // [memory.max]: https://docs.kernel.org/admin-guide/cgroup-v2.html#memory-interface-files
 // [memory.high]: https://docs.kernel.org/admin-guide/cgroup-v2.html#memory-interface-files
 // [QueryInformationJobObject]: https://learn.microsoft.com/en-us/windows/win32/api/jobapi2/nf-jobapi2-queryinformationjobobject
 // [JOBOBJECT_EXTENDED_LIMIT_INFORMATION]: https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-jobobject_extended_limit_information
 func Configure(ctx context.Context, opts ...Option) error {
+	_, err := Apply(ctx, opts...)
+	return err
+}
+
+// Apply is same as [Configure], but also returns a [Result] describing
+// the applied GOMEMLIMIT value, detected limits and its [Source]. Even when
+// an error is returned, [Result] contains current GOMEMLIMIT value.
+func Apply(ctx context.Context, opts ...Option) (Result, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	if ctx.Err() != nil {
-		return fmt.Errorf("memlimit: %w", ctx.Err())
+
+	cfg := newConfig(opts...)
+	result, err := compute(ctx, cfg)
+	if err != nil {
+		return result, err
+	}
+
+	switch result.Source {
+	case SourceEnv, SourceQuota:
+		if result.Previous != result.Value {
+			if result.Source == SourceEnv {
+				cfg.logger.LogAttrs(ctx, slog.LevelInfo,
+					"Setting GOMEMLIMIT from environment variable",
+					slog.String("GOMEMLIMIT", units.Format(result.Value)))
+			} else {
+				cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Setting GOMEMLIMIT",
+					slog.String("GOMEMLIMIT", units.Format(result.Value)),
+					slog.String("reason", result.Reason))
+			}
+			set(result.Value)
+		} else {
+			// Record value as applied, even though it is unchanged. As [Watch]
+			// calls Apply periodically, only log at info level the first time.
+			level := slog.LevelInfo
+			if applied.Swap(result.Value) == result.Value {
+				level = slog.LevelDebug
+			}
+			if result.Source == SourceEnv {
+				cfg.logger.LogAttrs(ctx, level,
+					"GOMEMLIMIT is already set from environment variable",
+					slog.String("GOMEMLIMIT", units.Format(result.Value)))
+			} else {
+				cfg.logger.LogAttrs(ctx, level, "GOMEMLIMIT is already set",
+					slog.String("GOMEMLIMIT", units.Format(result.Value)))
+			}
+		}
+	default:
+		// GOMEMLIMIT is left unchanged.
+	}
+
+	last.Store(&result)
+	if cfg.notify {
+		notify(ctx, cfg, result.Value, string(result.Source))
+	}
+	return result, nil
+}
+
+// notify reports GOMEMLIMIT to the service manager. Errors are only logged.
+func notify(ctx context.Context, cfg *config, limit int64, source string) {
+	err := sdnotify.UpdateMemLimit(limit, source)
+	if err != nil {
+		cfg.logger.LogAttrs(ctx, slog.LevelWarn, "Failed to notify service manager",
+			slog.Any("err", err),
+		)
+	}
+}
+
+// Compute returns GOMEMLIMIT value which would be set by [Apply],
+// without modifying it. This is useful for diagnostics.
+func Compute(ctx context.Context, opts ...Option) (Result, error) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	return compute(ctx, newConfig(opts...))
+}
 
+// newConfig builds config from options and applies defaults.
+func newConfig(opts ...Option) *config {
 	cfg := &config{}
 
 	// Apply all options.
@@ -96,129 +316,152 @@ func Configure(ctx context.Context, opts ...Option) error {
 		cfg.reserveFunc = DefaultReserveFunc()
 	}
 
-	var limit int64
-	var err error
+	// If limit policy is nil, use default.
+	if cfg.policy == nil {
+		cfg.policy = DefaultLimitPolicy()
+	}
+	return cfg
+}
 
+// compute computes GOMEMLIMIT value to set, but does not set it.
+func compute(ctx context.Context, cfg *config) (Result, error) {
 	// Get current value of memory limit.
 	snapshot := debug.SetMemoryLimit(-1)
+	result := Result{
+		Previous: snapshot,
+		Value:    snapshot,
+		Source:   SourceDefault,
+	}
+
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("memlimit: %w", ctx.Err())
+	}
+
+	var limit int64
+	var err error
 
 	// Check if GOMEMLIMIT env variable.
 	env := os.Getenv("GOMEMLIMIT")
 	if env != "" {
-		// Value "off" does not appear to be documented but is part of implementation.
-		// preserve the same behavior.
-		//
-		// https://go.googlesource.com/go/+/refs/tags/go1.22.3/src/runtime/mgcpacer.go#1323
-		if env == "off" {
-			limit = math.MaxInt64
-		} else {
-			limit, err = shared.ParseMemlimit(env)
-			if err != nil {
-				cfg.logger.LogAttrs(ctx, slog.LevelError,
-					"GOMEMLIMIT environment variable is invalid",
-					slog.String("GOMEMLIMIT", env),
-				)
-				return fmt.Errorf("GOMEMLIMIT environment variable(%q) is invalid", env)
-			}
+		limit, err = units.ParseGOMEMLIMIT(env)
+		if err != nil {
+			cfg.logger.LogAttrs(ctx, slog.LevelError,
+				"GOMEMLIMIT environment variable is invalid",
+				slog.String("GOMEMLIMIT", env),
+			)
+			return result, fmt.Errorf("GOMEMLIMIT environment variable(%q) is invalid", env)
 		}
 
-		// Set GOMEMLIMIT from env variable.
-		cfg.logger.LogAttrs(ctx, slog.LevelInfo,
-			"Setting GOMEMLIMIT from environment variable",
-			slog.String("GOMEMLIMIT", env))
-		if snapshot != limit {
-			cfg.logger.LogAttrs(ctx, slog.LevelInfo,
-				"Setting GOMEMLIMIT from environment variable",
-				slog.String("GOMEMLIMIT", env))
-			debug.SetMemoryLimit(limit)
-		} else {
-			cfg.logger.LogAttrs(ctx, slog.LevelInfo,
-				"GOMEMLIMIT is already set from environment variable",
-				slog.String("GOMEMLIMIT", env))
+		result.Value = limit
+		result.Source = SourceEnv
+		return result, nil
+	}
+
+	// Check if GOMEMLIMIT was modified by other packages.
+	if IsModified() {
+		if cfg.preserve {
+			cfg.logger.LogAttrs(ctx, slog.LevelWarn,
+				"GOMEMLIMIT was modified by another package, leaving it unchanged",
+				slog.String("GOMEMLIMIT", units.Format(snapshot)),
+			)
+			result.Source = SourceExternal
+			return result, nil
 		}
-		return nil
+		cfg.logger.LogAttrs(ctx, slog.LevelWarn,
+			"GOMEMLIMIT was modified by another package, overriding it",
+			slog.String("GOMEMLIMIT", units.Format(snapshot)),
+		)
 	}
 
 	// Get memory limits.
-	var hard int64
-	var soft int64
-	hard, soft, err = cfg.detector.DetectMemoryQuota(ctx)
+	info, err := shared.Call(ctx, func(ctx context.Context) (MemoryInfo, error) {
+		return detectMemoryInfo(ctx, cfg.detector)
+	})
 	if err != nil {
 		// Ignore unsupported platform error and do nothing.
 		if errors.Is(err, errors.ErrUnsupported) {
-			return nil
+			return result, nil
 		}
 
 		cfg.logger.LogAttrs(ctx, slog.LevelError,
 			"Failed to get memory limits",
 			slog.Any("err", err))
-		return fmt.Errorf("memlimit: %w", err)
+		return result, fmt.Errorf("memlimit: %w", err)
 	}
 
-	if hard <= 0 && soft <= 0 {
+	if info.Max <= 0 && info.High <= 0 {
 		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Memory limits not specified")
-		return nil
+		return result, nil
 	}
 
+	// Adjust memory limits, if they are shared by multiple processes.
+	detected := info
+	var share float64
+	info, share, result.Others = shareMemoryInfo(ctx, cfg, info)
+	if share < 1 {
+		result.Share = share
+	}
+
+	hard, soft := info.Max, info.High
+
 	// Calculate reserve memory only if hard limit is defined.
 	var reserve int64
 	if hard > 0 {
-		reserve = cfg.reserveFunc(hard)
-		if reserve < 0 {
-			err = fmt.Errorf("memlimit: reserve bytes count is negative:%d", reserve)
+		reserve, err = reserveOf(cfg.reserveFunc, hard)
+		if err == nil && cfg.adaptive {
+			// Memory used by other processes is already excluded from limits.
+			result.NonGo = max(nonGoMemory(info)-result.Others, 0)
+			reserve = min(max(reserve+result.NonGo, cfg.reserveMin), cfg.reserveMax)
 		}
 
-		if reserve >= hard {
+		switch {
+		case err != nil:
+			err = fmt.Errorf("memlimit: reserve func failed: %w", err)
+		case reserve < 0:
+			err = fmt.Errorf("memlimit: reserve bytes count is negative:%d", reserve)
+		case reserve >= hard:
 			err = fmt.Errorf("memlimit: reserve bytes larger than hard limit:%d", reserve)
 		}
 
 		if err != nil {
 			cfg.logger.LogAttrs(ctx, slog.LevelError, "ReserveFunc returned invalid value",
-				slog.Int64("memlimit.hard", hard),
-				slog.Int64("memlimit.soft", soft),
-				slog.Int64("memlimit.reserved", reserve),
+				slog.String("memlimit.hard", units.Format(hard)),
+				slog.String("memlimit.soft", units.Format(soft)),
+				slog.String("memlimit.reserved", units.Format(reserve)),
+				slog.String("memlimit.nongo", units.Format(result.NonGo)),
 				slog.Any("err", err),
 			)
-			return err
+			return result, err
 		}
 	}
 
 	cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Successfully obtained memory limits",
-		slog.Int64("memlimit.hard", hard),
-		slog.Int64("memlimit.soft", soft),
-		slog.Int64("memlimit.reserved", reserve),
+		slog.String("memlimit.hard", units.Format(hard)),
+		slog.String("memlimit.soft", units.Format(soft)),
+		slog.String("memlimit.reserved", units.Format(reserve)),
+		slog.String("memlimit.nongo", units.Format(result.NonGo)),
+		slog.String("memlimit.source", info.Source),
 	)
+	result.Max = detected.Max
+	result.High = detected.High
+	result.Reserve = reserve
 
-	switch {
-	// Both hard and soft memory limits are defined.
-	case hard > 0 && soft > 0:
-		// Check if hard - reserve is lower than soft.
-		if hard-reserve < soft {
-			limit = hard - reserve
-		} else {
-			limit = soft
-		}
-	// Only hard memory limit is specified.
-	case hard > 0:
-		limit = hard - reserve
-	// Only soft memory limit is specified.
-	case soft > 0:
-		limit = soft
-	}
-
-	// Set GOMEMLIMIT.
-	if limit > 0 {
-		if snapshot != limit {
-			cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Setting GOMEMLIMIT",
-				slog.String("GOMEMLIMIT", strconv.FormatInt(limit, 10)))
-			debug.SetMemoryLimit(limit)
-		} else {
-			cfg.logger.LogAttrs(ctx, slog.LevelInfo, "GOMEMLIMIT is already set",
-				slog.String("GOMEMLIMIT", env))
-		}
-	} else {
-		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Memory limits are not defined")
+	info.Reserve = reserve
+	limit, result.Reason, err = limitOf(cfg.policy, info)
+	if err != nil {
+		cfg.logger.LogAttrs(ctx, slog.LevelError, "Limit policy failed",
+			slog.Any("err", err),
+		)
+		return result, fmt.Errorf("memlimit: limit policy failed: %w", err)
+	}
+
+	if limit <= 0 {
+		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Memory limits are not defined",
+			slog.String("reason", result.Reason))
+		return result, nil
 	}
 
-	return nil
+	result.Value = limit
+	result.Source = SourceQuota
+	return result, nil
 }
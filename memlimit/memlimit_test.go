@@ -4,13 +4,16 @@
 package memlimit_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"log/slog"
 	"math"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tprasadtp/go-autotune/internal/shared"
 	"github.com/tprasadtp/go-autotune/internal/trampoline"
@@ -417,3 +420,258 @@ func TestConfigure(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigureModified(t *testing.T) {
+	tt := []struct {
+		name     string
+		preserve bool
+		expect   int64
+	}{
+		{
+			name:   "Override",
+			expect: 250 * shared.MiByte,
+		},
+		{
+			name:     "Preserve",
+			preserve: true,
+			expect:   500 * shared.MiByte,
+		},
+	}
+	t.Cleanup(reset) // avoid side effects in other tests.
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+			t.Setenv("GOMEMLIMIT", "")
+
+			// Emulate another package modifying GOMEMLIMIT.
+			debug.SetMemoryLimit(500 * shared.MiByte)
+			if !memlimit.IsModified() {
+				t.Fatalf("expected IsModified to be true")
+			}
+
+			err := memlimit.Configure(context.Background(),
+				memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				memlimit.WithPreserveExternal(tc.preserve),
+				memlimit.WithMemoryQuotaDetector(
+					memlimit.MemoryQuotaDetectorFunc(
+						func(_ context.Context) (int64, int64, error) {
+							return 0, 250 * shared.MiByte, nil
+						},
+					),
+				),
+			)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+
+			if v := memlimit.Current(); v != tc.expect {
+				t.Errorf("GOMEMLIMIT expected=%d, got=%d", tc.expect, v)
+			}
+
+			// Values set by Configure are not considered as modified.
+			if !tc.preserve && memlimit.IsModified() {
+				t.Errorf("expected IsModified to be false after Configure")
+			}
+		})
+	}
+}
+
+func TestApplyUnchanged(t *testing.T) {
+	t.Cleanup(reset)
+	t.Setenv("GOMEMLIMIT", "")
+
+	// Emulate another package setting GOMEMLIMIT to the value Apply would set.
+	debug.SetMemoryLimit(250 * shared.MiByte)
+	detector := memlimit.MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+		return 0, 250 * shared.MiByte, nil
+	})
+
+	// Unchanged values are logged at info level only once, as [memlimit.Watch]
+	// applies periodically.
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	for i, preserve := range []bool{false, true, false} {
+		result, err := memlimit.Apply(context.Background(),
+			memlimit.WithLogger(logger),
+			memlimit.WithPreserveExternal(preserve),
+			memlimit.WithMemoryQuotaDetector(detector),
+		)
+		if err != nil {
+			t.Fatalf("apply=%d expected no error, got %s", i, err)
+		}
+
+		if result.Source != memlimit.SourceQuota || result.Value != 250*shared.MiByte {
+			t.Errorf("apply=%d expected source=%s, value=%d, got=%+v",
+				i, memlimit.SourceQuota, 250*shared.MiByte, result)
+		}
+
+		// Unchanged values are recorded as applied.
+		if memlimit.IsModified() {
+			t.Errorf("apply=%d expected IsModified to be false", i)
+		}
+	}
+
+	if v := strings.Count(buf.String(), `level=INFO msg="GOMEMLIMIT is already set"`); v > 1 {
+		t.Errorf("expected at most one info log, got=%d\n%s", v, buf.String())
+	}
+
+	if v := strings.Count(buf.String(), `level=DEBUG msg="GOMEMLIMIT is already set"`); v < 2 {
+		t.Errorf("expected at least two debug logs, got=%d\n%s", v, buf.String())
+	}
+}
+
+func TestApply(t *testing.T) {
+	tt := []struct {
+		name   string
+		env    string
+		max    int64
+		high   int64
+		expect memlimit.Result
+	}{
+		{
+			name: "Default",
+			expect: memlimit.Result{
+				Previous: math.MaxInt64,
+				Value:    math.MaxInt64,
+				Source:   memlimit.SourceDefault,
+			},
+		},
+		{
+			name: "Env",
+			env:  "250MiB",
+			expect: memlimit.Result{
+				Previous: math.MaxInt64,
+				Value:    250 * shared.MiByte,
+				Source:   memlimit.SourceEnv,
+			},
+		},
+		{
+			name: "Quota",
+			max:  250 * shared.MiByte,
+			high: 300 * shared.MiByte,
+			expect: memlimit.Result{
+				Previous: math.MaxInt64,
+				Value:    225 * shared.MiByte,
+				Max:      250 * shared.MiByte,
+				High:     300 * shared.MiByte,
+				Reserve:  25 * shared.MiByte,
+				Source:   memlimit.SourceQuota,
+				Reason:   "max minus reserve is lower than high",
+			},
+		},
+	}
+	t.Cleanup(reset) // avoid side effects in other tests.
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+			t.Setenv("GOMEMLIMIT", tc.env)
+
+			result, err := memlimit.Apply(context.Background(),
+				memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				memlimit.WithMemoryQuotaDetector(
+					memlimit.MemoryQuotaDetectorFunc(
+						func(_ context.Context) (int64, int64, error) {
+							return tc.max, tc.high, nil
+						},
+					),
+				),
+			)
+			if err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+
+			if result != tc.expect {
+				t.Errorf("expected=%+v, got=%+v", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestApplyFailures(t *testing.T) {
+	quota := memlimit.MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+		return 250 * shared.MiByte, 0, nil
+	})
+	tt := []struct {
+		name    string
+		timeout time.Duration
+		opts    []memlimit.Option
+		err     error
+	}{
+		{
+			name: "DetectorPanic",
+			opts: []memlimit.Option{
+				memlimit.WithMemoryQuotaDetector(memlimit.MemoryQuotaDetectorFunc(
+					func(context.Context) (int64, int64, error) {
+						panic("test: detector panic")
+					},
+				)),
+			},
+		},
+		{
+			name:    "DetectorHung",
+			timeout: 50 * time.Millisecond,
+			err:     context.DeadlineExceeded,
+			opts: []memlimit.Option{
+				memlimit.WithMemoryQuotaDetector(memlimit.MemoryQuotaDetectorFunc(
+					func(context.Context) (int64, int64, error) {
+						// Detector which ignores context.
+						time.Sleep(time.Minute)
+						return 250 * shared.MiByte, 0, nil
+					},
+				)),
+			},
+		},
+		{
+			name: "ReserveFuncPanic",
+			opts: []memlimit.Option{
+				memlimit.WithMemoryQuotaDetector(quota),
+				memlimit.WithReserveFunc(func(int64) int64 {
+					panic("test: reserve func panic")
+				}),
+			},
+		},
+		{
+			name: "LimitPolicyPanic",
+			opts: []memlimit.Option{
+				memlimit.WithMemoryQuotaDetector(quota),
+				memlimit.WithLimitPolicy(memlimit.LimitPolicyFunc(func(memlimit.MemoryInfo) (int64, string) {
+					panic("test: limit policy panic")
+				})),
+			},
+		},
+	}
+	t.Cleanup(reset)
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Cleanup(reset)
+			t.Setenv("GOMEMLIMIT", "")
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			opts := append([]memlimit.Option{
+				memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			}, tc.opts...)
+			result, err := memlimit.Apply(ctx, opts...)
+			if err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Errorf("expected error wrapping %q, got %q", tc.err, err)
+			}
+
+			if result.Value != math.MaxInt64 || memlimit.Current() != math.MaxInt64 {
+				t.Errorf("GOMEMLIMIT must be unchanged on error")
+			}
+		})
+	}
+}
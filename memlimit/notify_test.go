@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !windows
+
+package memlimit_test
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestApplyWithSystemdNotify(t *testing.T) {
+	t.Cleanup(reset)
+	t.Setenv("GOMEMLIMIT", "")
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	apply := func(limit int64) {
+		t.Helper()
+		_, err := memlimit.Apply(context.Background(),
+			memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			memlimit.WithSystemdNotify(true),
+			memlimit.WithMemoryQuotaDetector(
+				memlimit.MemoryQuotaDetectorFunc(
+					func(_ context.Context) (int64, int64, error) {
+						return limit, 0, nil
+					},
+				),
+			),
+		)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+
+	receive := func(expect string) {
+		t.Helper()
+		buf := make([]byte, 4096)
+		_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("failed to read: %s", err)
+		}
+
+		got := string(buf[:n])
+		if !strings.HasPrefix(got, "STATUS=") || !strings.Contains(got, expect) {
+			t.Errorf("expected status containing %q, got %q", expect, got)
+		}
+	}
+
+	apply(250 * shared.MiByte)
+	receive("GOMEMLIMIT=225MiB (quota)")
+
+	// Status is only sent again when it changes.
+	apply(250 * shared.MiByte)
+	apply(500 * shared.MiByte)
+	receive("GOMEMLIMIT=450MiB (quota)")
+}
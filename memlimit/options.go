@@ -6,7 +6,6 @@ package memlimit
 import (
 	"context"
 	"log/slog"
-	"math"
 
 	"github.com/tprasadtp/go-autotune/internal/quota"
 	"github.com/tprasadtp/go-autotune/internal/shared"
@@ -80,6 +79,54 @@ func WithReserveFunc(fn func(limit int64) (reserve int64)) Option {
 	return nil
 }
 
+// WithLimitPolicy configures the [LimitPolicy] used to compute GOMEMLIMIT from
+// memory limits. Policy receives [MemoryInfo] with reserve computed by reserve func
+// (see [WithReserveFunc]). By default, [DefaultLimitPolicy] is used. This has no
+// effect if GOMEMLIMIT environment variable is set.
+func WithLimitPolicy(policy LimitPolicy) Option {
+	if policy != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.policy = policy
+			},
+		}
+	}
+	return nil
+}
+
+// WithPreserveExternal leaves GOMEMLIMIT unchanged if it was modified by
+// another package like [github.com/KimMachineGun/automemlimit] or by calling
+// [runtime/debug.SetMemoryLimit] directly. See [IsModified] for more info.
+// By default, a warning is logged and GOMEMLIMIT is overridden.
+//
+// [github.com/KimMachineGun/automemlimit]: https://pkg.go.dev/github.com/KimMachineGun/automemlimit
+func WithPreserveExternal(preserve bool) Option {
+	if preserve {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.preserve = true
+			},
+		}
+	}
+	return nil
+}
+
+// WithSystemdNotify reports GOMEMLIMIT and its source to the service manager
+// via [sd_notify] protocol, which is shown by "systemctl status". This does
+// nothing if NOTIFY_SOCKET environment variable is not set.
+//
+// [sd_notify]: https://www.freedesktop.org/software/systemd/man/latest/sd_notify.html
+func WithSystemdNotify(notify bool) Option {
+	if notify {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.notify = true
+			},
+		}
+	}
+	return nil
+}
+
 // WithMemoryQuotaDetector can be used to replace default memory quota detection algorithm.
 //
 // This is an advanced option intended to be used to detect memory quota from non-standard
@@ -102,16 +149,9 @@ func DefaultMemoryQuotaDetector() MemoryQuotaDetector {
 	return &quota.Detector{}
 }
 
-// DefaultReserveFunc returns default [WithReserveFunc].
+// DefaultReserveFunc returns default [WithReserveFunc]. It reserves 10% of hard
+// memory limit, with a maximum of 100MiB. See [PercentReserve], [FixedReserve],
+// [TieredReserve] and [ParseReserve] for other reserve funcs.
 func DefaultReserveFunc() func(limit int64) (reserve int64) {
-	return func(i int64) int64 {
-		if i <= 0 {
-			return 0
-		}
-
-		if v := math.Floor(float64(i) * 0.1); v <= shared.MiByte*100 {
-			return int64(v)
-		}
-		return shared.MiByte * 100
-	}
+	return PercentReserve(10, 0, shared.MiByte*100)
 }
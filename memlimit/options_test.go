@@ -6,7 +6,10 @@ package memlimit
 import (
 	"context"
 	"log/slog"
+	"math"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/tprasadtp/go-autotune/internal/shared"
 	"github.com/tprasadtp/go-autotune/internal/trampoline"
@@ -50,6 +53,23 @@ func TestWithReservePercent(t *testing.T) {
 	})
 }
 
+func TestWithLimitPolicy(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		opt := WithLimitPolicy(nil)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithLimitPolicy(PreferMaxPolicy())
+		opt.apply(&cfg)
+		if cfg.policy == nil {
+			t.Errorf("expected non nil value for cfg.policy")
+		}
+	})
+}
+
 func TestWithLogger(t *testing.T) {
 	t.Run("Nil", func(t *testing.T) {
 		opt := WithLogger(nil)
@@ -112,3 +132,269 @@ func TestDefaultReserveFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestWithPreserveExternal(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithPreserveExternal(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithPreserveExternal(true)
+		opt.apply(&cfg)
+		if !cfg.preserve {
+			t.Errorf("expected preserve to be true")
+		}
+	})
+}
+
+func TestWithPressureHandler(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		opt := WithPressureHandler(nil)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithPressureHandler(func(context.Context) {})
+		opt.apply(&cfg)
+		if cfg.pressureHandler == nil {
+			t.Errorf("expected non nil value for cfg.pressureHandler")
+		}
+	})
+}
+
+func TestWithPressureLimit(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithPressureLimit(0, time.Second),
+			WithPressureLimit(1, time.Second),
+			WithPressureLimit(-0.5, time.Second),
+			WithPressureLimit(math.NaN(), time.Second),
+			WithPressureLimit(0.5, 0),
+			WithPressureLimit(0.5, -time.Second),
+		} {
+			if opt != nil {
+				t.Errorf("expected nil")
+			}
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithPressureLimit(0.8, time.Minute)
+		opt.apply(&cfg)
+		if cfg.pressureFactor != 0.8 || cfg.pressureCooldown != time.Minute {
+			t.Errorf("unexpected config factor=%f, cooldown=%s", cfg.pressureFactor, cfg.pressureCooldown)
+		}
+	})
+}
+
+func TestWithSystemdNotify(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithSystemdNotify(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithSystemdNotify(true)
+		opt.apply(&cfg)
+		if !cfg.notify {
+			t.Errorf("expected notify to be true")
+		}
+	})
+}
+
+func TestWithAdaptiveReserve(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithAdaptiveReserve(-1, shared.MiByte),
+			WithAdaptiveReserve(0, 0),
+			WithAdaptiveReserve(0, -1),
+			WithAdaptiveReserve(2*shared.MiByte, shared.MiByte),
+		} {
+			if opt != nil {
+				t.Errorf("expected nil")
+			}
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithAdaptiveReserve(shared.MiByte, 2*shared.MiByte)
+		opt.apply(&cfg)
+		if !cfg.adaptive || cfg.reserveMin != shared.MiByte || cfg.reserveMax != 2*shared.MiByte {
+			t.Errorf("unexpected config adaptive=%t, min=%d, max=%d",
+				cfg.adaptive, cfg.reserveMin, cfg.reserveMax)
+		}
+	})
+}
+
+func TestWithShare(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, v := range []float64{0, -0.5, 1, 1.5, math.NaN()} {
+			if opt := WithShare(v); opt != nil {
+				t.Errorf("expected nil for share=%f", v)
+			}
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithShare(0.5)
+		opt.apply(&cfg)
+		if cfg.share != 0.5 {
+			t.Errorf("expected share to be 0.5, got %f", cfg.share)
+		}
+	})
+}
+
+func TestWithProcessShare(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithProcessShare(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithProcessShare(true)
+		opt.apply(&cfg)
+		if !cfg.processShare {
+			t.Errorf("expected processShare to be true")
+		}
+	})
+}
+
+func TestWithExcludeOthers(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithExcludeOthers(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithExcludeOthers(true)
+		opt.apply(&cfg)
+		if !cfg.excludeOthers {
+			t.Errorf("expected excludeOthers to be true")
+		}
+	})
+}
+
+func TestWithGCGuard(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithGCGuard(0, 1),
+			WithGCGuard(1, 1),
+			WithGCGuard(math.NaN(), 1),
+			WithGCGuard(0.5, 0),
+		} {
+			if opt != nil {
+				t.Errorf("expected nil")
+			}
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithGCGuard(0.5, 2)
+		opt.apply(&cfg)
+		if cfg.gcThreshold != 0.5 || cfg.gcSamples != 2 {
+			t.Errorf("unexpected config threshold=%f, samples=%d", cfg.gcThreshold, cfg.gcSamples)
+		}
+	})
+}
+
+func TestWithGCRaiseLimit(t *testing.T) {
+	t.Run("Negative", func(t *testing.T) {
+		opt := WithGCRaiseLimit(-1)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithGCRaiseLimit(shared.MiByte)
+		opt.apply(&cfg)
+		if !cfg.gcRaise || cfg.gcFloor != shared.MiByte {
+			t.Errorf("unexpected config raise=%t, floor=%d", cfg.gcRaise, cfg.gcFloor)
+		}
+	})
+}
+
+func TestWithGCHandler(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		opt := WithGCHandler(nil)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithGCHandler(func(context.Context, GCStatus) {})
+		opt.apply(&cfg)
+		if cfg.gcHandler == nil {
+			t.Errorf("expected non nil value for cfg.gcHandler")
+		}
+	})
+}
+
+func TestWithProfileThresholds(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithProfileThresholds(),
+			WithProfileThresholds(0),
+			WithProfileThresholds(0.9, 1.1),
+			WithProfileThresholds(math.NaN()),
+		} {
+			if opt != nil {
+				t.Errorf("expected nil")
+			}
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithProfileThresholds(0.95, 0.8, 0.95, 1)
+		opt.apply(&cfg)
+		if !slices.Equal(cfg.profileThresholds, []float64{0.8, 0.95, 1}) {
+			t.Errorf("expected thresholds=[0.8 0.95 1], got=%v", cfg.profileThresholds)
+		}
+	})
+}
+
+func TestWithProfileRateLimit(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		opt := WithProfileRateLimit(0)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithProfileRateLimit(time.Hour)
+		opt.apply(&cfg)
+		if cfg.profileRateLimit != time.Hour {
+			t.Errorf("expected rate limit=1h, got=%s", cfg.profileRateLimit)
+		}
+	})
+}
+
+func TestWithProfileRetention(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		opt := WithProfileRetention(0)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		opt := WithProfileRetention(3)
+		opt.apply(&cfg)
+		if cfg.profileRetention != 3 {
+			t.Errorf("expected retention=3, got=%d", cfg.profileRetention)
+		}
+	})
+}
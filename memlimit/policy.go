@@ -0,0 +1,245 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"math"
+
+	"github.com/tprasadtp/go-autotune/internal/quota"
+	"github.com/tprasadtp/go-autotune/internal/shared"
+)
+
+var _ LimitPolicy = (*LimitPolicyFunc)(nil)
+
+// MemoryInfo describes memory limits and usage of the workload, which is used by
+// [LimitPolicy] to compute GOMEMLIMIT. All values are in bytes and zero indicates
+// not defined or not supported by the platform.
+type MemoryInfo struct {
+	// Hard memory limit, like memory.max on Linux.
+	Max int64 `json:"max,omitempty"`
+
+	// Soft memory limit, like memory.high on Linux.
+	High int64 `json:"high,omitempty"`
+
+	// Best effort memory protection, like memory.low on Linux.
+	Low int64 `json:"low,omitempty"`
+
+	// Hard memory protection, like memory.min on Linux.
+	Min int64 `json:"min,omitempty"`
+
+	// Swap limit, like memory.swap.max on Linux.
+	SwapMax int64 `json:"swap_max,omitempty"`
+
+	// Memory set aside as reserved, computed from hard memory limit with
+	// reserve func. See [WithReserveFunc]. Detectors need not set it.
+	Reserve int64 `json:"reserve,omitempty"`
+
+	// Current memory usage of the workload.
+	Current int64 `json:"current,omitempty"`
+
+	// Peak memory usage of the workload.
+	Peak int64 `json:"peak,omitempty"`
+
+	// Anonymous memory usage of the workload, like anon in memory.stat on Linux.
+	Anon int64 `json:"anon,omitempty"`
+
+	// Page cache usage of the workload, like file in memory.stat on Linux.
+	File int64 `json:"file,omitempty"`
+
+	// Kernel memory usage of the workload, like kernel in memory.stat on Linux.
+	Kernel int64 `json:"kernel,omitempty"`
+
+	// Network socket buffer usage of the workload, like sock in memory.stat on Linux.
+	Sock int64 `json:"sock,omitempty"`
+
+	// Number of processes sharing memory limits, like number of processes in
+	// cgroup.procs on Linux. Detectors need not set it, if they implement
+	// DetectProcs method (see [WithProcessShare]). Zero if unknown.
+	Procs int `json:"procs,omitempty"`
+
+	// Source of the memory info, for example cgroup or jobobject.
+	// Info obtained from a [MemoryQuotaDetector] which does not implement
+	// [MemoryInfoDetector] has source detector.
+	Source string `json:"source,omitempty"`
+}
+
+// MemoryInfoDetector is a [MemoryQuotaDetector] which can also detect
+// [MemoryInfo]. If detector specified via [WithMemoryQuotaDetector] implements
+// this interface, it is used to obtain memory info for the [LimitPolicy].
+type MemoryInfoDetector interface {
+	MemoryQuotaDetector
+	DetectMemoryInfo(ctx context.Context) (MemoryInfo, error)
+}
+
+// LimitPolicy computes GOMEMLIMIT from memory info. It returns limit and a
+// short human readable reason describing how it was computed, which is
+// logged and included in [Result]. If returned limit is zero or negative,
+// GOMEMLIMIT is left unchanged.
+type LimitPolicy interface {
+	Limit(info MemoryInfo) (limit int64, reason string)
+}
+
+// LimitPolicyFunc is an adapter to allow the use of ordinary functions as
+// [LimitPolicy]. If f is a function with the appropriate signature,
+// LimitPolicyFunc(f) is a [LimitPolicy] that calls f.
+type LimitPolicyFunc func(info MemoryInfo) (limit int64, reason string)
+
+// Limit implements [LimitPolicy] interface.
+//
+//nolint:nonamedreturns // for docs.
+func (fn LimitPolicyFunc) Limit(info MemoryInfo) (limit int64, reason string) {
+	return fn(info)
+}
+
+// DefaultLimitPolicy returns default [LimitPolicy]. It uses the lower of soft
+// memory limit and hard memory limit minus reserve. See [Configure] for more info.
+func DefaultLimitPolicy() LimitPolicy {
+	return LimitPolicyFunc(func(info MemoryInfo) (int64, string) {
+		switch {
+		// Both hard and soft memory limits are defined.
+		case info.Max > 0 && info.High > 0:
+			// Check if hard - reserve is lower than soft.
+			if info.Max-info.Reserve < info.High {
+				return info.Max - info.Reserve, "max minus reserve is lower than high"
+			}
+			return info.High, "high is lower than max minus reserve"
+		// Only hard memory limit is specified.
+		case info.Max > 0:
+			return info.Max - info.Reserve, "max minus reserve"
+		// Only soft memory limit is specified.
+		case info.High > 0:
+			return info.High, "high"
+		}
+		return 0, "memory limits are not defined"
+	})
+}
+
+// PreferMaxPolicy returns a [LimitPolicy] which uses hard memory limit minus
+// reserve, ignoring soft memory limit. If hard memory limit is not defined,
+// soft memory limit is used.
+func PreferMaxPolicy() LimitPolicy {
+	return LimitPolicyFunc(func(info MemoryInfo) (int64, string) {
+		switch {
+		case info.Max > 0:
+			return info.Max - info.Reserve, "max minus reserve"
+		case info.High > 0:
+			return info.High, "high, as max is not defined"
+		}
+		return 0, "memory limits are not defined"
+	})
+}
+
+// PreferHighPolicy returns a [LimitPolicy] which uses soft memory limit, even
+// if it is higher than hard memory limit minus reserve. If soft memory limit is
+// not defined, hard memory limit minus reserve is used.
+func PreferHighPolicy() LimitPolicy {
+	return LimitPolicyFunc(func(info MemoryInfo) (int64, string) {
+		switch {
+		case info.High > 0:
+			return info.High, "high"
+		case info.Max > 0:
+			return info.Max - info.Reserve, "max minus reserve, as high is not defined"
+		}
+		return 0, "memory limits are not defined"
+	})
+}
+
+// FractionOfMaxPolicy returns a [LimitPolicy] which uses fraction of hard
+// memory limit, for example 0.8 for 80%. Reserve and soft memory limit are ignored.
+// If fraction is not in range (0, 1], nil is returned, which when used with
+// [WithLimitPolicy] is a no-op.
+func FractionOfMaxPolicy(fraction float64) LimitPolicy {
+	if math.IsNaN(fraction) || fraction <= 0 || fraction > 1 {
+		return nil
+	}
+
+	return LimitPolicyFunc(func(info MemoryInfo) (int64, string) {
+		if info.Max > 0 {
+			return int64(math.Floor(float64(info.Max) * fraction)), "fraction of max"
+		}
+		return 0, "max is not defined"
+	})
+}
+
+// HighMinusHeadroomPolicy returns a [LimitPolicy] which uses soft memory limit
+// minus fixed headroom bytes, but never more than hard memory limit minus reserve.
+// If soft memory limit is not defined, hard memory limit minus reserve is used.
+// If headroom is negative, nil is returned, which when used with [WithLimitPolicy]
+// is a no-op.
+func HighMinusHeadroomPolicy(headroom int64) LimitPolicy {
+	if headroom < 0 {
+		return nil
+	}
+
+	return LimitPolicyFunc(func(info MemoryInfo) (int64, string) {
+		switch {
+		case info.High > 0:
+			limit := info.High - headroom
+			if info.Max > 0 && info.Max-info.Reserve < limit {
+				return info.Max - info.Reserve, "max minus reserve is lower than high minus headroom"
+			}
+			return limit, "high minus headroom"
+		case info.Max > 0:
+			return info.Max - info.Reserve, "max minus reserve, as high is not defined"
+		}
+		return 0, "memory limits are not defined"
+	})
+}
+
+// limitOf calls policy, recovering from panics.
+//
+//nolint:nonamedreturns // required to recover from panics.
+func limitOf(policy LimitPolicy, info MemoryInfo) (limit int64, reason string, err error) {
+	defer shared.Recover(&err)
+	limit, reason = policy.Limit(info)
+	return limit, reason, nil
+}
+
+// reserveOf calls reserve function fn, recovering from panics.
+//
+//nolint:nonamedreturns // required to recover from panics.
+func reserveOf(fn func(int64) int64, hard int64) (reserve int64, err error) {
+	defer shared.Recover(&err)
+	return fn(hard), nil
+}
+
+// quotaInfoDetector is implemented by detectors in package quota.
+type quotaInfoDetector interface {
+	DetectMemoryInfo(ctx context.Context) (quota.MemoryInfo, error)
+}
+
+// detectMemoryInfo detects memory info using detector. If detector does not
+// implement [MemoryInfoDetector], only hard and soft memory limits are populated.
+func detectMemoryInfo(ctx context.Context, detector MemoryQuotaDetector) (MemoryInfo, error) {
+	switch d := detector.(type) {
+	case MemoryInfoDetector:
+		return d.DetectMemoryInfo(ctx)
+	case quotaInfoDetector:
+		info, err := d.DetectMemoryInfo(ctx)
+		if err != nil {
+			return MemoryInfo{}, err
+		}
+		return MemoryInfo{
+			Max:     info.Max,
+			High:    info.High,
+			Low:     info.Low,
+			Min:     info.Min,
+			SwapMax: info.SwapMax,
+			Current: info.Current,
+			Peak:    info.Peak,
+			Anon:    info.Anon,
+			File:    info.File,
+			Kernel:  info.Kernel,
+			Sock:    info.Sock,
+			Source:  info.Source,
+		}, nil
+	}
+
+	max, high, err := detector.DetectMemoryQuota(ctx)
+	if err != nil {
+		return MemoryInfo{}, err
+	}
+	return MemoryInfo{Max: max, High: high, Source: "detector"}, nil
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit_test
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+type infoDetector memlimit.MemoryInfo
+
+func (d infoDetector) DetectMemoryQuota(_ context.Context) (int64, int64, error) {
+	return d.Max, d.High, nil
+}
+
+func (d infoDetector) DetectMemoryInfo(_ context.Context) (memlimit.MemoryInfo, error) {
+	return memlimit.MemoryInfo(d), nil
+}
+
+func TestLimitPolicy(t *testing.T) {
+	both := memlimit.MemoryInfo{Max: 1000, High: 800, Reserve: 100}
+	maxOnly := memlimit.MemoryInfo{Max: 1000, Reserve: 100}
+	highOnly := memlimit.MemoryInfo{High: 800}
+	tt := []struct {
+		name   string
+		policy memlimit.LimitPolicy
+		info   memlimit.MemoryInfo
+		expect int64
+	}{
+		{name: "Default/None", policy: memlimit.DefaultLimitPolicy()},
+		{name: "Default/Both", policy: memlimit.DefaultLimitPolicy(), info: both, expect: 800},
+		{
+			name:   "Default/BothMaxLower",
+			policy: memlimit.DefaultLimitPolicy(),
+			info:   memlimit.MemoryInfo{Max: 1000, High: 950, Reserve: 100},
+			expect: 900,
+		},
+		{name: "Default/Max", policy: memlimit.DefaultLimitPolicy(), info: maxOnly, expect: 900},
+		{name: "Default/High", policy: memlimit.DefaultLimitPolicy(), info: highOnly, expect: 800},
+		{name: "PreferMax/None", policy: memlimit.PreferMaxPolicy()},
+		{name: "PreferMax/Both", policy: memlimit.PreferMaxPolicy(), info: both, expect: 900},
+		{name: "PreferMax/High", policy: memlimit.PreferMaxPolicy(), info: highOnly, expect: 800},
+		{name: "PreferHigh/None", policy: memlimit.PreferHighPolicy()},
+		{
+			name:   "PreferHigh/Both",
+			policy: memlimit.PreferHighPolicy(),
+			info:   memlimit.MemoryInfo{Max: 1000, High: 950, Reserve: 100},
+			expect: 950,
+		},
+		{name: "PreferHigh/Max", policy: memlimit.PreferHighPolicy(), info: maxOnly, expect: 900},
+		{name: "FractionOfMax/Both", policy: memlimit.FractionOfMaxPolicy(0.75), info: both, expect: 750},
+		{name: "FractionOfMax/High", policy: memlimit.FractionOfMaxPolicy(0.75), info: highOnly},
+		{name: "HighMinusHeadroom/None", policy: memlimit.HighMinusHeadroomPolicy(50)},
+		{name: "HighMinusHeadroom/Both", policy: memlimit.HighMinusHeadroomPolicy(50), info: both, expect: 750},
+		{
+			name:   "HighMinusHeadroom/MaxLower",
+			policy: memlimit.HighMinusHeadroomPolicy(50),
+			info:   memlimit.MemoryInfo{Max: 1000, High: 1000, Reserve: 100},
+			expect: 900,
+		},
+		{name: "HighMinusHeadroom/Max", policy: memlimit.HighMinusHeadroomPolicy(50), info: maxOnly, expect: 900},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			limit, reason := tc.policy.Limit(tc.info)
+			if limit != tc.expect {
+				t.Errorf("expected=%d, got=%d", tc.expect, limit)
+			}
+
+			if reason == "" {
+				t.Errorf("expected non empty reason")
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, policy := range []memlimit.LimitPolicy{
+			memlimit.FractionOfMaxPolicy(0),
+			memlimit.FractionOfMaxPolicy(-0.5),
+			memlimit.FractionOfMaxPolicy(1.5),
+			memlimit.FractionOfMaxPolicy(math.NaN()),
+			memlimit.HighMinusHeadroomPolicy(-1),
+		} {
+			if policy != nil {
+				t.Errorf("expected nil policy")
+			}
+		}
+	})
+}
+
+func TestComputeWithLimitPolicy(t *testing.T) {
+	t.Setenv("GOMEMLIMIT", "")
+	var got memlimit.MemoryInfo
+	result, err := memlimit.Compute(context.Background(),
+		memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+		memlimit.WithMemoryQuotaDetector(infoDetector{
+			Max:     250 * shared.MiByte,
+			High:    200 * shared.MiByte,
+			Low:     50 * shared.MiByte,
+			Current: 100 * shared.MiByte,
+			Source:  "test",
+		}),
+		memlimit.WithReserveFunc(func(int64) int64 {
+			return 10 * shared.MiByte
+		}),
+		memlimit.WithLimitPolicy(memlimit.LimitPolicyFunc(func(info memlimit.MemoryInfo) (int64, string) {
+			got = info
+			return info.Current * 2, "twice current"
+		})),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	expect := memlimit.MemoryInfo{
+		Max:     250 * shared.MiByte,
+		High:    200 * shared.MiByte,
+		Low:     50 * shared.MiByte,
+		Reserve: 10 * shared.MiByte,
+		Current: 100 * shared.MiByte,
+		Source:  "test",
+	}
+	if got != expect {
+		t.Errorf("info expected=%+v, got=%+v", expect, got)
+	}
+
+	if result.Value != 200*shared.MiByte || result.Reason != "twice current" ||
+		result.Source != memlimit.SourceQuota {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime/debug"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+// WatchPressure implements systemd [memory pressure] protocol and blocks until
+// ctx is cancelled or an error occurs.
+//
+// systemd 254 or later sets MEMORY_PRESSURE_WATCH and MEMORY_PRESSURE_WRITE
+// environment variables for services with [MemoryPressureWatch=] enabled.
+// MEMORY_PRESSURE_WATCH is the path to watch (typically memory.pressure file
+// of the service's cgroup, but it may also be a FIFO or a unix socket) and
+// MEMORY_PRESSURE_WRITE is base64 encoded trigger which is written to it.
+//
+// On each memory pressure event, handler specified via [WithPressureHandler]
+// is called, which can be used to release caches, and then garbage collection
+// is forced and memory is returned to the operating system with
+// [runtime/debug.FreeOSMemory]. If [WithPressureLimit] is specified,
+// GOMEMLIMIT is also lowered temporarily.
+//
+// If MEMORY_PRESSURE_WATCH is not set or is /dev/null, or platform is not
+// supported, returned error wraps [errors.ErrUnsupported]. Only [WithLogger],
+// [WithPressureHandler], [WithPressureLimit] and [WithSystemdNotify] options
+// are used. With [WithSystemdNotify], changes to GOMEMLIMIT are reported to
+// the service manager.
+//
+// [memory pressure]: https://systemd.io/MEMORY_PRESSURE/
+// [MemoryPressureWatch=]: https://www.freedesktop.org/software/systemd/man/latest/systemd.resource-control.html#MemoryPressureWatch=
+func WatchPressure(ctx context.Context, opts ...Option) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return watchPressure(ctx, newConfig(opts...))
+}
+
+// WithPressureHandler configures a handler which is called on each memory
+// pressure event, before forcing garbage collection. Handler runs on the
+// goroutine running [WatchPressure], and must not block for long.
+func WithPressureHandler(fn func(ctx context.Context)) Option {
+	if fn != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.pressureHandler = fn
+			},
+		}
+	}
+	return nil
+}
+
+// WithPressureLimit lowers GOMEMLIMIT to factor of its current value on
+// memory pressure events, for example 0.9 to lower it by 10%. This makes
+// garbage collector more aggressive while under memory pressure. GOMEMLIMIT
+// is restored once no memory pressure events are received for cooldown duration,
+// unless it was modified in the meantime. GOMEMLIMIT is not lowered if it is not set.
+//
+// If factor is not in range (0, 1) or cooldown is not positive, nil is returned,
+// which is a no-op.
+func WithPressureLimit(factor float64, cooldown time.Duration) Option {
+	if math.IsNaN(factor) || factor <= 0 || factor >= 1 || cooldown <= 0 {
+		return nil
+	}
+
+	return &optionFunc{
+		fn: func(c *config) {
+			c.pressureFactor = factor
+			c.pressureCooldown = cooldown
+		},
+	}
+}
+
+// pressureState tracks GOMEMLIMIT lowered due to memory pressure.
+type pressureState struct {
+	cfg      *config
+	previous int64
+	lowered  int64
+	deadline time.Time
+}
+
+// handle handles a memory pressure event.
+func (s *pressureState) handle(ctx context.Context) {
+	s.cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Received memory pressure event")
+	if s.cfg.pressureHandler != nil {
+		s.cfg.pressureHandler(ctx)
+	}
+
+	// This forces garbage collection.
+	debug.FreeOSMemory()
+
+	if s.cfg.pressureFactor <= 0 {
+		return
+	}
+
+	// Extend cooldown if already lowered.
+	s.deadline = time.Now().Add(s.cfg.pressureCooldown)
+	if s.lowered != 0 {
+		return
+	}
+
+	current := Current()
+	if current == math.MaxInt64 {
+		return
+	}
+
+	s.previous = current
+	s.lowered = int64(float64(current) * s.cfg.pressureFactor)
+	s.cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Lowering GOMEMLIMIT due to memory pressure",
+		slog.String("GOMEMLIMIT", units.Format(s.lowered)),
+		slog.Duration("cooldown", s.cfg.pressureCooldown),
+	)
+	set(s.lowered)
+	if s.cfg.notify {
+		notify(ctx, s.cfg, s.lowered, "memory pressure")
+	}
+}
+
+// timeout returns duration until GOMEMLIMIT should be restored.
+// Negative value indicates GOMEMLIMIT is not lowered.
+func (s *pressureState) timeout() time.Duration {
+	if s.lowered == 0 {
+		return -1
+	}
+	return max(time.Until(s.deadline), 0)
+}
+
+// restore restores GOMEMLIMIT if it was lowered and was not modified since.
+func (s *pressureState) restore(ctx context.Context) {
+	if s.lowered == 0 {
+		return
+	}
+
+	if Current() == s.lowered {
+		s.cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Restoring GOMEMLIMIT after memory pressure",
+			slog.String("GOMEMLIMIT", units.Format(s.previous)),
+		)
+		set(s.previous)
+		if s.cfg.notify {
+			notify(ctx, s.cfg, s.previous, "restored after memory pressure")
+		}
+	} else {
+		s.cfg.logger.LogAttrs(ctx, slog.LevelWarn,
+			"GOMEMLIMIT was modified while under memory pressure, leaving it unchanged",
+			slog.String("GOMEMLIMIT", units.Format(Current())),
+		)
+	}
+	s.lowered = 0
+	s.previous = 0
+}
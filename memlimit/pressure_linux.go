@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package memlimit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// pressureWatch is an open memory pressure watch.
+type pressureWatch struct {
+	fd     int
+	events int16
+	stream bool
+}
+
+// openPressure opens memory pressure watch path specified by
+// MEMORY_PRESSURE_WATCH and writes trigger specified by MEMORY_PRESSURE_WRITE.
+func openPressure(path string, trigger []byte) (*pressureWatch, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("memlimit: failed to stat memory pressure watch: %w", err)
+	}
+
+	w := &pressureWatch{fd: -1}
+	switch {
+	case info.Mode()&fs.ModeSocket != 0:
+		// Like sd-event, try stream sockets and fallback to seqpacket sockets.
+		for _, typ := range []int{unix.SOCK_STREAM, unix.SOCK_SEQPACKET} {
+			w.fd, err = unix.Socket(unix.AF_UNIX, typ|unix.SOCK_CLOEXEC, 0)
+			if err != nil {
+				break
+			}
+
+			err = unix.Connect(w.fd, &unix.SockaddrUnix{Name: path})
+			if err == nil {
+				break
+			}
+
+			_ = unix.Close(w.fd)
+			w.fd = -1
+			if !errors.Is(err, unix.EPROTOTYPE) {
+				break
+			}
+		}
+		w.events = unix.POLLIN
+		w.stream = true
+	case info.Mode()&fs.ModeNamedPipe != 0:
+		// FIFO is opened for writing too, to avoid POLLHUP when there are no
+		// writers. Trigger is not written to FIFO, as it would be read back.
+		w.fd, err = unix.Open(path, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		w.events = unix.POLLIN
+		trigger = nil
+	default:
+		// PSI files require write access to register a trigger.
+		w.fd, err = unix.Open(path, unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		w.events = unix.POLLPRI
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("memlimit: failed to open memory pressure watch(%s): %w", path, err)
+	}
+
+	// Trigger must be written in a single write.
+	if len(trigger) > 0 {
+		_, err = unix.Write(w.fd, trigger)
+		if err != nil {
+			_ = unix.Close(w.fd)
+			return nil, fmt.Errorf("memlimit: failed to write memory pressure trigger(%s): %w", path, err)
+		}
+	}
+	return w, nil
+}
+
+// wait waits for a memory pressure event. It returns false if timeout expires
+// or efd is readable.
+func (w *pressureWatch) wait(efd int, timeout time.Duration, buf []byte) (bool, error) {
+	ms := -1
+	if timeout >= 0 {
+		ms = int(timeout.Round(time.Millisecond).Milliseconds())
+	}
+
+	fds := []unix.PollFd{
+		{Fd: int32(w.fd), Events: w.events},
+		{Fd: int32(efd), Events: unix.POLLIN},
+	}
+
+	for {
+		_, err := unix.Poll(fds, ms)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("memlimit: failed to poll memory pressure watch: %w", err)
+		}
+		break
+	}
+
+	if fds[1].Revents != 0 {
+		return false, nil
+	}
+
+	revents := fds[0].Revents
+	switch {
+	case revents == 0:
+		return false, nil
+	case revents&unix.POLLERR != 0:
+		return false, errors.New("memlimit: memory pressure watch returned error")
+	case w.events == unix.POLLPRI:
+		return true, nil
+	}
+
+	// Contents of the message are not defined, thus are discarded.
+	n, err := unix.Read(w.fd, buf)
+	switch {
+	case errors.Is(err, unix.EAGAIN):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("memlimit: failed to read memory pressure watch: %w", err)
+	case n == 0 && w.stream:
+		return false, errors.New("memlimit: memory pressure watch socket is closed")
+	}
+	return n > 0, nil
+}
+
+func watchPressure(ctx context.Context, cfg *config) error {
+	path := os.Getenv("MEMORY_PRESSURE_WATCH")
+	if path == "" || path == os.DevNull {
+		return fmt.Errorf("memlimit: memory pressure watch is not configured: %w", errors.ErrUnsupported)
+	}
+
+	trigger, err := base64.StdEncoding.DecodeString(os.Getenv("MEMORY_PRESSURE_WRITE"))
+	if err != nil {
+		return fmt.Errorf("memlimit: invalid MEMORY_PRESSURE_WRITE: %w", err)
+	}
+
+	w, err := openPressure(path, trigger)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(w.fd)
+
+	// eventfd is used to wake up poll when context is cancelled.
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("memlimit: failed to create eventfd: %w", err)
+	}
+	defer unix.Close(efd)
+
+	stop := context.AfterFunc(ctx, func() {
+		_, _ = unix.Write(efd, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	})
+	defer stop()
+
+	state := &pressureState{cfg: cfg}
+	defer state.restore(context.WithoutCancel(ctx))
+
+	cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Watching for memory pressure events",
+		slog.String("MEMORY_PRESSURE_WATCH", path),
+	)
+
+	buf := make([]byte, 4096)
+	for {
+		timeout := state.timeout()
+		event, err := w.wait(efd, timeout, buf)
+		switch {
+		case err != nil:
+			return err
+		case ctx.Err() != nil:
+			return fmt.Errorf("memlimit: %w", ctx.Err())
+		case event:
+			state.handle(ctx)
+		case timeout >= 0 && state.timeout() == 0:
+			state.restore(ctx)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package memlimit_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestWatchPressureNotConfigured(t *testing.T) {
+	for _, v := range []string{"", os.DevNull} {
+		t.Run(v, func(t *testing.T) {
+			t.Setenv("MEMORY_PRESSURE_WATCH", v)
+			err := memlimit.WatchPressure(context.Background())
+			if !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("expected error wrapping ErrUnsupported, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWatchPressureFIFO(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pressure")
+	if err := unix.Mkfifo(path, 0o600); err != nil {
+		t.Fatalf("failed to create fifo: %s", err)
+	}
+	t.Setenv("MEMORY_PRESSURE_WATCH", path)
+	t.Setenv("MEMORY_PRESSURE_WRITE", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan struct{}, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- memlimit.WatchPressure(ctx,
+			memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			memlimit.WithPressureHandler(func(context.Context) {
+				events <- struct{}{}
+			}),
+		)
+	}()
+
+	// Opening FIFO for writing blocks until watcher opens it.
+	writer, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open fifo: %s", err)
+	}
+
+	for i := range 2 {
+		if _, err = writer.Write([]byte{1}); err != nil {
+			t.Fatalf("failed to write to fifo: %s", err)
+		}
+
+		select {
+		case <-events:
+		case err = <-errCh:
+			t.Fatalf("watcher returned early: %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timeout waiting for pressure event %d", i)
+		}
+	}
+
+	// Watcher must continue when there are no writers.
+	_ = writer.Close()
+	select {
+	case err = <-errCh:
+		t.Fatalf("watcher returned after writer was closed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	err = <-errCh
+	if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "memlimit: ") {
+		t.Errorf("expected context.Canceled with memlimit prefix, got %v", err)
+	}
+}
+
+func TestWatchPressureSocket(t *testing.T) {
+	orig := debug.SetMemoryLimit(shared.GiByte)
+	t.Cleanup(func() {
+		debug.SetMemoryLimit(orig)
+	})
+
+	path := filepath.Join(t.TempDir(), "pressure.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	trigger := "some 150000 1000000\x00"
+	t.Setenv("MEMORY_PRESSURE_WATCH", path)
+	t.Setenv("MEMORY_PRESSURE_WRITE", base64.StdEncoding.EncodeToString([]byte(trigger)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan int64, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- memlimit.WatchPressure(ctx,
+			memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			memlimit.WithPressureLimit(0.5, 250*time.Millisecond),
+			memlimit.WithPressureHandler(func(context.Context) {
+				events <- memlimit.Current()
+			}),
+		)
+	}()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %s", err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, len(trigger))
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("failed to read trigger: %s", err)
+	}
+
+	if string(buf) != trigger {
+		t.Errorf("expected trigger=%q, got=%q", trigger, buf)
+	}
+
+	if _, err = conn.Write([]byte{1}); err != nil {
+		t.Fatalf("failed to write event: %s", err)
+	}
+
+	select {
+	case <-events:
+	case err = <-errCh:
+		t.Fatalf("watcher returned early: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout waiting for pressure event")
+	}
+
+	// Handler runs before GOMEMLIMIT is lowered.
+	deadline := time.Now().Add(10 * time.Second)
+	for memlimit.Current() != shared.GiByte/2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected GOMEMLIMIT=%d, got=%d", shared.GiByte/2, memlimit.Current())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// GOMEMLIMIT is restored after cooldown.
+	for memlimit.Current() != shared.GiByte {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected GOMEMLIMIT=%d, got=%d", shared.GiByte, memlimit.Current())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Watcher returns an error when peer closes the connection.
+	_ = conn.Close()
+	select {
+	case err = <-errCh:
+		if err == nil || errors.Is(err, context.Canceled) {
+			t.Errorf("expected error when socket is closed, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout waiting for watcher to return")
+	}
+}
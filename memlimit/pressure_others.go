@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package memlimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func watchPressure(_ context.Context, _ *config) error {
+	return fmt.Errorf("memlimit: memory pressure watch is not supported: %w", errors.ErrUnsupported)
+}
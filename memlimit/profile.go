@@ -0,0 +1,368 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+// Default profile capture settings used by [WatchProfiles], if [WithProfileRateLimit]
+// or [WithProfileRetention] is not specified.
+const (
+	DefaultProfileRateLimit = time.Minute
+	DefaultProfileRetention = 5
+)
+
+// WithProfileThresholds configures thresholds, as fraction of memory limit, at
+// which [WatchProfiles] captures profiles, for example 0.8 and 0.95 to capture
+// profiles when memory usage reaches 80% and 95% of memory limit. If not
+// specified, 0.9 and 0.95 are used. If no thresholds are specified or any of
+// them is not in range (0, 1], nil is returned, which is a no-op.
+func WithProfileThresholds(thresholds ...float64) Option {
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	for _, v := range thresholds {
+		if math.IsNaN(v) || v <= 0 || v > 1 {
+			return nil
+		}
+	}
+
+	thresholds = slices.Clone(thresholds)
+	slices.Sort(thresholds)
+	thresholds = slices.Compact(thresholds)
+	return &optionFunc{
+		fn: func(c *config) {
+			c.profileThresholds = thresholds
+		},
+	}
+}
+
+// WithProfileRateLimit configures minimum duration between profiles captured by
+// [WatchProfiles]. If not specified, [DefaultProfileRateLimit] is used. If d is
+// not positive, nil is returned, which is a no-op.
+func WithProfileRateLimit(d time.Duration) Option {
+	if d <= 0 {
+		return nil
+	}
+
+	return &optionFunc{
+		fn: func(c *config) {
+			c.profileRateLimit = d
+		},
+	}
+}
+
+// WithProfileRetention configures number of profiles of each kind retained by
+// [WatchProfiles]. Older profiles are removed after each capture. If not specified,
+// [DefaultProfileRetention] is used. If n is not positive, nil is returned,
+// which is a no-op.
+func WithProfileRetention(n int) Option {
+	if n <= 0 {
+		return nil
+	}
+
+	return &optionFunc{
+		fn: func(c *config) {
+			c.profileRetention = n
+		},
+	}
+}
+
+// WatchProfiles checks memory usage of the workload every interval, until ctx is
+// done, and writes a heap profile (heap-<timestamp>.pb.gz) and a goroutine dump
+// (goroutine-<timestamp>.txt) to dir when memory usage crosses a threshold
+// (see [WithProfileThresholds]). This preserves evidence of what was using memory,
+// which is otherwise lost when the workload is OOM killed. Profiles can be
+// inspected with "go tool pprof". Directory is created if it does not exist,
+// and should be on a persistent volume, for profiles to survive restarts.
+//
+// Memory limit is hard memory limit in the [Result] of the most recent [Apply],
+// [Configure] or [Watch], adjusted for other processes sharing it. If GOMEMLIMIT
+// was not configured by this package, hard memory limit is obtained from detector
+// (see [WithMemoryQuotaDetector]), adjusted for other processes sharing it, if
+// [WithShare], [WithProcessShare] or [WithExcludeOthers] is specified. If hard
+// memory limit is not defined, GOMEMLIMIT is used, if set. Memory usage excludes
+// page cache when supported, as it can be reclaimed (see [WithAdaptiveReserve]).
+// If the platform does not report memory usage, memory mapped by the Go runtime
+// is used.
+//
+// Profiles are captured once for each threshold crossed, and again only after
+// memory usage drops below it. Captures are rate limited (see [WithProfileRateLimit])
+// and only the latest profiles are retained (see [WithProfileRetention]).
+//
+// Only [WithLogger], [WithMemoryQuotaDetector], [WithShare], [WithProcessShare],
+// [WithExcludeOthers], [WithProfileThresholds], [WithProfileRateLimit] and
+// [WithProfileRetention] options are used. Errors are logged and do not stop
+// the watch. This always returns a non-nil error, which wraps ctx error when
+// ctx is done.
+func WatchProfiles(ctx context.Context, interval time.Duration, dir string, opts ...Option) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("memlimit: invalid watch interval: %s", interval)
+	}
+
+	if dir == "" {
+		return errors.New("memlimit: profile directory is not specified")
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("memlimit: failed to create profile directory: %w", err)
+	}
+
+	state := newProfileState(newConfig(opts...), dir)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		state.check(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("memlimit: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// profileState tracks thresholds crossed and profiles captured by [WatchProfiles].
+type profileState struct {
+	cfg *config
+	dir string
+
+	// Index of the highest threshold for which profiles were captured.
+	// -1 indicates no threshold is crossed.
+	crossed int
+	last    time.Time
+}
+
+// newProfileState returns a new profileState, with defaults applied to cfg.
+func newProfileState(cfg *config, dir string) *profileState {
+	if len(cfg.profileThresholds) == 0 {
+		cfg.profileThresholds = []float64{0.9, 0.95}
+	}
+
+	if cfg.profileRateLimit <= 0 {
+		cfg.profileRateLimit = DefaultProfileRateLimit
+	}
+
+	if cfg.profileRetention <= 0 {
+		cfg.profileRetention = DefaultProfileRetention
+	}
+	return &profileState{cfg: cfg, dir: dir, crossed: -1}
+}
+
+// check checks memory usage and captures profiles if a threshold is crossed.
+// It returns true if profiles were captured.
+func (s *profileState) check(ctx context.Context, now time.Time) bool {
+	usage, limit, err := s.usage(ctx)
+	if err != nil {
+		s.cfg.logger.LogAttrs(ctx, slog.LevelError, "Failed to get memory usage",
+			slog.Any("err", err),
+		)
+		return false
+	}
+
+	if limit <= 0 {
+		return false
+	}
+
+	ratio := float64(usage) / float64(limit)
+	level := -1
+	for i, threshold := range s.cfg.profileThresholds {
+		if ratio >= threshold {
+			level = i
+		}
+	}
+
+	// Re-arm thresholds once memory usage drops below them.
+	if level <= s.crossed {
+		s.crossed = level
+		return false
+	}
+
+	if !s.last.IsZero() && now.Sub(s.last) < s.cfg.profileRateLimit {
+		s.cfg.logger.LogAttrs(ctx, slog.LevelDebug, "Skipping profile capture due to rate limit",
+			slog.Float64("memlimit.threshold", s.cfg.profileThresholds[level]),
+		)
+		return false
+	}
+
+	s.cfg.logger.LogAttrs(ctx, slog.LevelWarn, "Memory usage is near memory limit, capturing profiles",
+		slog.String("memlimit.usage", units.Format(usage)),
+		slog.String("memlimit.limit", units.Format(limit)),
+		slog.Float64("memlimit.threshold", s.cfg.profileThresholds[level]),
+		slog.String("dir", s.dir),
+	)
+
+	// Thresholds are marked as crossed even if capture fails, to avoid
+	// repeatedly failing captures.
+	s.crossed = level
+	s.last = now
+	err = writeProfiles(s.dir, now)
+	if err != nil {
+		s.cfg.logger.LogAttrs(ctx, slog.LevelError, "Failed to capture profiles",
+			slog.Any("err", err),
+		)
+		return false
+	}
+
+	err = pruneProfiles(s.dir, s.cfg.profileRetention)
+	if err != nil {
+		s.cfg.logger.LogAttrs(ctx, slog.LevelWarn, "Failed to remove old profiles",
+			slog.Any("err", err),
+		)
+	}
+	return true
+}
+
+// usage returns memory usage and memory limit. Zero limit indicates
+// memory limits are not defined.
+func (s *profileState) usage(ctx context.Context) (int64, int64, error) {
+	_, total, released := runtimeMemory()
+	usage := total - released
+
+	info, err := shared.Call(ctx, func(ctx context.Context) (MemoryInfo, error) {
+		return detectMemoryInfo(ctx, s.cfg.detector)
+	})
+	if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return 0, 0, fmt.Errorf("memlimit: %w", err)
+	}
+
+	if err == nil {
+		if v := memoryUsage(info); v > 0 {
+			usage = v
+		}
+	}
+
+	// Memory limits are only adjusted for other processes sharing them
+	// if GOMEMLIMIT was not configured by this package.
+	if result := last.Load(); result != nil {
+		if limit := shareLimit(*result); limit > 0 {
+			return usage, limit, nil
+		}
+	} else if err == nil {
+		info, _, _ = shareMemoryInfo(ctx, s.cfg, info)
+		if info.Max > 0 {
+			return usage, info.Max, nil
+		}
+	}
+
+	if limit := Current(); limit != math.MaxInt64 {
+		return usage, limit, nil
+	}
+	return usage, 0, nil
+}
+
+// shareLimit returns hard memory limit in result, adjusted for other processes
+// sharing it, like [shareMemoryInfo]. Zero indicates it is not defined.
+func shareLimit(result Result) int64 {
+	switch {
+	case result.Max <= 0:
+		return 0
+	case result.Others > 0:
+		return reduce(result.Max, result.Others)
+	case result.Share > 0:
+		return int64(float64(result.Max) * result.Share)
+	default:
+		return result.Max
+	}
+}
+
+// Prefixes and suffixes of profiles written by [WatchProfiles].
+const (
+	heapProfilePrefix      = "heap-"
+	heapProfileSuffix      = ".pb.gz"
+	goroutineProfilePrefix = "goroutine-"
+	goroutineProfileSuffix = ".txt"
+)
+
+// writeProfiles writes a heap profile and a goroutine dump to dir.
+func writeProfiles(dir string, now time.Time) error {
+	ts := now.UTC().Format("20060102T150405.000Z")
+	err := writeProfile(dir, heapProfilePrefix+ts+heapProfileSuffix, "heap", 0)
+	if err != nil {
+		return err
+	}
+	return writeProfile(dir, goroutineProfilePrefix+ts+goroutineProfileSuffix, "goroutine", 2)
+}
+
+// writeProfile writes named profile to a file in dir. Profile is first written to
+// a temporary file, which is renamed, so that partial profiles are not retained.
+func writeProfile(dir, name, profile string, debug int) error {
+	p := pprof.Lookup(profile)
+	if p == nil {
+		return fmt.Errorf("memlimit: unknown profile: %s", profile)
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return fmt.Errorf("memlimit: failed to create %s profile: %w", profile, err)
+	}
+	defer os.Remove(f.Name())
+
+	err = p.WriteTo(f, debug)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("memlimit: failed to write %s profile: %w", profile, err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("memlimit: failed to write %s profile: %w", profile, err)
+	}
+
+	err = os.Rename(f.Name(), filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("memlimit: failed to write %s profile: %w", profile, err)
+	}
+	return nil
+}
+
+// pruneProfiles removes all but the latest n profiles of each kind from dir.
+func pruneProfiles(dir string, n int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("memlimit: failed to list profiles: %w", err)
+	}
+
+	var errs []error
+	for _, kind := range [][2]string{
+		{heapProfilePrefix, heapProfileSuffix},
+		{goroutineProfilePrefix, goroutineProfileSuffix},
+	} {
+		// Entries are sorted by name, thus by timestamp.
+		var names []string
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.Type().IsRegular() &&
+				strings.HasPrefix(name, kind[0]) && strings.HasSuffix(name, kind[1]) {
+				names = append(names, name)
+			}
+		}
+
+		for _, name := range names[:max(len(names)-n, 0)] {
+			err = os.Remove(filepath.Join(dir, name))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("memlimit: failed to remove profile: %w", err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,321 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+)
+
+// profiles returns names of heap profiles and goroutine dumps in dir.
+func profiles(t *testing.T, dir string) ([]string, []string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %s", err)
+	}
+
+	var heap, goroutine []string
+	for _, entry := range entries {
+		switch {
+		case strings.HasPrefix(entry.Name(), heapProfilePrefix):
+			heap = append(heap, entry.Name())
+		case strings.HasPrefix(entry.Name(), goroutineProfilePrefix):
+			goroutine = append(goroutine, entry.Name())
+		default:
+			t.Errorf("unexpected file in profile dir: %s", entry.Name())
+		}
+	}
+	return heap, goroutine
+}
+
+func TestProfileState(t *testing.T) {
+	last.Store(nil)
+	var current atomic.Int64
+	detector := MemoryInfoDetector(infoFunc(func() MemoryInfo {
+		return MemoryInfo{Max: shared.GiByte, Current: current.Load(), Source: "test"}
+	}))
+
+	dir := t.TempDir()
+	state := newProfileState(newConfig(
+		WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+		WithMemoryQuotaDetector(detector),
+		WithProfileThresholds(0.8, 0.9),
+		WithProfileRateLimit(time.Minute),
+		WithProfileRetention(2),
+	), dir)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		usage   int64
+		elapsed time.Duration
+		capture bool
+	}{
+		{usage: 512 * shared.MiByte},
+		{usage: 850 * shared.MiByte, elapsed: time.Second, capture: true},
+		{usage: 860 * shared.MiByte, elapsed: time.Second},
+		// Rate limited.
+		{usage: 950 * shared.MiByte, elapsed: time.Second},
+		{usage: 950 * shared.MiByte, elapsed: time.Minute, capture: true},
+		{usage: 960 * shared.MiByte, elapsed: 2 * time.Minute},
+		// Drops below 0.9 and crosses it again.
+		{usage: 850 * shared.MiByte, elapsed: 2 * time.Minute},
+		{usage: 950 * shared.MiByte, elapsed: 2 * time.Minute, capture: true},
+		// Drops below 0.8 and crosses both.
+		{usage: 512 * shared.MiByte, elapsed: 2 * time.Minute},
+		{usage: 1000 * shared.MiByte, elapsed: 2 * time.Minute, capture: true},
+	}
+
+	for i, step := range steps {
+		now = now.Add(step.elapsed)
+		current.Store(step.usage)
+		if v := state.check(context.Background(), now); v != step.capture {
+			t.Errorf("step=%d expected capture=%t, got=%t", i, step.capture, v)
+		}
+	}
+
+	heap, goroutine := profiles(t, dir)
+	expect := []string{"20240101T000703.000Z", "20240101T001103.000Z"}
+	if len(heap) != len(expect) || len(goroutine) != len(expect) {
+		t.Fatalf("expected %d profiles of each kind, got heap=%v, goroutine=%v", len(expect), heap, goroutine)
+	}
+
+	for i, ts := range expect {
+		if heap[i] != heapProfilePrefix+ts+heapProfileSuffix {
+			t.Errorf("unexpected heap profile: %s", heap[i])
+		}
+
+		if goroutine[i] != goroutineProfilePrefix+ts+goroutineProfileSuffix {
+			t.Errorf("unexpected goroutine dump: %s", goroutine[i])
+		}
+	}
+
+	// Heap profile is gzip compressed protocol buffer.
+	buf, err := os.ReadFile(filepath.Join(dir, heap[0]))
+	if err != nil || len(buf) < 2 || buf[0] != 0x1f || buf[1] != 0x8b {
+		t.Errorf("expected heap profile to be gzip compressed, got err=%v", err)
+	}
+
+	buf, err = os.ReadFile(filepath.Join(dir, goroutine[0]))
+	if err != nil || !strings.Contains(string(buf), "TestProfileState") {
+		t.Errorf("expected goroutine dump to contain test function, got err=%v", err)
+	}
+}
+
+func TestProfileStateLimit(t *testing.T) {
+	t.Setenv("GOMEMLIMIT", "")
+	t.Cleanup(func() {
+		debug.SetMemoryLimit(math.MaxInt64)
+		last.Store(nil)
+	})
+
+	tt := []struct {
+		name     string
+		detector MemoryQuotaDetector
+		result   *Result
+		limit    int64
+		expect   int64
+		err      bool
+	}{
+		{
+			name: "Max",
+			detector: infoFunc(func() MemoryInfo {
+				return MemoryInfo{Max: shared.GiByte, High: 512 * shared.MiByte, Current: shared.MiByte}
+			}),
+			limit:  256 * shared.MiByte,
+			expect: shared.GiByte,
+		},
+		{
+			name: "GOMEMLIMIT",
+			detector: infoFunc(func() MemoryInfo {
+				return MemoryInfo{High: 512 * shared.MiByte, Current: shared.MiByte}
+			}),
+			limit:  256 * shared.MiByte,
+			expect: 256 * shared.MiByte,
+		},
+		{
+			name: "Unsupported",
+			detector: MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+				return 0, 0, errors.ErrUnsupported
+			}),
+			limit:  256 * shared.MiByte,
+			expect: 256 * shared.MiByte,
+		},
+		{
+			name: "NotDefined",
+			detector: infoFunc(func() MemoryInfo {
+				return MemoryInfo{Current: shared.MiByte}
+			}),
+			limit: math.MaxInt64,
+		},
+		{
+			name: "Error",
+			detector: MemoryQuotaDetectorFunc(func(context.Context) (int64, int64, error) {
+				return 0, 0, errors.New("test: error")
+			}),
+			limit: 256 * shared.MiByte,
+			err:   true,
+		},
+		{
+			name: "Result",
+			detector: infoFunc(func() MemoryInfo {
+				return MemoryInfo{Max: 2 * shared.GiByte, Current: shared.MiByte}
+			}),
+			result: &Result{Max: shared.GiByte, Source: SourceQuota},
+			limit:  256 * shared.MiByte,
+			expect: shared.GiByte,
+		},
+		{
+			name: "ResultShare",
+			detector: infoFunc(func() MemoryInfo {
+				return MemoryInfo{Max: 2 * shared.GiByte, Current: shared.MiByte}
+			}),
+			result: &Result{Max: shared.GiByte, Share: 0.5, Source: SourceQuota},
+			limit:  256 * shared.MiByte,
+			expect: 512 * shared.MiByte,
+		},
+		{
+			name: "ResultOthers",
+			detector: infoFunc(func() MemoryInfo {
+				return MemoryInfo{Max: 2 * shared.GiByte, Current: shared.MiByte}
+			}),
+			result: &Result{Max: shared.GiByte, Others: 256 * shared.MiByte, Source: SourceQuota},
+			limit:  256 * shared.MiByte,
+			expect: 768 * shared.MiByte,
+		},
+		{
+			name: "ResultNoMax",
+			detector: infoFunc(func() MemoryInfo {
+				return MemoryInfo{Max: 2 * shared.GiByte, Current: shared.MiByte}
+			}),
+			result: &Result{High: shared.GiByte, Source: SourceQuota},
+			limit:  256 * shared.MiByte,
+			expect: 256 * shared.MiByte,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			debug.SetMemoryLimit(tc.limit)
+			last.Store(tc.result)
+			state := newProfileState(newConfig(WithMemoryQuotaDetector(tc.detector)), t.TempDir())
+			usage, limit, err := state.usage(context.Background())
+			if tc.err != (err != nil) {
+				t.Errorf("expected error=%t, got=%v", tc.err, err)
+			}
+
+			if limit != tc.expect {
+				t.Errorf("expected limit=%d, got=%d", tc.expect, limit)
+			}
+
+			if !tc.err && usage <= 0 {
+				t.Errorf("expected positive usage, got=%d", usage)
+			}
+		})
+	}
+}
+
+func TestPruneProfiles(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"heap-20240101T000000.000Z.pb.gz",
+		"heap-20240101T000100.000Z.pb.gz",
+		"heap-20240101T000200.000Z.pb.gz",
+		"goroutine-20240101T000000.000Z.txt",
+		"goroutine-20240101T000200.000Z.txt",
+		"unrelated.txt",
+		".heap-20240101T000300.000Z.pb.gz.1234",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("failed to create file: %s", err)
+		}
+	}
+
+	if err := pruneProfiles(dir, 1); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %s", err)
+	}
+
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+
+	expect := []string{
+		".heap-20240101T000300.000Z.pb.gz.1234",
+		"goroutine-20240101T000200.000Z.txt",
+		"heap-20240101T000200.000Z.pb.gz",
+		"unrelated.txt",
+	}
+	if !slices.Equal(got, expect) {
+		t.Errorf("expected=%v, got=%v", expect, got)
+	}
+}
+
+func TestWatchProfiles(t *testing.T) {
+	t.Run("InvalidInterval", func(t *testing.T) {
+		err := WatchProfiles(context.Background(), 0, t.TempDir())
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("MissingDir", func(t *testing.T) {
+		err := WatchProfiles(context.Background(), time.Second, "")
+		if err == nil {
+			t.Errorf("expected error, got nil")
+		}
+	})
+
+	t.Run("Capture", func(t *testing.T) {
+		last.Store(nil)
+		dir := filepath.Join(t.TempDir(), "profiles")
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err := WatchProfiles(ctx, 10*time.Millisecond, dir,
+			WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+			WithMemoryQuotaDetector(infoFunc(func() MemoryInfo {
+				return MemoryInfo{Max: shared.GiByte, Current: 950 * shared.MiByte}
+			})),
+		)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+
+		heap, goroutine := profiles(t, dir)
+		if len(heap) != 1 || len(goroutine) != 1 {
+			t.Errorf("expected 1 profile of each kind, got heap=%v, goroutine=%v", heap, goroutine)
+		}
+	})
+}
+
+// infoFunc is a [MemoryInfoDetector] which returns memory info from a function.
+type infoFunc func() MemoryInfo
+
+func (fn infoFunc) DetectMemoryQuota(context.Context) (int64, int64, error) {
+	info := fn()
+	return info.Max, info.High, nil
+}
+
+func (fn infoFunc) DetectMemoryInfo(context.Context) (MemoryInfo, error) {
+	return fn(), nil
+}
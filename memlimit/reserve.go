@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+// FixedReserve returns a reserve func (see [WithReserveFunc]), which reserves
+// fixed number of bytes, irrespective of hard memory limit. If bytes is negative,
+// nil is returned, which when used with [WithReserveFunc] is a no-op.
+func FixedReserve(bytes int64) func(limit int64) (reserve int64) {
+	if bytes < 0 {
+		return nil
+	}
+
+	return func(limit int64) int64 {
+		if limit <= 0 {
+			return 0
+		}
+		return bytes
+	}
+}
+
+// PercentReserve returns a reserve func (see [WithReserveFunc]), which reserves
+// percent of hard memory limit, but at least minimum and at most maximum bytes.
+// If maximum is zero, reserve is not capped. For example, [DefaultReserveFunc] is
+// same as PercentReserve(10, 0, 100*units.MiB). If percent is not in range [0, 100],
+// minimum or maximum is negative, or maximum is less than minimum, nil is returned,
+// which when used with [WithReserveFunc] is a no-op.
+func PercentReserve(percent float64, minimum, maximum int64) func(limit int64) (reserve int64) {
+	if math.IsNaN(percent) || percent < 0 || percent > 100 ||
+		minimum < 0 || maximum < 0 || (maximum > 0 && maximum < minimum) {
+		return nil
+	}
+
+	return func(limit int64) int64 {
+		if limit <= 0 {
+			return 0
+		}
+
+		v := max(int64(math.Floor(float64(limit)*percent/100)), minimum)
+		if maximum > 0 {
+			return min(v, maximum)
+		}
+		return v
+	}
+}
+
+// ReserveTier is a tier of [TieredReserve].
+type ReserveTier struct {
+	// Tier applies to hard memory limits up to and including UpTo bytes.
+	// Zero indicates no upper bound, and is only valid for the last tier.
+	UpTo int64
+
+	// Reserve func used for this tier, for example [PercentReserve].
+	Reserve func(limit int64) (reserve int64)
+}
+
+// TieredReserve returns a reserve func (see [WithReserveFunc]), which uses reserve
+// func of the first tier applicable to the hard memory limit. Tiers must be in
+// increasing order of [ReserveTier.UpTo]. If hard memory limit is larger than
+// all tiers, last tier is used. For example, to reserve 20% up to 1GiB, 10% up to
+// 8GiB and 512MiB for larger limits,
+//
+//	memlimit.TieredReserve(
+//		memlimit.ReserveTier{UpTo: units.GiB, Reserve: memlimit.PercentReserve(20, 0, 0)},
+//		memlimit.ReserveTier{UpTo: 8 * units.GiB, Reserve: memlimit.PercentReserve(10, 0, 0)},
+//		memlimit.ReserveTier{Reserve: memlimit.FixedReserve(512 * units.MiB)},
+//	)
+//
+// If no tiers are specified, any tier has nil reserve func or tiers are not in
+// increasing order, nil is returned, which when used with [WithReserveFunc] is a no-op.
+func TieredReserve(tiers ...ReserveTier) func(limit int64) (reserve int64) {
+	if len(tiers) == 0 {
+		return nil
+	}
+
+	for i, tier := range tiers {
+		if tier.Reserve == nil || tier.UpTo < 0 {
+			return nil
+		}
+
+		// Only last tier may be unbounded.
+		if tier.UpTo == 0 && i != len(tiers)-1 {
+			return nil
+		}
+
+		if i > 0 && tier.UpTo != 0 && tier.UpTo <= tiers[i-1].UpTo {
+			return nil
+		}
+	}
+
+	tiers = append([]ReserveTier(nil), tiers...)
+	return func(limit int64) int64 {
+		if limit <= 0 {
+			return 0
+		}
+
+		for _, tier := range tiers {
+			if tier.UpTo == 0 || limit <= tier.UpTo {
+				return tier.Reserve(limit)
+			}
+		}
+		return tiers[len(tiers)-1].Reserve(limit)
+	}
+}
+
+// ParseReserve parses a textual reserve spec and returns a reserve func
+// (see [WithReserveFunc]). This allows tuning reserve without recompiling,
+// for example via GOAUTOTUNE_MEMLIMIT_RESERVE environment variable.
+//
+// Spec consists of an amount, which is either a percentage of hard memory limit
+// (like 15%) or a size parsed by [units.Parse] (like 256MiB), optionally followed
+// by comma separated min=SIZE and max=SIZE to bound percentage amount. Tiers
+// (see [TieredReserve]) are separated by semicolons and specify upto=SIZE.
+//
+// Sizes with binary suffixes KiB, MiB, GiB and TiB (or Ki, Mi, Gi and Ti) are
+// powers of 1024, while suffixes K, M, G and T are decimal (powers of 1000),
+// as in Kubernetes quantities. For example, max=512M is 512000000 bytes,
+// while max=512MiB is 536870912 bytes.
+//
+//	15%
+//	256MiB
+//	15%,min=32MiB,max=512MiB
+//	20%,upto=1GiB;10%,upto=8GiB;512MiB
+func ParseReserve(spec string) (func(limit int64) (reserve int64), error) {
+	segments := strings.Split(spec, ";")
+	if len(segments) == 1 {
+		tier, err := parseReserveTier(segments[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if tier.UpTo != 0 {
+			return nil, fmt.Errorf("memlimit: invalid reserve spec(%q): upto is only valid with tiers", spec)
+		}
+		return tier.Reserve, nil
+	}
+
+	tiers := make([]ReserveTier, 0, len(segments))
+	for _, segment := range segments {
+		tier, err := parseReserveTier(segment)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+
+	fn := TieredReserve(tiers...)
+	if fn == nil {
+		return nil, fmt.Errorf("memlimit: invalid reserve spec(%q): tiers must be in increasing order of upto, "+
+			"and only last tier may omit upto", spec)
+	}
+	return fn, nil
+}
+
+// parseReserveTier parses a single tier of reserve spec.
+func parseReserveTier(spec string) (ReserveTier, error) {
+	fields := strings.Split(strings.TrimSpace(spec), ",")
+	amount := strings.TrimSpace(fields[0])
+	if amount == "" {
+		return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): missing amount", spec)
+	}
+
+	var tier ReserveTier
+	var minimum, maximum int64
+	var bounded bool
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): invalid field %q", spec, field)
+		}
+
+		v, err := parseReserveSize(value)
+		if err != nil {
+			return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): invalid %s: %w", spec, key, err)
+		}
+
+		switch key {
+		case "min":
+			minimum, bounded = v, true
+		case "max":
+			maximum, bounded = v, true
+		case "upto":
+			if v == 0 {
+				return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): upto must be positive", spec)
+			}
+			tier.UpTo = v
+		default:
+			return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): unknown key %q", spec, key)
+		}
+	}
+
+	if percent, ok := strings.CutSuffix(amount, "%"); ok {
+		// Validate percentage with units.ParsePercent, as it is stricter.
+		if _, err := units.ParsePercent(amount, 0); err != nil {
+			return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): %w", spec, err)
+		}
+
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil {
+			return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): %w", spec, err)
+		}
+
+		tier.Reserve = PercentReserve(p, minimum, maximum)
+		if tier.Reserve == nil {
+			return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): max is less than min", spec)
+		}
+		return tier, nil
+	}
+
+	if bounded {
+		return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): min and max are only valid with percentage", spec)
+	}
+
+	v, err := parseReserveSize(amount)
+	if err != nil {
+		return ReserveTier{}, fmt.Errorf("memlimit: invalid reserve spec(%q): %w", spec, err)
+	}
+	tier.Reserve = FixedReserve(v)
+	return tier, nil
+}
+
+// parseReserveSize parses s as size in bytes.
+func parseReserveSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || strings.HasSuffix(s, "%") {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return units.Parse(s, 0)
+}
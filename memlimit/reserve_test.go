@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/memlimit"
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+func TestReserveFuncInvalid(t *testing.T) {
+	valid := memlimit.FixedReserve(units.MiB)
+	tt := []struct {
+		name string
+		fn   func(int64) int64
+	}{
+		{name: "Fixed/Negative", fn: memlimit.FixedReserve(-1)},
+		{name: "Percent/Negative", fn: memlimit.PercentReserve(-1, 0, 0)},
+		{name: "Percent/Over100", fn: memlimit.PercentReserve(101, 0, 0)},
+		{name: "Percent/NaN", fn: memlimit.PercentReserve(math.NaN(), 0, 0)},
+		{name: "Percent/NegativeMin", fn: memlimit.PercentReserve(10, -1, 0)},
+		{name: "Percent/NegativeMax", fn: memlimit.PercentReserve(10, 0, -1)},
+		{name: "Percent/MaxLessThanMin", fn: memlimit.PercentReserve(10, 2*units.MiB, units.MiB)},
+		{name: "Tiered/Empty", fn: memlimit.TieredReserve()},
+		{name: "Tiered/NilReserve", fn: memlimit.TieredReserve(memlimit.ReserveTier{})},
+		{
+			name: "Tiered/NegativeUpTo",
+			fn:   memlimit.TieredReserve(memlimit.ReserveTier{UpTo: -1, Reserve: valid}),
+		},
+		{
+			name: "Tiered/UnboundedNotLast",
+			fn: memlimit.TieredReserve(
+				memlimit.ReserveTier{Reserve: valid},
+				memlimit.ReserveTier{UpTo: units.GiB, Reserve: valid},
+			),
+		},
+		{
+			name: "Tiered/NotIncreasing",
+			fn: memlimit.TieredReserve(
+				memlimit.ReserveTier{UpTo: units.GiB, Reserve: valid},
+				memlimit.ReserveTier{UpTo: units.GiB, Reserve: valid},
+			),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.fn != nil {
+				t.Errorf("expected nil")
+			}
+		})
+	}
+}
+
+func TestReserveFunc(t *testing.T) {
+	tiered := memlimit.TieredReserve(
+		memlimit.ReserveTier{UpTo: units.GiB, Reserve: memlimit.PercentReserve(20, 0, 0)},
+		memlimit.ReserveTier{UpTo: 8 * units.GiB, Reserve: memlimit.PercentReserve(10, 0, 0)},
+		memlimit.ReserveTier{Reserve: memlimit.FixedReserve(512 * units.MiB)},
+	)
+	bounded := memlimit.TieredReserve(
+		memlimit.ReserveTier{UpTo: units.GiB, Reserve: memlimit.FixedReserve(64 * units.MiB)},
+		memlimit.ReserveTier{UpTo: 2 * units.GiB, Reserve: memlimit.FixedReserve(128 * units.MiB)},
+	)
+	tt := []struct {
+		name   string
+		fn     func(int64) int64
+		limit  int64
+		expect int64
+	}{
+		{name: "Fixed/Zero", fn: memlimit.FixedReserve(units.MiB)},
+		{name: "Fixed", fn: memlimit.FixedReserve(units.MiB), limit: units.GiB, expect: units.MiB},
+		{name: "Percent/Zero", fn: memlimit.PercentReserve(10, units.MiB, 0)},
+		{name: "Percent", fn: memlimit.PercentReserve(25, 0, 0), limit: units.GiB, expect: 256 * units.MiB},
+		{
+			name:   "Percent/Min",
+			fn:     memlimit.PercentReserve(10, 32*units.MiB, 0),
+			limit:  100 * units.MiB,
+			expect: 32 * units.MiB,
+		},
+		{
+			name:   "Percent/Max",
+			fn:     memlimit.PercentReserve(10, 0, 100*units.MiB),
+			limit:  10 * units.GiB,
+			expect: 100 * units.MiB,
+		},
+		{name: "Tiered/Zero", fn: tiered},
+		{name: "Tiered/First", fn: tiered, limit: 500 * units.MiB, expect: 100 * units.MiB},
+		{name: "Tiered/FirstBoundary", fn: tiered, limit: units.GiB, expect: units.GiB / 5},
+		{name: "Tiered/Second", fn: tiered, limit: 4 * units.GiB, expect: 4 * units.GiB / 10},
+		{name: "Tiered/Last", fn: tiered, limit: 16 * units.GiB, expect: 512 * units.MiB},
+		{name: "Tiered/Bounded", fn: bounded, limit: 4 * units.GiB, expect: 128 * units.MiB},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			v := tc.fn(tc.limit)
+			if v != tc.expect {
+				t.Errorf("expected=%d, got=%d", tc.expect, v)
+			}
+		})
+	}
+}
+
+func TestParseReserve(t *testing.T) {
+	tt := []struct {
+		spec   string
+		limit  int64
+		expect int64
+		err    bool
+	}{
+		{spec: "15%", limit: units.GiB, expect: units.GiB * 15 / 100},
+		{spec: "256MiB", limit: units.GiB, expect: 256 * units.MiB},
+		{spec: "256Mi", limit: units.GiB, expect: 256 * units.MiB},
+		{spec: "15%,min=32MiB,max=512MiB", limit: 100 * units.MiB, expect: 32 * units.MiB},
+		{spec: "15%,min=32MiB,max=512MiB", limit: 10 * units.GiB, expect: 512 * units.MiB},
+		{spec: " 15% , max=512MiB ", limit: units.GiB, expect: units.GiB * 15 / 100},
+		{spec: "15%,max=512M", limit: 10 * units.GiB, expect: 512 * units.MB},
+		{spec: "15%,min=512K", limit: units.MiB, expect: 512 * units.KB},
+		{spec: "512K", limit: units.GiB, expect: 512 * units.KB},
+		{spec: "20%,upto=1GiB;10%,upto=8GiB;512MiB", limit: 500 * units.MiB, expect: 100 * units.MiB},
+		{spec: "20%,upto=1GiB;10%,upto=8GiB;512MiB", limit: 4 * units.GiB, expect: 4 * units.GiB / 10},
+		{spec: "20%,upto=1GiB;10%,upto=8GiB;512MiB", limit: 16 * units.GiB, expect: 512 * units.MiB},
+		{spec: "", err: true},
+		{spec: "foo", err: true},
+		{spec: "off", err: true},
+		{spec: "-1MiB", err: true},
+		{spec: "101%", err: true},
+		{spec: "15%,min", err: true},
+		{spec: "15%,min=5%", err: true},
+		{spec: "15%,foo=1MiB", err: true},
+		{spec: "15%,min=2MiB,max=1MiB", err: true},
+		{spec: "256MiB,max=1GiB", err: true},
+		{spec: "15%,upto=1GiB", err: true},
+		{spec: "15%,upto=0;10%", err: true},
+		{spec: "15%;10%", err: true},
+		{spec: "15%,upto=8GiB;10%,upto=1GiB;512MiB", err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.spec, func(t *testing.T) {
+			fn, err := memlimit.ParseReserve(tc.spec)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if v := fn(tc.limit); v != tc.expect {
+				t.Errorf("expected=%d, got=%d", tc.expect, v)
+			}
+		})
+	}
+}
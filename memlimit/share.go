@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+// WithShare configures share of memory limits available to this process, when
+// memory limits are shared by multiple processes, for example with sidecar binaries
+// in a single container, pre-fork servers or a supervisor and its workers. Hard and
+// soft memory limits are multiplied by share before computing reserve and GOMEMLIMIT.
+// For example, with two processes sharing a hard memory limit of 4GiB, WithShare(0.5)
+// computes GOMEMLIMIT from hard memory limit of 2GiB.
+//
+// This takes precedence over [WithProcessShare]. If share is not in range (0, 1),
+// this returns nil, which is a no-op.
+func WithShare(share float64) Option {
+	if share > 0 && share < 1 {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.share = share
+			},
+		}
+	}
+	return nil
+}
+
+// WithProcessShare divides memory limits equally among all processes sharing them,
+// like processes in cgroup.procs on Linux or active processes in the job object
+// on Windows, as reported by [MemoryInfo.Procs] or by DetectProcs(context.Context) (int, error)
+// method of the detector, if implemented. Number of processes is only detected
+// when this is enabled, and limits are not divided if it cannot be detected.
+// This is only suitable when all processes are similar, for example multiple
+// instances of the same binary.
+// See [WithShare] for more info. If enable is false, this returns nil, which
+// is a no-op.
+func WithProcessShare(enable bool) Option {
+	if enable {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.processShare = true
+			},
+		}
+	}
+	return nil
+}
+
+// WithExcludeOthers subtracts memory used by other processes sharing memory
+// limits from hard and soft memory limits, before computing reserve and GOMEMLIMIT.
+// Unlike [WithShare], this does not require processes to be similar, and is
+// useful when co-located processes have different memory usage, for example
+// a Go process with a sidecar. As memory usage of other processes changes over
+// time, this is typically used with [Watch].
+//
+// Memory used by other processes is estimated as anonymous memory usage of
+// the workload (anon in memory.stat on Linux) which is not used by this process
+// (RssAnon in /proc/self/status). Page cache is excluded as it can be reclaimed.
+// If used with [WithAdaptiveReserve], memory used by other processes is not
+// included in adaptive reserve.
+//
+// This takes precedence over [WithShare] and [WithProcessShare], which are
+// only used if memory usage of other processes cannot be estimated, for
+// example on Windows. If enable is false, this returns nil, which is a no-op.
+func WithExcludeOthers(enable bool) Option {
+	if enable {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.excludeOthers = true
+			},
+		}
+	}
+	return nil
+}
+
+// shareMemoryInfo returns memory info with memory limits adjusted for other
+// processes sharing them (see [WithExcludeOthers], [WithShare] and [WithProcessShare]),
+// along with share of this process and memory used by other processes.
+// Share is 1 if memory limits are not divided.
+//
+// Number of processes is only detected when required by [WithExcludeOthers]
+// or [WithProcessShare] and not reported by the detector. Failures are
+// treated as unknown.
+func shareMemoryInfo(ctx context.Context, cfg *config, info MemoryInfo) (MemoryInfo, float64, int64) {
+	if info.Procs == 0 &&
+		((cfg.excludeOthers && info.Anon > 0) || (cfg.share == 0 && cfg.processShare)) {
+		procs, err := shared.Procs(ctx, cfg.detector)
+		if err == nil {
+			info.Procs = procs
+		} else {
+			cfg.logger.LogAttrs(ctx, slog.LevelDebug, "Failed to obtain number of processes",
+				slog.Any("err", err),
+			)
+		}
+	}
+
+	if cfg.excludeOthers && info.Procs != 1 && info.Anon > 0 {
+		self, err := selfMemory()
+		if err == nil {
+			others := othersMemory(info, self)
+			if others > 0 {
+				cfg.logger.LogAttrs(ctx, slog.LevelInfo,
+					"Excluding memory used by other processes from memory limits",
+					slog.String("memlimit.others", units.Format(others)),
+					slog.Int("memlimit.procs", info.Procs),
+				)
+				info.Max = reduce(info.Max, others)
+				info.High = reduce(info.High, others)
+			}
+			return info, 1, others
+		}
+
+		cfg.logger.LogAttrs(ctx, slog.LevelWarn,
+			"Failed to obtain memory usage of the process",
+			slog.Any("err", err),
+		)
+	}
+
+	share := shared.Share(cfg.share, cfg.processShare, info.Procs)
+	if share < 1 {
+		info.Max = int64(float64(info.Max) * share)
+		info.High = int64(float64(info.High) * share)
+		cfg.logger.LogAttrs(ctx, slog.LevelInfo, "Scaling memory limits by share of the process",
+			slog.Float64("memlimit.share", share),
+			slog.Int("memlimit.procs", info.Procs),
+		)
+	}
+	return info, share, 0
+}
+
+// othersMemory returns anonymous memory usage of the workload,
+// which is not used by this process.
+func othersMemory(info MemoryInfo, self int64) int64 {
+	return max(info.Anon-self, 0)
+}
+
+// reduce subtracts bytes from memory limit, but ensures that limit
+// remains defined. Undefined limits are returned as is.
+func reduce(limit, bytes int64) int64 {
+	if limit <= 0 {
+		return limit
+	}
+	return max(limit-bytes, 1)
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package memlimit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// selfMemory returns anonymous memory used by this process,
+// as reported by RssAnon in /proc/self/status.
+func selfMemory() (int64, error) {
+	buf, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, fmt.Errorf("memlimit: failed to read process status: %w", err)
+	}
+	return parseRssAnon(buf)
+}
+
+// parseRssAnon parses RssAnon from contents of /proc/<pid>/status.
+func parseRssAnon(buf []byte) (int64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		value, ok := bytes.CutPrefix(scanner.Bytes(), []byte("RssAnon:"))
+		if !ok {
+			continue
+		}
+
+		value, ok = bytes.CutSuffix(bytes.TrimSpace(value), []byte(" kB"))
+		if !ok {
+			return 0, fmt.Errorf("memlimit: invalid RssAnon in process status: %q", scanner.Text())
+		}
+
+		v, err := strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("memlimit: invalid RssAnon in process status: %q", scanner.Text())
+		}
+		return v * 1024, nil
+	}
+	return 0, fmt.Errorf("memlimit: RssAnon not found in process status")
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+package memlimit
+
+import (
+	"testing"
+)
+
+func TestParseRssAnon(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		expect int64
+		err    bool
+	}{
+		{
+			name:   "Valid",
+			input:  "Name:\tgo\nVmRSS:\t   20480 kB\nRssAnon:\t    8192 kB\nRssFile:\t   12288 kB\n",
+			expect: 8192 * 1024,
+		},
+		{name: "Zero", input: "RssAnon:\t       0 kB\n"},
+		{name: "Missing", input: "Name:\tgo\nVmRSS:\t   20480 kB\n", err: true},
+		{name: "NoUnit", input: "RssAnon:\t    8192\n", err: true},
+		{name: "Invalid", input: "RssAnon:\t    foo kB\n", err: true},
+		{name: "Negative", input: "RssAnon:\t    -1 kB\n", err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := parseRssAnon([]byte(tc.input))
+			if tc.err != (err != nil) {
+				t.Errorf("expected error=%t, got=%v", tc.err, err)
+			}
+
+			if v != tc.expect {
+				t.Errorf("expected=%d, got=%d", tc.expect, v)
+			}
+		})
+	}
+
+	t.Run("Self", func(t *testing.T) {
+		v, err := selfMemory()
+		if err != nil || v <= 0 {
+			t.Errorf("expected positive value, got=%d(%v)", v, err)
+		}
+	})
+}
@@ -0,0 +1,15 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package memlimit
+
+import (
+	"errors"
+	"fmt"
+)
+
+func selfMemory() (int64, error) {
+	return 0, fmt.Errorf("memlimit: process memory usage is not supported: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package memlimit_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/internal/shared"
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+// procsDetector is a memory quota detector which reports number of processes
+// only via DetectProcs, and counts calls to it.
+type procsDetector struct {
+	max   int64
+	procs int
+	err   error
+	calls *atomic.Int32
+}
+
+func (d procsDetector) DetectMemoryQuota(_ context.Context) (int64, int64, error) {
+	return d.max, 0, nil
+}
+
+func (d procsDetector) DetectProcs(_ context.Context) (int, error) {
+	d.calls.Add(1)
+	return d.procs, d.err
+}
+
+func TestComputeWithShare(t *testing.T) {
+	tt := []struct {
+		name  string
+		info  memlimit.MemoryInfo
+		opts  []memlimit.Option
+		value int64
+		share float64
+	}{
+		{
+			name:  "NoShare",
+			info:  memlimit.MemoryInfo{Max: 4 * shared.GiByte, Procs: 4},
+			value: 4*shared.GiByte - 100*shared.MiByte,
+		},
+		{
+			name:  "Explicit",
+			info:  memlimit.MemoryInfo{Max: 4 * shared.GiByte, Procs: 4},
+			opts:  []memlimit.Option{memlimit.WithShare(0.5)},
+			value: 2*shared.GiByte - 100*shared.MiByte,
+			share: 0.5,
+		},
+		{
+			name: "ExplicitOverridesProcessShare",
+			info: memlimit.MemoryInfo{Max: 4 * shared.GiByte, Procs: 4},
+			opts: []memlimit.Option{
+				memlimit.WithShare(0.5),
+				memlimit.WithProcessShare(true),
+			},
+			value: 2*shared.GiByte - 100*shared.MiByte,
+			share: 0.5,
+		},
+		{
+			name:  "ProcessShare",
+			info:  memlimit.MemoryInfo{Max: 4 * shared.GiByte, Procs: 4},
+			opts:  []memlimit.Option{memlimit.WithProcessShare(true)},
+			value: shared.GiByte - 100*shared.MiByte,
+			share: 0.25,
+		},
+		{
+			name:  "ProcessShareReserve",
+			info:  memlimit.MemoryInfo{Max: 512 * shared.MiByte, Procs: 2},
+			opts:  []memlimit.Option{memlimit.WithProcessShare(true)},
+			value: 256*shared.MiByte - 256*shared.MiByte/10,
+			share: 0.5,
+		},
+		{
+			name:  "ProcessShareSoftLimit",
+			info:  memlimit.MemoryInfo{High: 4 * shared.GiByte, Procs: 2},
+			opts:  []memlimit.Option{memlimit.WithProcessShare(true)},
+			value: 2 * shared.GiByte,
+			share: 0.5,
+		},
+		{
+			name:  "ProcessShareSingleProcess",
+			info:  memlimit.MemoryInfo{Max: 4 * shared.GiByte, Procs: 1},
+			opts:  []memlimit.Option{memlimit.WithProcessShare(true)},
+			value: 4*shared.GiByte - 100*shared.MiByte,
+		},
+		{
+			name:  "ExcludeOthersSingleProcess",
+			info:  memlimit.MemoryInfo{Max: 4 * shared.GiByte, Anon: 2 * shared.GiByte, Procs: 1},
+			opts:  []memlimit.Option{memlimit.WithExcludeOthers(true)},
+			value: 4*shared.GiByte - 100*shared.MiByte,
+		},
+		{
+			name: "ExcludeOthersNoUsage",
+			info: memlimit.MemoryInfo{Max: 4 * shared.GiByte, Procs: 2},
+			opts: []memlimit.Option{
+				memlimit.WithExcludeOthers(true),
+				memlimit.WithProcessShare(true),
+			},
+			value: 2*shared.GiByte - 100*shared.MiByte,
+			share: 0.5,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOMEMLIMIT", "")
+			opts := append([]memlimit.Option{
+				memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+				memlimit.WithMemoryQuotaDetector(infoDetector(tc.info)),
+			}, tc.opts...)
+			result, err := memlimit.Compute(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+
+			if result.Value != tc.value || result.Share != tc.share || result.Others != 0 {
+				t.Errorf("expected value=%d, share=%f, others=0, got=%+v", tc.value, tc.share, result)
+			}
+
+			if result.Max != tc.info.Max || result.High != tc.info.High {
+				t.Errorf("expected max=%d, high=%d, got=%+v", tc.info.Max, tc.info.High, result)
+			}
+		})
+	}
+
+	t.Run("DetectProcs", func(t *testing.T) {
+		tt := []struct {
+			name  string
+			err   error
+			opts  []memlimit.Option
+			value int64
+			share float64
+			calls int32
+		}{
+			{
+				name:  "NoShare",
+				value: 4*shared.GiByte - 100*shared.MiByte,
+			},
+			{
+				name:  "Explicit",
+				opts:  []memlimit.Option{memlimit.WithShare(0.5), memlimit.WithProcessShare(true)},
+				value: 2*shared.GiByte - 100*shared.MiByte,
+				share: 0.5,
+			},
+			{
+				name:  "ProcessShare",
+				opts:  []memlimit.Option{memlimit.WithProcessShare(true)},
+				value: shared.GiByte - 100*shared.MiByte,
+				share: 0.25,
+				calls: 1,
+			},
+			{
+				name:  "ProcessShareError",
+				err:   errors.New("failed to read cgroup.procs"),
+				opts:  []memlimit.Option{memlimit.WithProcessShare(true)},
+				value: 4*shared.GiByte - 100*shared.MiByte,
+				calls: 1,
+			},
+		}
+		for _, tc := range tt {
+			t.Run(tc.name, func(t *testing.T) {
+				t.Setenv("GOMEMLIMIT", "")
+				d := procsDetector{max: 4 * shared.GiByte, procs: 4, err: tc.err, calls: &atomic.Int32{}}
+				opts := append([]memlimit.Option{
+					memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+					memlimit.WithMemoryQuotaDetector(d),
+				}, tc.opts...)
+				result, err := memlimit.Compute(context.Background(), opts...)
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+
+				if result.Value != tc.value || result.Share != tc.share {
+					t.Errorf("expected value=%d, share=%f, got=%+v", tc.value, tc.share, result)
+				}
+
+				if v := d.calls.Load(); v != tc.calls {
+					t.Errorf("expected DetectProcs calls=%d, got=%d", tc.calls, v)
+				}
+			})
+		}
+	})
+}
+
+func TestComputeWithExcludeOthers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("process memory usage is not supported on %s", runtime.GOOS)
+	}
+
+	t.Setenv("GOMEMLIMIT", "")
+	info := memlimit.MemoryInfo{
+		Max:   16 * shared.GiByte,
+		High:  12 * shared.GiByte,
+		Anon:  8 * shared.GiByte,
+		Procs: 2,
+	}
+	result, err := memlimit.Compute(context.Background(),
+		memlimit.WithLogger(slog.New(trampoline.NewTestingHandler(t))),
+		memlimit.WithMemoryQuotaDetector(infoDetector(info)),
+		memlimit.WithExcludeOthers(true),
+		memlimit.WithProcessShare(true),
+		memlimit.WithReserveFunc(memlimit.FixedReserve(0)),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	// Anonymous memory used by test binary is well below 1GiB.
+	if result.Others <= 7*shared.GiByte || result.Others > info.Anon {
+		t.Errorf("expected others in range (7GiB, 8GiB], got=%d", result.Others)
+	}
+
+	if result.Share != 0 {
+		t.Errorf("expected share=0, got=%f", result.Share)
+	}
+
+	if result.Value != info.High-result.Others {
+		t.Errorf("expected value=%d, got=%d", info.High-result.Others, result.Value)
+	}
+}
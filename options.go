@@ -0,0 +1,262 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package autotune
+
+import (
+	"log/slog"
+
+	"github.com/tprasadtp/go-autotune/internal/autotune"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+type config struct {
+	logger              *slog.Logger
+	preserve            bool
+	cpuQuotaDetector    maxprocs.CPUQuotaDetector
+	memoryQuotaDetector memlimit.MemoryQuotaDetector
+	roundFunc           func(float64) int
+	cpuPolicy           maxprocs.CPUPolicy
+	reserveFunc         func(int64) int64
+	limitPolicy         memlimit.LimitPolicy
+	disableMaxProcs     bool
+	disableMemLimit     bool
+	notify              bool
+	share               float64
+	processShare        bool
+	excludeOthers       bool
+}
+
+// newConfig builds config from options.
+func newConfig(opts ...Option) *config {
+	cfg := &config{}
+	for i := range opts {
+		if opts[i] != nil {
+			opts[i].apply(cfg)
+		}
+	}
+	return cfg
+}
+
+// runConfig returns config for [autotune.Run], used by [Configure] and [Diagnose].
+func (c *config) runConfig() autotune.Config {
+	return autotune.Config{
+		Logger:              c.logger,
+		PreserveExternal:    c.preserve,
+		CPUQuotaDetector:    c.cpuQuotaDetector,
+		MemoryQuotaDetector: c.memoryQuotaDetector,
+		RoundFunc:           c.roundFunc,
+		CPUPolicy:           c.cpuPolicy,
+		ReserveFunc:         c.reserveFunc,
+		LimitPolicy:         c.limitPolicy,
+		DisableMaxProcs:     c.disableMaxProcs,
+		DisableMemLimit:     c.disableMemLimit,
+		Notify:              c.notify,
+		Share:               c.share,
+		ProcessShare:        c.processShare,
+		ExcludeOthers:       c.excludeOthers,
+	}
+}
+
+// Option to apply when configuring GOMAXPROCS and GOMEMLIMIT.
+type Option interface {
+	apply(c *config)
+}
+
+type optionFunc struct {
+	fn func(*config)
+}
+
+func (opt *optionFunc) apply(f *config) {
+	opt.fn(f)
+}
+
+// WithLogger configures the logger used for setting GOMAXPROCS and GOMEMLIMIT.
+func WithLogger(logger *slog.Logger) Option {
+	if logger != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.logger = logger
+			},
+		}
+	}
+	return nil
+}
+
+// WithPreserveExternal leaves GOMAXPROCS and GOMEMLIMIT unchanged if they were
+// modified by other packages. See [github.com/tprasadtp/go-autotune/maxprocs.IsModified]
+// and [github.com/tprasadtp/go-autotune/memlimit.IsModified] for more info.
+func WithPreserveExternal(preserve bool) Option {
+	if preserve {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.preserve = true
+			},
+		}
+	}
+	return nil
+}
+
+// WithSystemdNotify reports GOMAXPROCS, GOMEMLIMIT and their sources to the
+// service manager via [sd_notify] protocol, which is shown by "systemctl status".
+// This does nothing if NOTIFY_SOCKET environment variable is not set. When this
+// package is imported, this can be enabled by setting GOAUTOTUNE_NOTIFY
+// environment variable to "true".
+//
+// [sd_notify]: https://www.freedesktop.org/software/systemd/man/latest/sd_notify.html
+func WithSystemdNotify(notify bool) Option {
+	if notify {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.notify = true
+			},
+		}
+	}
+	return nil
+}
+
+// WithCPUQuotaDetector replaces default CPU quota detector.
+// See [github.com/tprasadtp/go-autotune/maxprocs.WithCPUQuotaDetector].
+func WithCPUQuotaDetector(d maxprocs.CPUQuotaDetector) Option {
+	if d != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.cpuQuotaDetector = d
+			},
+		}
+	}
+	return nil
+}
+
+// WithMemoryQuotaDetector replaces default memory quota detector.
+// See [github.com/tprasadtp/go-autotune/memlimit.WithMemoryQuotaDetector].
+func WithMemoryQuotaDetector(d memlimit.MemoryQuotaDetector) Option {
+	if d != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.memoryQuotaDetector = d
+			},
+		}
+	}
+	return nil
+}
+
+// WithRoundFunc replaces default rounding function for fractional CPU quota.
+// See [github.com/tprasadtp/go-autotune/maxprocs.WithRoundFunc].
+func WithRoundFunc(fn func(float64) int) Option {
+	if fn != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.roundFunc = fn
+			},
+		}
+	}
+	return nil
+}
+
+// WithCPUPolicy replaces default policy used to compute GOMAXPROCS.
+// See [github.com/tprasadtp/go-autotune/maxprocs.WithCPUPolicy].
+func WithCPUPolicy(policy maxprocs.CPUPolicy) Option {
+	if policy != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.cpuPolicy = policy
+			},
+		}
+	}
+	return nil
+}
+
+// WithReserveFunc replaces default reserve function for hard memory limits.
+// See [github.com/tprasadtp/go-autotune/memlimit.WithReserveFunc].
+func WithReserveFunc(fn func(limit int64) (reserve int64)) Option {
+	if fn != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.reserveFunc = fn
+			},
+		}
+	}
+	return nil
+}
+
+// WithLimitPolicy replaces default policy used to compute GOMEMLIMIT.
+// See [github.com/tprasadtp/go-autotune/memlimit.WithLimitPolicy].
+func WithLimitPolicy(policy memlimit.LimitPolicy) Option {
+	if policy != nil {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.limitPolicy = policy
+			},
+		}
+	}
+	return nil
+}
+
+// WithMaxProcs enables or disables configuring GOMAXPROCS. Enabled by default.
+func WithMaxProcs(enable bool) Option {
+	return &optionFunc{
+		fn: func(c *config) {
+			c.disableMaxProcs = !enable
+		},
+	}
+}
+
+// WithMemLimit enables or disables configuring GOMEMLIMIT. Enabled by default.
+func WithMemLimit(enable bool) Option {
+	return &optionFunc{
+		fn: func(c *config) {
+			c.disableMemLimit = !enable
+		},
+	}
+}
+
+// WithShare configures share of CPU and memory limits available to this process,
+// when they are shared by multiple processes. When this package is imported, this
+// can be specified by setting GOAUTOTUNE_SHARE environment variable to a number in
+// range (0, 1). See [github.com/tprasadtp/go-autotune/maxprocs.WithShare] and
+// [github.com/tprasadtp/go-autotune/memlimit.WithShare].
+func WithShare(share float64) Option {
+	if share > 0 && share < 1 {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.share = share
+			},
+		}
+	}
+	return nil
+}
+
+// WithProcessShare divides CPU and memory limits equally among all processes
+// sharing them. When this package is imported, this can be enabled by setting
+// GOAUTOTUNE_SHARE environment variable to "procs".
+// See [github.com/tprasadtp/go-autotune/maxprocs.WithProcessShare] and
+// [github.com/tprasadtp/go-autotune/memlimit.WithProcessShare].
+func WithProcessShare(enable bool) Option {
+	if enable {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.processShare = true
+			},
+		}
+	}
+	return nil
+}
+
+// WithExcludeOthers subtracts memory used by other processes sharing memory
+// limits from memory limits. This does not apply to CPU limits, use it with
+// [WithShare] or [WithProcessShare] to also divide CPU limits. When this package
+// is imported, this can be enabled along with [WithProcessShare] by setting
+// GOAUTOTUNE_SHARE environment variable to "others".
+// See [github.com/tprasadtp/go-autotune/memlimit.WithExcludeOthers].
+func WithExcludeOthers(enable bool) Option {
+	if enable {
+		return &optionFunc{
+			fn: func(c *config) {
+				c.excludeOthers = true
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package autotune
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/internal/trampoline"
+	"github.com/tprasadtp/go-autotune/maxprocs"
+	"github.com/tprasadtp/go-autotune/memlimit"
+)
+
+func TestWithLogger(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		opt := WithLogger(nil)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("NotNil", func(t *testing.T) {
+		cfg := config{}
+		opt := WithLogger(slog.New(trampoline.NewTestingHandler(t)))
+		opt.apply(&cfg)
+		if cfg.logger == nil {
+			t.Errorf("expected non nil logger")
+		}
+	})
+}
+
+func TestWithPreserveExternal(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithPreserveExternal(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithPreserveExternal(true)
+		opt.apply(&cfg)
+		if !cfg.preserve {
+			t.Errorf("expected preserve to be true")
+		}
+	})
+}
+
+func TestWithDetectors(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		if opt := WithCPUQuotaDetector(nil); opt != nil {
+			t.Errorf("expected nil")
+		}
+		if opt := WithMemoryQuotaDetector(nil); opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("NotNil", func(t *testing.T) {
+		cfg := config{}
+		WithCPUQuotaDetector(maxprocs.DefaultCPUQuotaDetector()).apply(&cfg)
+		WithMemoryQuotaDetector(memlimit.DefaultMemoryQuotaDetector()).apply(&cfg)
+		if cfg.cpuQuotaDetector == nil {
+			t.Errorf("expected non nil cpu quota detector")
+		}
+		if cfg.memoryQuotaDetector == nil {
+			t.Errorf("expected non nil memory quota detector")
+		}
+	})
+}
+
+func TestWithFuncs(t *testing.T) {
+	t.Run("Nil", func(t *testing.T) {
+		if opt := WithRoundFunc(nil); opt != nil {
+			t.Errorf("expected nil")
+		}
+		if opt := WithReserveFunc(nil); opt != nil {
+			t.Errorf("expected nil")
+		}
+		if opt := WithCPUPolicy(nil); opt != nil {
+			t.Errorf("expected nil")
+		}
+		if opt := WithLimitPolicy(nil); opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("NotNil", func(t *testing.T) {
+		cfg := config{}
+		WithRoundFunc(func(f float64) int { return int(math.Ceil(f)) }).apply(&cfg)
+		WithReserveFunc(memlimit.DefaultReserveFunc()).apply(&cfg)
+		WithCPUPolicy(maxprocs.CPUSetPolicy()).apply(&cfg)
+		WithLimitPolicy(memlimit.PreferMaxPolicy()).apply(&cfg)
+		if cfg.roundFunc == nil {
+			t.Errorf("expected non nil roundFunc")
+		}
+		if cfg.reserveFunc == nil {
+			t.Errorf("expected non nil reserveFunc")
+		}
+		if cfg.cpuPolicy == nil {
+			t.Errorf("expected non nil cpuPolicy")
+		}
+		if cfg.limitPolicy == nil {
+			t.Errorf("expected non nil limitPolicy")
+		}
+	})
+}
+
+func TestWithEnableFlags(t *testing.T) {
+	cfg := config{}
+	WithMaxProcs(false).apply(&cfg)
+	WithMemLimit(false).apply(&cfg)
+	if !cfg.disableMaxProcs || !cfg.disableMemLimit {
+		t.Errorf("expected both to be disabled")
+	}
+
+	WithMaxProcs(true).apply(&cfg)
+	WithMemLimit(true).apply(&cfg)
+	if cfg.disableMaxProcs || cfg.disableMemLimit {
+		t.Errorf("expected both to be enabled")
+	}
+}
+
+func TestWithSystemdNotify(t *testing.T) {
+	t.Run("False", func(t *testing.T) {
+		opt := WithSystemdNotify(false)
+		if opt != nil {
+			t.Errorf("expected nil")
+		}
+	})
+	t.Run("True", func(t *testing.T) {
+		cfg := config{}
+		opt := WithSystemdNotify(true)
+		opt.apply(&cfg)
+		if !cfg.notify {
+			t.Errorf("expected notify to be true")
+		}
+	})
+}
+
+func TestWithShare(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithShare(0),
+			WithShare(1),
+			WithProcessShare(false),
+			WithExcludeOthers(false),
+		} {
+			if opt != nil {
+				t.Errorf("expected nil")
+			}
+		}
+	})
+	t.Run("Valid", func(t *testing.T) {
+		cfg := config{}
+		WithShare(0.5).apply(&cfg)
+		WithProcessShare(true).apply(&cfg)
+		WithExcludeOthers(true).apply(&cfg)
+		if cfg.share != 0.5 || !cfg.processShare || !cfg.excludeOthers {
+			t.Errorf("unexpected config share=%f, processShare=%t, excludeOthers=%t",
+				cfg.share, cfg.processShare, cfg.excludeOthers)
+		}
+	})
+}
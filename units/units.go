@@ -0,0 +1,303 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+// Package units parses and formats memory sizes.
+//
+// Sizes can be parsed in [GOMEMLIMIT] syntax, [Kubernetes quantity] syntax,
+// [systemd] syntax, or as a percentage relative to a base value. Sizes are
+// formatted in canonical GOMEMLIMIT syntax, which can be parsed by all of them.
+//
+// [GOMEMLIMIT]: https://pkg.go.dev/runtime#hdr-Environment_Variables
+// [Kubernetes quantity]: https://kubernetes.io/docs/reference/kubernetes-api/common-definitions/quantity/
+// [systemd]: https://www.freedesktop.org/software/systemd/man/latest/systemd.resource-control.html#MemoryMax=bytes
+package units
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// IEC (binary) size units.
+const (
+	KiB int64 = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+	PiB
+	EiB
+)
+
+// SI (decimal) size units.
+const (
+	KB int64 = 1000
+	MB       = KB * 1000
+	GB       = MB * 1000
+	TB       = GB * 1000
+	PB       = TB * 1000
+	EB       = PB * 1000
+)
+
+// ParseGOMEMLIMIT parses s in GOMEMLIMIT syntax and returns size in bytes.
+// s must match the following regular expression, or be "off",
+// which returns [math.MaxInt64].
+//
+//	^[0-9]+(([KMGT]i)?B)?$
+func ParseGOMEMLIMIT(s string) (int64, error) {
+	// Value "off" does not appear to be documented but is part of implementation.
+	// preserve the same behavior.
+	//
+	// https://go.googlesource.com/go/+/refs/tags/go1.22.3/src/runtime/mgcpacer.go#1323
+	if s == "off" {
+		return math.MaxInt64, nil
+	}
+
+	// Save index of lastDigit to parse unit.
+	i := digits(s)
+
+	// Try to parse s[0:i] as an integer value.
+	v, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("units: invalid size(%q): %w", s, err)
+	}
+
+	// Because value is parsed as uint64, check if it overflows int64.
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("units: integer overflow: %q", s)
+	}
+
+	// Parse units.
+	unit := s[i:]
+	multiplier := uint64(1)
+
+	switch unit {
+	case "", "B":
+		// already in bytes
+	case "KiB":
+		multiplier = uint64(KiB)
+	case "MiB":
+		multiplier = uint64(MiB)
+	case "GiB":
+		multiplier = uint64(GiB)
+	case "TiB":
+		multiplier = uint64(TiB)
+	default:
+		return 0, fmt.Errorf("units: invalid size unit: %q", unit)
+	}
+
+	rv := v * multiplier
+
+	if rv > 0 {
+		if rv > math.MaxInt64 || rv/multiplier != v {
+			return 0, fmt.Errorf("units: integer overflow: %q", s)
+		}
+	}
+
+	return int64(rv), nil
+}
+
+// ParseQuantity parses s in Kubernetes quantity syntax and returns size in bytes.
+// s is a non-negative decimal number (for example, 1.5) followed by an optional suffix.
+//
+//   - Binary suffixes Ki, Mi, Gi, Ti, Pi and Ei are powers of 1024.
+//   - Decimal suffixes k, M, G, T, P and E are powers of 1000.
+//   - Decimal exponents like e3 or E6 are powers of 10.
+//
+// Fractional bytes are truncated. For example, 1.5Gi is 1610612736 and
+// 250M is 250000000 bytes.
+func ParseQuantity(s string) (int64, error) {
+	num, suffix := split(s)
+	var multiplier *big.Rat
+	switch suffix {
+	case "":
+		multiplier = big.NewRat(1, 1)
+	case "Ki", "Mi", "Gi", "Ti", "Pi", "Ei":
+		multiplier = binary(suffix[0])
+	case "k", "M", "G", "T", "P", "E":
+		multiplier = decimal(suffix[0])
+	default:
+		if suffix[0] != 'e' && suffix[0] != 'E' {
+			return 0, fmt.Errorf("units: invalid quantity suffix: %q", suffix)
+		}
+
+		exp, err := strconv.ParseInt(suffix[1:], 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("units: invalid quantity exponent: %q", suffix)
+		}
+
+		multiplier = new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(abs(exp)), nil))
+		if exp < 0 {
+			multiplier.Inv(multiplier)
+		}
+	}
+	return parse(s, num, multiplier)
+}
+
+// ParseSystemd parses s in systemd size syntax, as used by MemoryMax= and other
+// resource control settings and returns size in bytes. s is a non-negative decimal
+// number followed by an optional suffix B, K, M, G, T, P or E, which are powers
+// of 1024, or "infinity", which returns [math.MaxInt64]. Fractional bytes are
+// truncated. For example, 250M is 262144000 and 1.5G is 1610612736 bytes.
+func ParseSystemd(s string) (int64, error) {
+	if s == "infinity" {
+		return math.MaxInt64, nil
+	}
+
+	num, suffix := split(s)
+	switch suffix {
+	case "", "B":
+		return parse(s, num, big.NewRat(1, 1))
+	case "K", "M", "G", "T", "P", "E":
+		return parse(s, num, binary(suffix[0]))
+	default:
+		return 0, fmt.Errorf("units: invalid size suffix: %q", suffix)
+	}
+}
+
+// ParsePercent parses s as a non-negative percentage (for example, 15% or 12.5%)
+// of base and returns size in bytes. Fractional bytes are truncated.
+// Percentage greater than 100 is not allowed.
+func ParsePercent(s string, base int64) (int64, error) {
+	if base < 0 {
+		return 0, fmt.Errorf("units: invalid base for percentage: %d", base)
+	}
+
+	num, ok := strings.CutSuffix(s, "%")
+	if !ok {
+		return 0, fmt.Errorf("units: invalid percentage(%q): missing %% suffix", s)
+	}
+
+	if n, suffix := split(num); n == "" || suffix != "" {
+		return 0, fmt.Errorf("units: invalid percentage: %q", s)
+	}
+
+	percent, ok := new(big.Rat).SetString(num)
+	if !ok || percent.Cmp(big.NewRat(100, 1)) > 0 {
+		return 0, fmt.Errorf("units: invalid percentage(%q): must be between 0%% and 100%%", s)
+	}
+
+	return parse(s, num, big.NewRat(base, 100))
+}
+
+// Parse parses s as a percentage of base (if it ends with "%"), in GOMEMLIMIT syntax,
+// or in Kubernetes quantity syntax, in that order. This can be used to parse sizes
+// specified by users. Suffix K is accepted as an alias of Kubernetes quantity
+// suffix k. Note that suffixes k, K, M, G and T are decimal (powers of 1000),
+// for example, 512M is 512000000 bytes. Use binary suffixes like Mi or MiB
+// for powers of 1024, or use [ParseSystemd] to parse K, M, G and T as powers
+// of 1024.
+func Parse(s string, base int64) (int64, error) {
+	if strings.HasSuffix(s, "%") {
+		return ParsePercent(s, base)
+	}
+
+	if v, err := ParseGOMEMLIMIT(s); err == nil {
+		return v, nil
+	}
+
+	quantity := s
+	if strings.HasSuffix(quantity, "K") {
+		quantity = strings.TrimSuffix(quantity, "K") + "k"
+	}
+
+	v, err := ParseQuantity(quantity)
+	if err != nil {
+		return 0, fmt.Errorf("units: invalid size: %q", s)
+	}
+	return v, nil
+}
+
+// Format formats bytes in canonical GOMEMLIMIT syntax, using the largest unit
+// (up to TiB) which represents v exactly. For example, 262144000 is formatted as
+// 250MiB and 1000 as 1000B. [math.MaxInt64] is formatted as "off". Zero is
+// formatted as "0". Negative values are formatted in bytes, which are not valid.
+func Format(v int64) string {
+	switch {
+	case v == math.MaxInt64:
+		return "off"
+	case v == 0:
+		return "0"
+	case v < 0:
+		return strconv.FormatInt(v, 10) + "B"
+	}
+
+	for _, unit := range []struct {
+		size int64
+		name string
+	}{
+		{TiB, "TiB"},
+		{GiB, "GiB"},
+		{MiB, "MiB"},
+		{KiB, "KiB"},
+	} {
+		if v%unit.size == 0 {
+			return strconv.FormatInt(v/unit.size, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(v, 10) + "B"
+}
+
+// digits returns number of leading ASCII digits in s.
+func digits(s string) int {
+	var i int
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// split splits s into a decimal number and a suffix. Number may be empty,
+// if s does not start with a decimal number.
+func split(s string) (string, string) {
+	i := digits(s)
+	if i < len(s) && s[i] == '.' {
+		if j := digits(s[i+1:]); j > 0 {
+			i += j + 1
+		} else if i == 0 {
+			return "", s
+		}
+	}
+	return s[:i], s[i:]
+}
+
+// binary returns 1024^n, where n is position of unit in "KMGTPE".
+func binary(unit byte) *big.Rat {
+	n := strings.IndexByte("KMGTPE", unit) + 1
+	return new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(10*n)))
+}
+
+// decimal returns 1000^n, where n is position of unit in "kMGTPE".
+func decimal(unit byte) *big.Rat {
+	n := strings.IndexByte("kMGTPE", unit) + 1
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(1000), big.NewInt(int64(n)), nil))
+}
+
+// parse multiplies decimal number num by multiplier and truncates it to int64.
+func parse(s, num string, multiplier *big.Rat) (int64, error) {
+	if num == "" {
+		return 0, fmt.Errorf("units: invalid size: %q", s)
+	}
+
+	v, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, fmt.Errorf("units: invalid size: %q", s)
+	}
+
+	// Truncate fractional bytes.
+	v.Mul(v, multiplier)
+	rv := new(big.Int).Quo(v.Num(), v.Denom())
+	if !rv.IsInt64() {
+		return 0, fmt.Errorf("units: integer overflow: %q", s)
+	}
+	return rv.Int64(), nil
+}
+
+// abs returns absolute value of v.
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
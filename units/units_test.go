@@ -0,0 +1,343 @@
+// SPDX-FileCopyrightText: Copyright 2024 Prasad Tengse
+// SPDX-License-Identifier: MIT
+
+package units_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tprasadtp/go-autotune/units"
+)
+
+func TestParseGOMEMLIMIT(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect int64
+		valid  bool
+	}{
+		// Good numeric inputs.
+		{"1", 1, true},
+		{"12345", 12345, true},
+		{"012345", 12345, true},
+		{"98765432100", 98765432100, true},
+		{"9223372036854775807", 1<<63 - 1, true},
+
+		// Good trivial suffix inputs.
+		{"1B", 1, true},
+		{"12345B", 12345, true},
+		{"012345B", 12345, true},
+		{"98765432100B", 98765432100, true},
+		{"9223372036854775807B", 1<<63 - 1, true},
+
+		// Good binary suffix inputs.
+		{"1KiB", 1 << 10, true},
+		{"05KiB", 5 << 10, true},
+		{"1MiB", 1 << 20, true},
+		{"10MiB", 10 << 20, true},
+		{"1GiB", 1 << 30, true},
+		{"100GiB", 100 << 30, true},
+		{"1TiB", 1 << 40, true},
+		{"99TiB", 99 << 40, true},
+
+		// Off.
+		{"off", 1<<63 - 1, true},
+		{"Off", 0, false},
+
+		// Good zero inputs.
+		{"0", 0, true},
+		{"0B", 0, true},
+		{"0KiB", 0, true},
+		{"0MiB", 0, true},
+		{"0GiB", 0, true},
+		{"0TiB", 0, true},
+
+		// Bad inputs.
+		{"-0", 0, false},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"a12345", 0, false},
+		{"a12345B", 0, false},
+		{"12345x", 0, false},
+		{"0x12345", 0, false},
+
+		// Bad numeric inputs.
+		{"9223372036854775808", 0, false},
+		{"9223372036854775809", 0, false},
+		{"18446744073709551615", 0, false},
+		{"20496382327982653440", 0, false},
+		{"18446744073709551616", 0, false},
+		{"18446744073709551617", 0, false},
+		{"9999999999999999999999", 0, false},
+
+		// Bad trivial suffix inputs.
+		{"9223372036854775808B", 0, false},
+		{"9223372036854775809B", 0, false},
+		{"18446744073709551615B", 0, false},
+		{"20496382327982653440B", 0, false},
+		{"18446744073709551616B", 0, false},
+		{"18446744073709551617B", 0, false},
+		{"9999999999999999999999B", 0, false},
+
+		// Bad binary suffix inputs.
+		{"1Ki", 0, false},
+		{"05Ki", 0, false},
+		{"10Mi", 0, false},
+		{"100Gi", 0, false},
+		{"99Ti", 0, false},
+		{"22iB", 0, false},
+		{"B", 0, false},
+		{"iB", 0, false},
+		{"KiB", 0, false},
+		{"MiB", 0, false},
+		{"GiB", 0, false},
+		{"TiB", 0, false},
+		{"-120KiB", 0, false},
+		{"-891MiB", 0, false},
+		{"-704GiB", 0, false},
+		{"-42TiB", 0, false},
+		{"99999999999999999999KiB", 0, false},
+		{"99999999999999999MiB", 0, false},
+		{"99999999999999GiB", 0, false},
+		{"99999999999TiB", 0, false},
+		{"555EiB", 0, false},
+
+		// Mistaken SI suffix inputs.
+		{"0KB", 0, false},
+		{"0MB", 0, false},
+		{"0GB", 0, false},
+		{"0TB", 0, false},
+		{"1KB", 0, false},
+		{"05KB", 0, false},
+		{"1MB", 0, false},
+		{"10MB", 0, false},
+		{"1GB", 0, false},
+		{"100GB", 0, false},
+		{"1TB", 0, false},
+		{"99TB", 0, false},
+		{"1K", 0, false},
+		{"05K", 0, false},
+		{"10M", 0, false},
+		{"100G", 0, false},
+		{"99T", 0, false},
+		{"99999999999999999999KB", 0, false},
+		{"99999999999999999MB", 0, false},
+		{"99999999999999GB", 0, false},
+		{"99999999999TB", 0, false},
+		{"99999999999TiB", 0, false},
+		{"555EB", 0, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := units.ParseGOMEMLIMIT(tc.input)
+			check(t, tc.input, v, err, tc.expect, tc.valid)
+		})
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect int64
+		valid  bool
+	}{
+		{"0", 0, true},
+		{"1024", 1024, true},
+		{"1.5", 1, true},
+		{".5Ki", 512, true},
+		{"1Ki", units.KiB, true},
+		{"512Mi", 512 * units.MiB, true},
+		{"1Gi", units.GiB, true},
+		{"1.5Gi", 1536 * units.MiB, true},
+		{"2Ti", 2 * units.TiB, true},
+		{"1Pi", units.PiB, true},
+		{"7Ei", 7 * units.EiB, true},
+		{"1k", 1000, true},
+		{"250M", 250 * units.MB, true},
+		{"1.5G", 1500 * units.MB, true},
+		{"1T", units.TB, true},
+		{"1P", units.PB, true},
+		{"9E", 9 * units.EB, true},
+		{"1e3", 1000, true},
+		{"1E6", units.MB, true},
+		{"15e-1", 1, true},
+		{"9223372036854775807", math.MaxInt64, true},
+
+		{"", 0, false},
+		{".", 0, false},
+		{"1.", 0, false},
+		{"-1Gi", 0, false},
+		{"+1Gi", 0, false},
+		{"1K", 0, false},
+		{"1Ki B", 0, false},
+		{"1KiB", 0, false},
+		{"1GB", 0, false},
+		{"Gi", 0, false},
+		{"1e", 0, false},
+		{"1e1000", 0, false},
+		{"1/2", 0, false},
+		{"8Ei", 0, false},
+		{"10E", 0, false},
+		{"9223372036854775808", 0, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := units.ParseQuantity(tc.input)
+			check(t, tc.input, v, err, tc.expect, tc.valid)
+		})
+	}
+}
+
+func TestParseSystemd(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect int64
+		valid  bool
+	}{
+		{"0", 0, true},
+		{"1024", 1024, true},
+		{"1024B", 1024, true},
+		{"1K", units.KiB, true},
+		{"250M", 250 * units.MiB, true},
+		{"1.5G", 1536 * units.MiB, true},
+		{"2T", 2 * units.TiB, true},
+		{"1P", units.PiB, true},
+		{"7E", 7 * units.EiB, true},
+		{"infinity", math.MaxInt64, true},
+
+		{"", 0, false},
+		{"-1G", 0, false},
+		{"1k", 0, false},
+		{"1Gi", 0, false},
+		{"1GiB", 0, false},
+		{"1G ", 0, false},
+		{"8E", 0, false},
+		{"Infinity", 0, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := units.ParseSystemd(tc.input)
+			check(t, tc.input, v, err, tc.expect, tc.valid)
+		})
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	tt := []struct {
+		input  string
+		base   int64
+		expect int64
+		valid  bool
+	}{
+		{"0%", units.GiB, 0, true},
+		{"10%", 1000, 100, true},
+		{"12.5%", units.GiB, 128 * units.MiB, true},
+		{"33%", 100, 33, true},
+		{"33.3%", 10, 3, true},
+		{"100%", math.MaxInt64, math.MaxInt64, true},
+		{"50%", 0, 0, true},
+
+		{"", 1000, 0, false},
+		{"%", 1000, 0, false},
+		{"10", 1000, 0, false},
+		{"-10%", 1000, 0, false},
+		{"100.1%", 1000, 0, false},
+		{"10Ki%", 1000, 0, false},
+		{"10%", -1, 0, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := units.ParsePercent(tc.input, tc.base)
+			check(t, tc.input, v, err, tc.expect, tc.valid)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect int64
+		valid  bool
+	}{
+		{"25%", 256 * units.MiB, true},
+		{"1024", 1024, true},
+		{"250MiB", 250 * units.MiB, true},
+		{"off", math.MaxInt64, true},
+		{"512Mi", 512 * units.MiB, true},
+		{"250M", 250 * units.MB, true},
+		{"512M", 512 * units.MB, true},
+		{"512k", 512 * units.KB, true},
+		{"512K", 512 * units.KB, true},
+		{"1.5Gi", 1536 * units.MiB, true},
+
+		{"", 0, false},
+		{"1.5GiB", 0, false},
+		{"200%", 0, false},
+		{"invalid", 0, false},
+		{"K", 0, false},
+		{"512KK", 0, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := units.Parse(tc.input, units.GiB)
+			check(t, tc.input, v, err, tc.expect, tc.valid)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tt := []struct {
+		input  int64
+		expect string
+	}{
+		{0, "0"},
+		{1, "1B"},
+		{1000, "1000B"},
+		{units.KiB, "1KiB"},
+		{1536, "1536B"},
+		{1536 * units.KiB, "1536KiB"},
+		{250 * units.MiB, "250MiB"},
+		{225 * units.MiB, "225MiB"},
+		{10*units.GiB - 100*units.MiB, "10140MiB"},
+		{5 * units.GiB, "5GiB"},
+		{2 * units.TiB, "2TiB"},
+		{units.PiB, "1024TiB"},
+		{math.MaxInt64, "off"},
+		{-1, "-1B"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expect, func(t *testing.T) {
+			got := units.Format(tc.input)
+			if got != tc.expect {
+				t.Errorf("expected=%q, got=%q", tc.expect, got)
+			}
+
+			// Formatted values must be valid GOMEMLIMIT values.
+			if tc.input >= 0 {
+				v, err := units.ParseGOMEMLIMIT(got)
+				if err != nil || v != tc.input {
+					t.Errorf("round trip failed for %q: value=%d, err=%v", got, v, err)
+				}
+			}
+		})
+	}
+}
+
+func check(t *testing.T, input string, v int64, err error, expect int64, valid bool) {
+	t.Helper()
+	if valid {
+		if v != expect {
+			t.Errorf("expect value=%d but got=%d", expect, v)
+		}
+		if err != nil {
+			t.Errorf("expected no error but got (%s)", err)
+		}
+	} else {
+		if v != 0 {
+			t.Errorf("expect value to be 0 when input is invalid (%q)", input)
+		}
+		if err == nil {
+			t.Errorf("expected error when input is invalid (%q)", input)
+		}
+	}
+}